@@ -19,6 +19,10 @@ func main() {
 		log.Fatal(err.Error())
 	}
 
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err.Error())
+	}
+
 	if err := bot.Run(cfg); err != nil {
 		log.Fatal(err.Error())
 	}
@@ -1,7 +1,44 @@
 package configs
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
 type Bot struct {
 	BotToken string `envconfig:"BOT_TOKEN" required:"true"`
 	AdminID  int64  `envconfig:"ADMIN_ID" required:"true"`
 	DBConn   string `envconfig:"DB_CONN" required:"true"`
+
+	// Timezone of the college, lessons and notification times are in it.
+	Timezone string `envconfig:"TIMEZONE" default:"Asia/Yekaterinburg"`
+	// NotifyCron is how often due notifications are checked. Minutes between runs are caught up.
+	NotifyCron string `envconfig:"NOTIFY_CRON" default:"* * * * *"`
+	// UpdateCron is how often the schedule is loaded from the portal.
+	UpdateCron string `envconfig:"UPDATE_CRON" default:"0 * * * *"`
+	// SaturdayNextDayHours is the hour of Saturday since which the next week is shown as current, 24 keeps the week until Sunday.
+	SaturdayNextDayHours int `envconfig:"SATURDAY_NEXT_DAY_HOURS" default:"14"`
+}
+
+// Validate checks the timezone and the job schedule, so a misconfigured bot does not start.
+func (b Bot) Validate() error {
+	if _, err := time.LoadLocation(b.Timezone); err != nil {
+		return fmt.Errorf("TIMEZONE: %w", err)
+	}
+
+	if _, err := cron.ParseStandard(b.NotifyCron); err != nil {
+		return fmt.Errorf("NOTIFY_CRON: %w", err)
+	}
+
+	if _, err := cron.ParseStandard(b.UpdateCron); err != nil {
+		return fmt.Errorf("UPDATE_CRON: %w", err)
+	}
+
+	if b.SaturdayNextDayHours < 0 || b.SaturdayNextDayHours > 24 {
+		return fmt.Errorf("SATURDAY_NEXT_DAY_HOURS: %d is not an hour of the day", b.SaturdayNextDayHours)
+	}
+
+	return nil
 }
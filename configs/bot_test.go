@@ -0,0 +1,65 @@
+package configs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBotValidate(t *testing.T) {
+	valid := Bot{
+		Timezone:             "Asia/Yekaterinburg",
+		NotifyCron:           "* * * * *",
+		UpdateCron:           "0 * * * *",
+		SaturdayNextDayHours: 14,
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(cfg *Bot)
+		wantErr bool
+	}{
+		{
+			name:   "defaults",
+			modify: func(cfg *Bot) {},
+		},
+		{
+			name:   "session schedule",
+			modify: func(cfg *Bot) { cfg.UpdateCron = "*/30 6-22 * * *"; cfg.SaturdayNextDayHours = 24 },
+		},
+		{
+			name:    "unknown timezone",
+			modify:  func(cfg *Bot) { cfg.Timezone = "Mars/Olympus" },
+			wantErr: true,
+		},
+		{
+			name:    "invalid notify cron",
+			modify:  func(cfg *Bot) { cfg.NotifyCron = "every minute" },
+			wantErr: true,
+		},
+		{
+			name:    "cron with seconds",
+			modify:  func(cfg *Bot) { cfg.UpdateCron = "0 0 * * * *" },
+			wantErr: true,
+		},
+		{
+			name:    "invalid hour",
+			modify:  func(cfg *Bot) { cfg.SaturdayNextDayHours = 25 },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -24,30 +24,41 @@ const (
 	weeksUrl    = baseUrl + "/get_weekdates_actual"
 	gridUrl     = baseUrl + "/public_shedule_spo_grid"
 	lessonsUrl  = baseUrl + "/public_getsheduleclasses_spo"
-
-	saturdayNextDayHours = 14
-	timezone             = "Asia/Yekaterinburg"
 )
 
+// termLessonsRefresh is how often lessons of the whole term are fetched. They are only used for stats,
+// so they are not fetched on every update together with lessons of the current week.
+const termLessonsRefresh = 24 * time.Hour
+
 var (
 	regexStudyYearId = regexp.MustCompile(`studyyear_id\s*:\s*'(\d+)'`)
 )
 
 type portal struct {
+	timezone string
+	// saturdayNextDayHours is the hour of Saturday since which the next week is current.
+	saturdayNextDayHours int
+
 	studyYearId string
 	term        string
 	streams     []Stream
 	weeks       []Week
 	lessons     map[string][]Lesson
-	mu          sync.RWMutex
+	termLessons map[string][]Lesson
+	// termUpdatedAt is when termLessons were fetched.
+	termUpdatedAt time.Time
+	mu            sync.RWMutex
 }
 
-func New() *portal {
-	return &portal{}
+func New(timezone string, saturdayNextDayHours int) *portal {
+	return &portal{
+		timezone:             timezone,
+		saturdayNextDayHours: saturdayNextDayHours,
+	}
 }
 
-func (*portal) Timezone() string {
-	return timezone
+func (p *portal) Timezone() string {
+	return p.timezone
 }
 
 func (p *portal) Update() error {
@@ -96,6 +107,7 @@ func (p *portal) Update() error {
 			substreams, err := p.collectSubstreams(s.Value, term, studyYearId, week.Value)
 			if err != nil {
 				log.Println(err.Error(), s)
+				streams[i].SubstreamsUnknown = true
 				return
 			}
 
@@ -105,16 +117,17 @@ func (p *portal) Update() error {
 
 	wg.Wait()
 
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.studyYearId = studyYearId
-	p.term = term
-	p.weeks = weeks
-	p.streams = streams
+	termStart := weeks[0].StartDate.Time
+	termEnd := weeks[len(weeks)-1].EndDate.Time
+
+	p.mu.RLock()
+	refreshTerm := p.term != term || p.studyYearId != studyYearId || time.Since(p.termUpdatedAt) >= termLessonsRefresh
+	p.mu.RUnlock()
 
 	wg = sync.WaitGroup{}
 	mu := sync.Mutex{}
 	lessons := make(map[string][]Lesson, len(streams))
+	termLessons := make(map[string][]Lesson, len(streams))
 	for _, stream := range streams {
 		wg.Add(1)
 		go func() {
@@ -129,28 +142,70 @@ func (p *portal) Update() error {
 			lessons[stream.Value] = l
 			mu.Unlock()
 		}()
+
+		if !refreshTerm {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l, err := p.collectSchedule(stream.Value, term, studyYearId, termStart, termEnd)
+			if err != nil {
+				log.Println(err.Error(), stream.Name)
+				return
+			}
+
+			mu.Lock()
+			termLessons[stream.Value] = l
+			mu.Unlock()
+		}()
 	}
 
 	wg.Wait()
+
+	// The schedule is fetched without the lock, so reads are not blocked by requests to the portal
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.studyYearId = studyYearId
+	p.term = term
+	p.weeks = weeks
+	p.streams = streams
 	p.lessons = lessons
+	if refreshTerm {
+		p.termLessons = termLessons
+		p.termUpdatedAt = time.Now()
+	}
 
 	return nil
 }
 
 func (p *portal) CurrentWeekLessons(stream, substream string) ([]models.Lesson, error) {
-	loc, err := time.LoadLocation(timezone)
-	if err != nil {
-		return nil, err
-	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.streamLessons(p.lessons, stream, substream)
+}
 
+// TermLessons returns lessons of the stream for every week of the current term.
+func (p *portal) TermLessons(stream, substream string) ([]models.Lesson, error) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
-	if len(p.lessons) == 0 {
+	return p.streamLessons(p.termLessons, stream, substream)
+}
+
+func (p *portal) streamLessons(source map[string][]Lesson, stream, substream string) ([]models.Lesson, error) {
+	loc, err := time.LoadLocation(p.timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(source) == 0 {
 		return nil, models.ErrLessonsAreEmpty
 	}
 
-	l, ok := p.lessons[stream]
+	l, ok := source[stream]
 	if !ok {
 		return nil, models.ErrStreamIsUnknown
 	}
@@ -221,9 +276,10 @@ func (p *portal) Streams() []models.Stream {
 	streams := make([]models.Stream, len(p.streams))
 	for i, s := range p.streams {
 		streams[i] = models.Stream{
-			ID:         s.Value,
-			Name:       s.Name,
-			Substreams: s.Substreams,
+			ID:                s.Value,
+			Name:              s.Name,
+			Substreams:        s.Substreams,
+			SubstreamsUnknown: s.SubstreamsUnknown,
 		}
 	}
 
@@ -231,7 +287,7 @@ func (p *portal) Streams() []models.Stream {
 }
 
 func (p *portal) currentWeek(weeks []Week) (Week, error) {
-	loc, err := time.LoadLocation(timezone)
+	loc, err := time.LoadLocation(p.timezone)
 	if err != nil {
 		return Week{}, err
 	}
@@ -254,7 +310,7 @@ func (p *portal) currentWeek(weeks []Week) (Week, error) {
 	}
 
 	weekday := now.Weekday()
-	if weekday == time.Saturday && now.Hour() >= saturdayNextDayHours {
+	if weekday == time.Saturday && now.Hour() >= p.saturdayNextDayHours {
 		return weeks[index+1], nil
 	}
 
@@ -9,6 +9,8 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+const timezone = "Asia/Yekaterinburg"
+
 func TestCurrentWeekLessons(t *testing.T) {
 	loc, err := time.LoadLocation(timezone)
 	require.NoError(t, err)
@@ -59,9 +61,8 @@ func TestCurrentWeekLessons(t *testing.T) {
 		},
 	}
 
-	p := &portal{
-		lessons: testLessons,
-	}
+	p := New(timezone, 14)
+	p.lessons = testLessons
 
 	tests := []struct {
 		name            string
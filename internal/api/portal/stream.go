@@ -1,7 +1,8 @@
 package portal
 
 type Stream struct {
-	Name       string
-	Value      string
-	Substreams []string
+	Name              string
+	Value             string
+	Substreams        []string
+	SubstreamsUnknown bool
 }
@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"context"
 	"log"
 	"pgtk-schedule/configs"
 	"pgtk-schedule/internal/api/portal"
@@ -8,12 +9,23 @@ import (
 	"pgtk-schedule/internal/service"
 	"pgtk-schedule/internal/transport/tg"
 	"pgtk-schedule/pkg/database"
+	"pgtk-schedule/pkg/ratelimit"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
 	"gopkg.in/telebot.v4"
 )
 
+const (
+	jobNotify = "notify"
+	jobUpdate = "update"
+
+	// leaderLockKey is the advisory lock held by the instance running jobs
+	leaderLockKey = 2025_0205
+	// jobGrace is how far back missed job slots are caught up
+	jobGrace = 10 * time.Minute
+)
+
 func Run(cfg configs.Bot) error {
 	// Bot
 	pref := telebot.Settings{
@@ -24,7 +36,7 @@ func Run(cfg configs.Bot) error {
 		},
 		Poller: &telebot.LongPoller{
 			Timeout:        3 * time.Second,
-			AllowedUpdates: []string{"message", "chat_member", "callback_query", "poll", "inline_query"},
+			AllowedUpdates: []string{"message", "chat_member", "my_chat_member", "callback_query", "poll", "inline_query"},
 		},
 		ParseMode: telebot.ModeHTML,
 	}
@@ -35,7 +47,7 @@ func Run(cfg configs.Bot) error {
 	}
 
 	// Api
-	portal := portal.New()
+	portal := portal.New(cfg.Timezone, cfg.SaturdayNextDayHours)
 
 	// Database
 	pool, err := database.NewPgx(cfg.DBConn)
@@ -46,33 +58,105 @@ func Run(cfg configs.Bot) error {
 	// Repository
 	studentRepo := repository.NewStudent(pool)
 	notifySettingsRepo := repository.NewNotifySettings(pool)
+	subscriptionRepo := repository.NewSubscription(pool)
+	teacherRepo := repository.NewTeacher(pool)
+	outboxRepo := repository.NewOutbox(pool)
+	notificationLogRepo := repository.NewNotificationLog(pool)
+	jobRunRepo := repository.NewJobRun(pool)
+	leader := repository.NewLeader(pool, leaderLockKey)
+	lessonSnapshotRepo := repository.NewLessonSnapshot(pool)
+	scheduleMessageRepo := repository.NewScheduleMessage(pool)
+	firstLessonRepo := repository.NewFirstLesson(pool)
+	chatRepo := repository.NewChat(pool)
+	streamRepo := repository.NewStream(pool)
+
+	loc, err := time.LoadLocation(portal.Timezone())
+	if err != nil {
+		return err
+	}
 
 	// Service
 	studentService := service.NewStudent(studentRepo)
-	scheduleService := service.NewSchedule(portal)
-	teacherService := service.NewTeacher(portal, scheduleService)
+	scheduleService := service.NewSchedule(portal, loc)
+	teacherService := service.NewTeacher(portal, scheduleService, teacherRepo)
 	notifySettingsService := service.NewNotifySettings(notifySettingsRepo)
+	statsService := service.NewStats(portal)
+	subscriptionService := service.NewSubscription(subscriptionRepo, studentRepo, portal)
+	compareService := service.NewCompare(scheduleService)
+	outboxService := service.NewOutbox(outboxRepo, notificationLogRepo)
+	changeService := service.NewChange(lessonSnapshotRepo, firstLessonRepo, scheduleService)
+	scheduleMessageService := service.NewScheduleMessage(scheduleMessageRepo, scheduleService)
+	chatService := service.NewChat(chatRepo)
+	streamService := service.NewStream(streamRepo, portal)
+	jobRunner := service.NewJobRunner(jobRunRepo, leader, jobGrace)
+	reminderService := service.NewReminder(studentRepo, notifySettingsRepo, subscriptionService, scheduleService, teacherService)
 
 	// Handlers
-	studentHandlers := tg.NewStudent(bot, studentService, portal)
-	scheduleHandlers := tg.NewSchedule(scheduleService)
-	teacherHandlers := tg.NewTeacher(bot, teacherService)
-	adminHandlers := tg.NewAdmin(bot, studentService, cfg.AdminID)
-	notifyHandlers := tg.NewNotify(bot, studentService, scheduleService, notifySettingsService)
+	picker := tg.NewPicker(bot, portal)
+	studentHandlers := tg.NewStudent(bot, studentService, subscriptionService, notifySettingsService, portal, picker)
+	scheduleHandlers := tg.NewSchedule(scheduleService, teacherService, changeService, scheduleMessageService)
+	teacherHandlers := tg.NewTeacher(bot, teacherService, studentService)
+	adminHandlers := tg.NewAdmin(bot, studentService, outboxService, streamService, portal, cfg.AdminID)
+	notifyHandlers := tg.NewNotify(bot, studentService, scheduleService, notifySettingsService, subscriptionService, teacherService, reminderService, outboxService, changeService, scheduleMessageService, loc)
+	subscriptionHandlers := tg.NewSubscription(bot, subscriptionService, studentService, portal, picker)
+	compareHandlers := tg.NewCompare(compareService, subscriptionService, portal)
+	statsHandlers := tg.NewStats(statsService)
+	inlineHandlers := tg.NewInline(scheduleService, portal, loc)
+	chatHandlers := tg.NewChat(bot, chatService, scheduleService, outboxService, scheduleMessageService, portal, picker)
 
 	if err := scheduleService.Update(); err != nil {
 		return err
 	}
 
+	if err := streamService.Remember(context.Background()); err != nil {
+		return err
+	}
+
+	if err := teacherService.Index(context.Background()); err != nil {
+		return err
+	}
+
+	if err := reminderService.Rebuild(context.Background(), time.Now()); err != nil {
+		return err
+	}
+
 	err = bot.SetCommands([]telebot.Command{
 		{
 			Text:        "/setstream",
 			Description: "Измененить группу и подгруппу",
 		},
+		{
+			Text:        "/today",
+			Description: "Расписание на сегодня",
+		},
+		{
+			Text:        "/tomorrow",
+			Description: "Расписание на завтра",
+		},
+		{
+			Text:        "/week",
+			Description: "Расписание на неделю",
+		},
+		{
+			Text:        "/groups",
+			Description: "Мои группы",
+		},
+		{
+			Text:        "/compare",
+			Description: "Найти общее свободное время групп",
+		},
 		{
 			Text:        "/findteacher",
 			Description: "Найти преподавателя",
 		},
+		{
+			Text:        "/stats",
+			Description: "Статистика пар за семестр",
+		},
+		{
+			Text:        "/iamteacher",
+			Description: "Я преподаватель",
+		},
 		{
 			Text:        "/notifysettings",
 			Description: "Изменить настройки уведомлений",
@@ -90,44 +174,132 @@ func Run(cfg configs.Bot) error {
 	weekButton := markup.Text("Получить расписание на неделю")
 	todayButton := markup.Text("На сегодня")
 	tomorrowButton := markup.Text("На завтра")
+	groupsButton := markup.Text("Мои группы")
 	markup.ResizeKeyboard = true
-	markup.Reply(telebot.Row{weekButton}, telebot.Row{todayButton, tomorrowButton})
+	markup.Reply(telebot.Row{weekButton}, telebot.Row{todayButton, tomorrowButton}, telebot.Row{groupsButton})
 
-	bot.Handle("/start", func(ctx telebot.Context) error {
+	onboardingHandlers := tg.NewOnboarding(bot, studentService, subscriptionService, notifySettingsService, scheduleService, portal, picker, markup)
+
+	bot.Handle("/start", studentHandlers.Start(onboardingHandlers.Start(func(ctx telebot.Context) error {
 		return ctx.Reply("Привет! Вышло обновление бота. Со следующего учебного года поддержка бота будет платной, потому что никто из студентов не хочет поддерживать бота. Необходимо будет оплачивать сервер каждый месяц. Подробнее можно спросить у @kostromin59.\n\nИспользуйте команду /feedback для обратной связи.", markup)
-	})
-	bot.Handle("/setstream", studentHandlers.SetStream(), studentHandlers.RegisteredStudent())
+	})), studentHandlers.RegisteredStudent())
+	bot.Handle(telebot.OnMyChatMember, studentHandlers.ChatMember(), tg.InGroup(chatHandlers.ChatMember()))
+	bot.Handle(telebot.OnMigration, chatHandlers.Migrate())
+	bot.Handle("/setstream", studentHandlers.SetStream(), tg.InGroup(chatHandlers.SetStream()), studentHandlers.RegisteredStudent())
+	bot.Handle("/chatsettings", func(ctx telebot.Context) error {
+		return ctx.Reply("Команда работает только в групповых чатах. Ваши уведомления настраиваются командой /notifysettings")
+	}, tg.InGroup(chatHandlers.Settings()))
+	bot.Handle(telebot.OnQuery, inlineHandlers.Query())
 	bot.Handle("/findteacher", teacherHandlers.Find())
+	bot.Handle("/iamteacher", teacherHandlers.Register(), studentHandlers.RegisteredStudent())
+	subscriptionsList := subscriptionHandlers.List()
+	bot.Handle("/groups", subscriptionsList, studentHandlers.RegisteredStudent())
 	bot.Handle("/send", adminHandlers.Send(), adminHandlers.ValidateAdmin())
+	bot.Handle("/report", adminHandlers.Report(), adminHandlers.ValidateAdmin())
+	bot.Handle("/invite", adminHandlers.Invite(), adminHandlers.ValidateAdmin())
+	bot.Handle("/rollover", adminHandlers.Rollover(), adminHandlers.ValidateAdmin())
 	bot.Handle("/notifysettings", notifyHandlers.Change(), studentHandlers.RegisteredStudent(), studentHandlers.ValidateStudent())
+	bot.Handle("/compare", compareHandlers.Groups(), studentHandlers.RegisteredStudent())
+	bot.Handle("/stats", statsHandlers.Term(), studentHandlers.RegisteredStudent(), studentHandlers.ValidateStudent())
 	bot.Handle("/feedback", func(ctx telebot.Context) error {
 		return ctx.Reply("Напишите @kostromin59, чтобы сообщить о проблеме, предложить новый функционал или договориться о дальнейшей поддержке бота")
 	})
 
-	bot.Handle(&weekButton, scheduleHandlers.CurrentWeekLessons(), studentHandlers.RegisteredStudent(), studentHandlers.ValidateStudent())
-	bot.Handle(&todayButton, scheduleHandlers.TodayLessons(), studentHandlers.RegisteredStudent(), studentHandlers.ValidateStudent())
-	bot.Handle(&tomorrowButton, scheduleHandlers.TomorrowLessons(), studentHandlers.RegisteredStudent(), studentHandlers.ValidateStudent())
+	// Group chats get the schedule of the bound stream, private chats the schedule of the student
+	handleSchedule := func(endpoints []any, handler telebot.HandlerFunc) {
+		for _, endpoint := range endpoints {
+			bot.Handle(endpoint, handler, tg.InGroup(chatHandlers.Bound()(handler)), studentHandlers.RegisteredStudent(), studentHandlers.ValidateStudent())
+		}
+	}
+	handleSchedule([]any{&weekButton, "/week"}, scheduleHandlers.CurrentWeekLessons())
+	handleSchedule([]any{&todayButton, "/today"}, scheduleHandlers.TodayLessons())
+	handleSchedule([]any{&tomorrowButton, "/tomorrow"}, scheduleHandlers.TomorrowLessons())
+	bot.Handle(&groupsButton, subscriptionsList, studentHandlers.RegisteredStudent())
+	bot.Handle(telebot.OnText, picker.Search())
 
-	// Cron jobs
-	s, err := gocron.NewScheduler()
+	// Cron jobs run in the timezone of the college
+	s, err := gocron.NewScheduler(gocron.WithLocation(loc))
 	if err != nil {
 		return err
 	}
 
-	s.NewJob(gocron.CronJob("TZ=Asia/Yekaterinburg 0 5 * * 1-6", false), gocron.NewTask(notifyHandlers.Morning))
-	s.NewJob(gocron.CronJob("TZ=Asia/Yekaterinburg 0 18 * * 1-5", false), gocron.NewTask(notifyHandlers.Evening))
-	s.NewJob(gocron.CronJob("TZ=Asia/Yekaterinburg 0 12 * * 0", false), gocron.NewTask(notifyHandlers.Week))
+	// Notification times are chosen by students, so every minute between runs is checked.
+	// Only the leader sends notifications, minutes missed during a restart are caught up.
+	_, err = s.NewJob(gocron.CronJob(cfg.NotifyCron, false), gocron.NewTask(func() {
+		slot := time.Now().In(loc).Truncate(time.Minute)
+		err := jobRunner.CatchUp(context.Background(), jobNotify, slot, time.Minute, func(slot time.Time) error {
+			notifyHandlers.Dispatch(slot)
+			chatHandlers.Dispatch(slot)
+			return nil
+		})
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}))
 
-	s.NewJob(gocron.CronJob("0 * * * *", false), gocron.NewTask(func() {
+	if err != nil {
+		return err
+	}
+
+	// Every instance refreshes the schedule and caches built from it, so commands are answered with current lessons
+	// and a new leader does not notify from stale data. Only the leader detects changes and alerts students.
+	_, err = s.NewJob(gocron.CronJob(cfg.UpdateCron, false), gocron.NewTask(func() {
 		if err := scheduleService.Update(); err != nil {
 			log.Println(err.Error())
+			return
 		}
 		log.Println("schedule has been updated!")
+
+		if err := teacherService.Index(context.Background()); err != nil {
+			log.Println(err.Error())
+		}
+
+		if err := reminderService.Rebuild(context.Background(), time.Now()); err != nil {
+			log.Println(err.Error())
+		}
+
+		slot := time.Now().In(loc).Truncate(time.Minute)
+		err := jobRunner.Run(context.Background(), jobUpdate, slot, func(slot time.Time) error {
+			if err := streamService.Remember(context.Background()); err != nil {
+				log.Println(err.Error())
+			}
+
+			if err := notifyHandlers.AlertMissingStreams(time.Now().In(loc), portal.Streams()); err != nil {
+				log.Println(err.Error())
+			}
+
+			if err := notifyHandlers.Refresh(time.Now().In(loc)); err != nil {
+				log.Println(err.Error())
+			}
+
+			if err := notifyHandlers.Alert(time.Now().In(loc)); err != nil {
+				log.Println(err.Error())
+			}
+
+			return notifyHandlers.AlertFirstCancel(time.Now().In(loc))
+		})
+		if err != nil {
+			log.Println(err.Error())
+		}
 	}))
+	if err != nil {
+		return err
+	}
+
+	// Leadership is given up after jobs are stopped
+	defer leader.Close(context.Background())
 
 	s.Start()
 	defer s.Shutdown()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Notifications and broadcasts are delivered from the outbox by the leader instance.
+	// Telegram allows about 30 messages per second overall and one message per second to a chat.
+	limiter := ratelimit.New(25, 25, 1)
+	go tg.NewSender(bot, outboxService, studentService, chatService, leader, limiter, 8).Run(ctx)
+
 	bot.Start()
 
 	return nil
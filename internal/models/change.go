@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// LessonSnapshot is the lessons of the date last sent to the student for the group.
+type LessonSnapshot struct {
+	StudentID int64
+	Stream    string
+	Substream string
+	Date      time.Time
+	Lessons   []Lesson
+}
+
+// LessonChange is a lesson that got another room or time.
+type LessonChange struct {
+	Before Lesson
+	After  Lesson
+}
+
+// ScheduleChange is the changes of the date the student has to be alerted about.
+type ScheduleChange struct {
+	StudentID int64
+	Stream    string
+	Substream string
+	Date      time.Time
+	Changes   []LessonChange
+}
+
+// FirstLesson is the first lesson of the date sent to the student in the morning notification.
+type FirstLesson struct {
+	StudentID int64
+	Stream    string
+	Substream string
+	Date      time.Time
+	Lesson    Lesson
+}
+
+// FirstLessonCancel is the cancelled first lesson of the date. After is the new first lesson,
+// it is nil when no lessons are left.
+type FirstLessonCancel struct {
+	StudentID int64
+	Stream    string
+	Substream string
+	Date      time.Time
+	Before    Lesson
+	After     *Lesson
+}
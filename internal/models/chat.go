@@ -0,0 +1,32 @@
+package models
+
+import "errors"
+
+var ErrChatNotFound = errors.New("chat not found")
+
+// Chat is a group chat bound to a stream. Its members get the schedule of the stream.
+type Chat struct {
+	ID        int64
+	Title     string
+	Stream    *string
+	Substream *string
+	// Active is false after the bot is removed from the chat.
+	Active bool
+	// Times are minutes since midnight.
+	MorningTime int
+	EveningTime int
+	// Pin is set when the morning schedule is pinned in the chat.
+	Pin bool
+}
+
+// Schedule returns time in minutes since midnight of the notification kind.
+func (c Chat) Schedule(kind NotifyKind) int {
+	switch kind {
+	case NotifyMorning:
+		return c.MorningTime
+	case NotifyEvening:
+		return c.EveningTime
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotEnoughGroups = errors.New("at least two groups are required")
+)
+
+type Group struct {
+	Stream    string
+	Substream string
+	Title     string
+}
+
+type FreeWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// DayComparison holds lessons of compared groups for one day. Lessons are in the same order as groups.
+type DayComparison struct {
+	Date    time.Time
+	Free    []FreeWindow
+	Lessons [][]Lesson
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+type DeliveryStatus string
+
+const (
+	DeliveryQueued  DeliveryStatus = "queued"
+	DeliverySent    DeliveryStatus = "sent"
+	DeliverySkipped DeliveryStatus = "skipped"
+	DeliveryEmpty   DeliveryStatus = "empty"
+	DeliveryFailed  DeliveryStatus = "failed"
+)
+
+// NotificationLog is an outcome of a job for one student.
+type NotificationLog struct {
+	ID        int64
+	RunID     string
+	Kind      string
+	StudentID int64
+	Status    DeliveryStatus
+	OutboxID  *int64
+	MessageID *int64
+	Error     *string
+	// Key identifies the message among messages of the kind to the student, so it is queued once.
+	Key       *string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// RunReport summarises outcomes of a job run.
+type RunReport struct {
+	RunID     string
+	Kind      string
+	StartedAt time.Time
+	Counts    map[DeliveryStatus]int
+}
+
+// RunID identifies the daily run of the job of the kind.
+func RunID(kind string, at time.Time) string {
+	return kind + "-" + at.Format(time.DateOnly)
+}
@@ -1,15 +1,68 @@
 package models
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
 	ErrNotifySettingsNotFound = errors.New("notify settings not found")
+	ErrNotifyKindUnknown      = errors.New("unknown notify kind")
+)
+
+type NotifyKind string
+
+const (
+	NotifyMorning NotifyKind = "morning"
+	NotifyEvening NotifyKind = "evening"
+	NotifyWeek    NotifyKind = "week"
 )
 
+// Weekdays is a set of weekdays where bit N stands for time.Weekday(N).
+type Weekdays int16
+
+func (w Weekdays) Has(weekday time.Weekday) bool {
+	return w&(1<<weekday) != 0
+}
+
 type NotifySettings struct {
-	ID        int64
-	StudentID int64
-	Morning   bool
-	Evening   bool
-	Week      bool
+	ID           int64
+	StudentID    int64
+	Morning      bool
+	Evening      bool
+	Week         bool
+	MorningTime  int
+	MorningDays  Weekdays
+	EveningTime  int
+	EveningDays  Weekdays
+	WeekTime     int
+	WeekDays     Weekdays
+	Reminder     bool
+	ReminderLead int
+	// PausedUntil is the date notifications are resumed at.
+	PausedUntil *time.Time
+}
+
+// Paused reports whether notifications are paused on the date.
+func (ns NotifySettings) Paused(date time.Time) bool {
+	return ns.PausedUntil != nil && Date(date).Before(*ns.PausedUntil)
+}
+
+// Date returns the calendar date of t as it is stored in date columns.
+func Date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Schedule returns time in minutes since midnight and days of the notification kind.
+func (ns NotifySettings) Schedule(kind NotifyKind) (int, Weekdays) {
+	switch kind {
+	case NotifyMorning:
+		return ns.MorningTime, ns.MorningDays
+	case NotifyEvening:
+		return ns.EveningTime, ns.EveningDays
+	case NotifyWeek:
+		return ns.WeekTime, ns.WeekDays
+	default:
+		return 0, 0
+	}
 }
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	OutboxSent    OutboxStatus = "sent"
+	OutboxFailed  OutboxStatus = "failed"
+)
+
+// Kinds of queued messages besides notification kinds.
+const (
+	OutboxReminder    = "reminder"
+	OutboxResume      = "resume"
+	OutboxBroadcast   = "broadcast"
+	OutboxChange      = "change"
+	OutboxEdit        = "edit"
+	OutboxFirstCancel = "first_cancel"
+	OutboxStreamGone  = "stream_gone"
+)
+
+// OutboxMessage is a message waiting for delivery or already delivered.
+type OutboxMessage struct {
+	ID            int64
+	ChatID        int64
+	Kind          string
+	Text          string
+	Status        OutboxStatus
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     *string
+	MessageID     *int64
+	// EditMessageID is set when the message replaces the text of an already sent one.
+	EditMessageID *int64
+	// Pin is set when the sent message replaces the pinned message of the chat.
+	Pin       bool
+	CreatedAt time.Time
+	SentAt    *time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Reminder is a message sent Lead minutes before the lesson starts.
+type Reminder struct {
+	StudentID int64
+	Title     string
+	Lesson    Lesson
+	Lead      int
+	At        time.Time
+}
@@ -0,0 +1,14 @@
+package models
+
+// StaleStream is a stream that has disappeared from the portal but is still selected by students.
+type StaleStream struct {
+	Stream
+	Students int
+}
+
+// StreamMapping moves students from an old stream to a new one at the start of a study year.
+type StreamMapping struct {
+	Old StaleStream
+	// New is nil when no similar stream is found on the portal.
+	New *Stream
+}
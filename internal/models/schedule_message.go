@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ScheduleMessage is a sent message with lessons of the group. It is edited when the lessons change.
+type ScheduleMessage struct {
+	ID     int64
+	ChatID int64
+	// OutboxID is set for queued messages until they are sent.
+	OutboxID  *int64
+	MessageID *int64
+	Stream    string
+	Substream string
+	// Header is the text before the lessons.
+	Header   string
+	DateFrom time.Time
+	DateTo   time.Time
+	Lessons  []Lesson
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+type Stats struct {
+	Pairs       int
+	Hours       int
+	Disciplines []DisciplineStats
+	Teachers    []TeacherStats
+	Weekdays    []WeekdayStats
+}
+
+type DisciplineStats struct {
+	Name  string
+	Pairs int
+	Hours int
+	Types []LessonTypeStats
+}
+
+type LessonTypeStats struct {
+	Type  string
+	Pairs int
+	Hours int
+}
+
+type TeacherStats struct {
+	Name  string
+	Pairs int
+	Hours int
+}
+
+type WeekdayStats struct {
+	Weekday time.Weekday
+	Pairs   int
+}
@@ -10,4 +10,6 @@ type Stream struct {
 	ID         string
 	Name       string
 	Substreams []string
+	// SubstreamsUnknown is set when substreams failed to load, so an empty list says nothing about them.
+	SubstreamsUnknown bool
 }
@@ -7,9 +7,44 @@ var (
 	ErrStudentStreamMissed = errors.New("student must contains stream")
 )
 
+const (
+	RoleStudent = "student"
+	RoleTeacher = "teacher"
+)
+
+const (
+	OnboardingStream = "stream"
+	OnboardingNotify = "notify"
+)
+
 type Student struct {
 	ID        int64
 	Nickname  *string
 	Stream    *string
 	Substream *string
+	Role      string
+	Teacher   *string
+	// Active is false while the user has the bot blocked or the account deleted.
+	Active bool
+	// StreamMissing is true when the stream or substream has disappeared from the portal
+	// and the student has not selected a new one yet.
+	StreamMissing bool
+	// OnboardingStep is the unfinished step of the onboarding after /start.
+	OnboardingStep *string
+}
+
+// IsTeacher reports whether the user has registered as a teacher.
+func (s Student) IsTeacher() bool {
+	return s.Role == RoleTeacher && s.Teacher != nil
+}
+
+// NeedsOnboarding reports whether the user has not set up the bot yet or has left the onboarding
+// at the notifications step. The group step is done once the group is set or the user is a teacher,
+// e.g. by /setstream or /iamteacher.
+func (s Student) NeedsOnboarding() bool {
+	if s.OnboardingStep != nil && *s.OnboardingStep == OnboardingNotify {
+		return true
+	}
+
+	return s.Stream == nil && !s.IsTeacher()
 }
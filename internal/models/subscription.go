@@ -0,0 +1,17 @@
+package models
+
+import "errors"
+
+var (
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+)
+
+// Subscription is a group followed by a student. The active group is stored in the student itself.
+type Subscription struct {
+	ID        int64
+	StudentID int64
+	Stream    string
+	Substream string
+	Label     string
+	Notify    bool
+}
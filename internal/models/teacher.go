@@ -0,0 +1,13 @@
+package models
+
+import "errors"
+
+var (
+	ErrTeacherNotFound = errors.New("teacher not found")
+)
+
+// Teacher is a teacher with a short stable identifier suitable for callback data.
+type Teacher struct {
+	ID   int64
+	Name string
+}
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pgtk-schedule/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// chatColumns maps notification kinds posted to group chats to column prefixes of chats.
+var chatColumns = map[models.NotifyKind]string{
+	models.NotifyMorning: "morning",
+	models.NotifyEvening: "evening",
+}
+
+type chat struct {
+	pool *pgxpool.Pool
+}
+
+func NewChat(pool *pgxpool.Pool) *chat {
+	return &chat{
+		pool: pool,
+	}
+}
+
+// Save creates the chat or activates it again when the bot is added back.
+func (c *chat) Save(ctx context.Context, id int64, title string) error {
+	query := `INSERT INTO chats(id, title) VALUES ($1, $2)
+	ON CONFLICT (id) DO UPDATE SET title = excluded.title, active = true;`
+	_, err := c.pool.Exec(ctx, query, id, title)
+	return err
+}
+
+func (c *chat) FindByID(ctx context.Context, id int64) (models.Chat, error) {
+	query := `SELECT title, stream, substream, active, morning_time, evening_time, pin FROM chats WHERE id = $1;`
+	row := c.pool.QueryRow(ctx, query, id)
+	chat := models.Chat{
+		ID: id,
+	}
+
+	err := row.Scan(&chat.Title, &chat.Stream, &chat.Substream, &chat.Active, &chat.MorningTime, &chat.EveningTime, &chat.Pin)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return chat, models.ErrChatNotFound
+		}
+
+		return chat, err
+	}
+
+	return chat, nil
+}
+
+// UpdateStream binds the chat to the stream. Empty substream means the whole stream.
+func (c *chat) UpdateStream(ctx context.Context, id int64, stream, substream string) error {
+	query := `UPDATE chats SET stream = $1, substream = NULLIF($2, '') WHERE id = $3;`
+	tag, err := c.pool.Exec(ctx, query, stream, substream, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() != 1 {
+		return models.ErrChatNotFound
+	}
+
+	return nil
+}
+
+func (c *chat) Deactivate(ctx context.Context, id int64) error {
+	query := `UPDATE chats SET active = false WHERE id = $1;`
+	_, err := c.pool.Exec(ctx, query, id)
+	return err
+}
+
+// ShiftTime moves notification time by delta minutes wrapping around midnight.
+func (c *chat) ShiftTime(ctx context.Context, id int64, kind models.NotifyKind, delta int) error {
+	column, ok := chatColumns[kind]
+	if !ok {
+		return models.ErrNotifyKindUnknown
+	}
+
+	query := fmt.Sprintf(`UPDATE chats SET %[1]s_time = ((%[1]s_time + $1) %% 1440 + 1440) %% 1440
+	WHERE id = $2`, column)
+	tag, err := c.pool.Exec(ctx, query, delta, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() != 1 {
+		return models.ErrChatNotFound
+	}
+
+	return nil
+}
+
+func (c *chat) TogglePin(ctx context.Context, id int64) error {
+	query := `UPDATE chats SET pin = NOT pin WHERE id = $1;`
+	tag, err := c.pool.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() != 1 {
+		return models.ErrChatNotFound
+	}
+
+	return nil
+}
+
+// UpdatePinned stores the pinned message of the chat and returns the previously pinned one.
+func (c *chat) UpdatePinned(ctx context.Context, id int64, messageId int64) (*int64, error) {
+	query := `UPDATE chats c SET pinned_message_id = $1
+	FROM (SELECT id, pinned_message_id FROM chats WHERE id = $2 FOR UPDATE) previous
+	WHERE c.id = previous.id
+	RETURNING previous.pinned_message_id;`
+
+	var previous *int64
+	err := c.pool.QueryRow(ctx, query, messageId, id).Scan(&previous)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, models.ErrChatNotFound
+		}
+
+		return nil, err
+	}
+
+	return previous, nil
+}
+
+// Migrate moves settings and sent schedules of a group to the supergroup it has been upgraded to.
+// A row saved for the supergroup before the migration event is replaced by the settings of the group.
+func (c *chat) Migrate(ctx context.Context, from, to int64) error {
+	return pgx.BeginFunc(ctx, c.pool, func(tx pgx.Tx) error {
+		query := `DELETE FROM chats WHERE id = $1 AND EXISTS (SELECT 1 FROM chats WHERE id = $2);`
+		if _, err := tx.Exec(ctx, query, to, from); err != nil {
+			return err
+		}
+
+		query = `UPDATE chats SET id = $1, active = true WHERE id = $2;`
+		if _, err := tx.Exec(ctx, query, to, from); err != nil {
+			return err
+		}
+
+		query = `UPDATE schedule_messages SET chat_id = $1 WHERE chat_id = $2;`
+		_, err := tx.Exec(ctx, query, to, from)
+		return err
+	})
+}
+
+// FindAllDue returns active bound chats with notification of the kind scheduled at minute of the date.
+func (c *chat) FindAllDue(ctx context.Context, kind models.NotifyKind, minute int, date time.Time, id int64, limit int) ([]models.Chat, int64, error) {
+	column, ok := chatColumns[kind]
+	if !ok {
+		return nil, id, nil
+	}
+
+	query := fmt.Sprintf(`SELECT id, title, stream, substream, active, morning_time, evening_time, pin FROM chats
+	WHERE %[1]s_time = $1 AND %[1]s_days & $2 <> 0 AND active AND stream IS NOT NULL AND id > $3
+	ORDER BY id LIMIT $4`, column)
+	rows, err := c.pool.Query(ctx, query, minute, int16(1)<<date.Weekday(), id, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	chats, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Chat])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	lastSeenID := id
+	if len(chats) > 0 {
+		lastSeenID = chats[len(chats)-1].ID
+	}
+
+	return chats, lastSeenID, nil
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"pgtk-schedule/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type firstLesson struct {
+	pool *pgxpool.Pool
+}
+
+func NewFirstLesson(pool *pgxpool.Pool) *firstLesson {
+	return &firstLesson{
+		pool: pool,
+	}
+}
+
+func (fl *firstLesson) Save(ctx context.Context, first models.FirstLesson) error {
+	query := `INSERT INTO first_lessons(student_id, stream, substream, date, lesson) VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (student_id, stream, substream, date) DO UPDATE SET lesson = excluded.lesson, alerted_at = NULL;`
+	_, err := fl.pool.Exec(ctx, query, first.StudentID, first.Stream, first.Substream, first.Date, first.Lesson)
+	return err
+}
+
+// FindNotAlerted returns first lessons of the date the students were not alerted about yet.
+// Inactive students and students with paused notifications are skipped.
+func (fl *firstLesson) FindNotAlerted(ctx context.Context, date time.Time) ([]models.FirstLesson, error) {
+	query := `SELECT fl.student_id, fl.stream, fl.substream, fl.date, fl.lesson FROM first_lessons fl
+	JOIN students s ON s.id = fl.student_id
+	JOIN notify_settings ns ON ns.student_id = fl.student_id
+	WHERE fl.date = $1 AND fl.alerted_at IS NULL AND s.active AND (ns.paused_until IS NULL OR ns.paused_until <= $1)
+	ORDER BY fl.student_id;`
+	rows, err := fl.pool.Query(ctx, query, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.FirstLesson])
+}
+
+func (fl *firstLesson) MarkAlerted(ctx context.Context, first models.FirstLesson) error {
+	query := `UPDATE first_lessons SET alerted_at = now() WHERE student_id = $1 AND stream = $2 AND substream = $3 AND date = $4;`
+	_, err := fl.pool.Exec(ctx, query, first.StudentID, first.Stream, first.Substream, first.Date)
+	return err
+}
+
+func (fl *firstLesson) DeleteBefore(ctx context.Context, date time.Time) error {
+	query := `DELETE FROM first_lessons WHERE date < $1;`
+	_, err := fl.pool.Exec(ctx, query, date)
+	return err
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type jobRun struct {
+	pool *pgxpool.Pool
+}
+
+func NewJobRun(pool *pgxpool.Pool) *jobRun {
+	return &jobRun{
+		pool: pool,
+	}
+}
+
+// Start records the run of the job slot. It reports false if the slot has already been run.
+func (jr *jobRun) Start(ctx context.Context, job string, slot time.Time) (bool, error) {
+	query := `INSERT INTO job_runs(job, slot) VALUES ($1, $2) ON CONFLICT (job, slot) DO NOTHING;`
+	rows, err := jr.pool.Exec(ctx, query, job, slot)
+	if err != nil {
+		return false, err
+	}
+
+	return rows.RowsAffected() == 1, nil
+}
+
+func (jr *jobRun) Finish(ctx context.Context, job string, slot time.Time, runErr error) error {
+	var lastError *string
+	if runErr != nil {
+		text := runErr.Error()
+		lastError = &text
+	}
+
+	query := `UPDATE job_runs SET finished_at = now(), error = $1 WHERE job = $2 AND slot = $3;`
+	_, err := jr.pool.Exec(ctx, query, lastError, job, slot)
+	return err
+}
+
+// LastSlot returns the latest started slot of the job or zero time if the job has never run.
+func (jr *jobRun) LastSlot(ctx context.Context, job string) (time.Time, error) {
+	query := `SELECT coalesce(max(slot), 'epoch'::timestamptz) FROM job_runs WHERE job = $1;`
+
+	var slot time.Time
+	if err := jr.pool.QueryRow(ctx, query, job).Scan(&slot); err != nil {
+		return time.Time{}, err
+	}
+
+	if slot.Unix() == 0 {
+		return time.Time{}, nil
+	}
+
+	return slot, nil
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type leader struct {
+	pool *pgxpool.Pool
+	key  int64
+
+	conn *pgxpool.Conn
+	mu   sync.Mutex
+}
+
+// NewLeader elects a leader among instances sharing the database by the advisory lock key.
+func NewLeader(pool *pgxpool.Pool, key int64) *leader {
+	return &leader{
+		pool: pool,
+		key:  key,
+	}
+}
+
+// IsLeader reports whether the instance holds the lock, trying to take it if nobody does.
+// The lock lives on a dedicated connection, so it is released as soon as the instance dies.
+func (l *leader) IsLeader(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		if err := l.conn.Ping(ctx); err == nil {
+			return true, nil
+		}
+
+		// The connection is broken, so the lock is lost
+		l.conn.Conn().Close(ctx)
+		l.conn.Release()
+		l.conn = nil
+	}
+
+	conn, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1);`, l.key).Scan(&locked); err != nil || !locked {
+		conn.Release()
+		return false, err
+	}
+
+	l.conn = conn
+
+	return true, nil
+}
+
+// Close gives up leadership.
+func (l *leader) Close(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+
+	_, err := l.conn.Exec(ctx, `SELECT pg_advisory_unlock($1);`, l.key)
+	l.conn.Release()
+	l.conn = nil
+
+	return err
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"pgtk-schedule/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type lessonSnapshot struct {
+	pool *pgxpool.Pool
+}
+
+func NewLessonSnapshot(pool *pgxpool.Pool) *lessonSnapshot {
+	return &lessonSnapshot{
+		pool: pool,
+	}
+}
+
+func (ls *lessonSnapshot) Save(ctx context.Context, snapshot models.LessonSnapshot) error {
+	query := `INSERT INTO lesson_snapshots(student_id, stream, substream, date, lessons) VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (student_id, stream, substream, date) DO UPDATE SET lessons = excluded.lessons, updated_at = now();`
+	_, err := ls.pool.Exec(ctx, query, snapshot.StudentID, snapshot.Stream, snapshot.Substream, snapshot.Date, snapshot.Lessons)
+	return err
+}
+
+// FindByDates returns snapshots between dates inclusive of active students without paused notifications.
+func (ls *lessonSnapshot) FindByDates(ctx context.Context, from, to time.Time) ([]models.LessonSnapshot, error) {
+	query := `SELECT ls.student_id, ls.stream, ls.substream, ls.date, ls.lessons FROM lesson_snapshots ls
+	JOIN students s ON s.id = ls.student_id
+	JOIN notify_settings ns ON ns.student_id = ls.student_id
+	WHERE ls.date BETWEEN $1 AND $2 AND s.active AND (ns.paused_until IS NULL OR ns.paused_until <= $1)
+	ORDER BY ls.student_id, ls.date;`
+	rows, err := ls.pool.Query(ctx, query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.LessonSnapshot])
+}
+
+func (ls *lessonSnapshot) DeleteBefore(ctx context.Context, date time.Time) error {
+	query := `DELETE FROM lesson_snapshots WHERE date < $1;`
+	_, err := ls.pool.Exec(ctx, query, date)
+	return err
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"pgtk-schedule/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type notificationLog struct {
+	pool *pgxpool.Pool
+}
+
+func NewNotificationLog(pool *pgxpool.Pool) *notificationLog {
+	return &notificationLog{
+		pool: pool,
+	}
+}
+
+func (nl *notificationLog) Create(ctx context.Context, entry models.NotificationLog) error {
+	query := `INSERT INTO notification_log(run_id, kind, student_id, status, outbox_id, error)
+	VALUES ($1, $2, $3, $4, $5, $6);`
+	_, err := nl.pool.Exec(ctx, query, entry.RunID, entry.Kind, entry.StudentID, entry.Status, entry.OutboxID, entry.Error)
+	return err
+}
+
+// CreateOnce creates the entry unless an entry with the same key has been created for the student.
+// It reports whether the entry is created.
+func (nl *notificationLog) CreateOnce(ctx context.Context, entry models.NotificationLog) (int64, bool, error) {
+	query := `INSERT INTO notification_log(run_id, kind, student_id, status, outbox_id, error, key)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (kind, student_id, key) WHERE key IS NOT NULL DO NOTHING
+	RETURNING id;`
+
+	var id int64
+	err := nl.pool.QueryRow(ctx, query, entry.RunID, entry.Kind, entry.StudentID, entry.Status, entry.OutboxID, entry.Error, entry.Key).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+
+	return id, true, nil
+}
+
+// AttachOutbox links the entry to the queued message.
+func (nl *notificationLog) AttachOutbox(ctx context.Context, id int64, outboxId int64) error {
+	query := `UPDATE notification_log SET outbox_id = $1, updated_at = now() WHERE id = $2;`
+	_, err := nl.pool.Exec(ctx, query, outboxId, id)
+	return err
+}
+
+// Fail records that the message of the entry has not been queued and releases the key, so it can be queued again.
+func (nl *notificationLog) Fail(ctx context.Context, id int64, lastError string) error {
+	query := `UPDATE notification_log SET status = 'failed', error = $1, key = NULL, updated_at = now() WHERE id = $2;`
+	_, err := nl.pool.Exec(ctx, query, lastError, id)
+	return err
+}
+
+// UpdateByOutboxID records the delivery result of the queued message.
+func (nl *notificationLog) UpdateByOutboxID(ctx context.Context, outboxId int64, status models.DeliveryStatus, messageId *int64, lastError *string) error {
+	query := `UPDATE notification_log SET status = $1, message_id = $2, error = $3, updated_at = now()
+	WHERE outbox_id = $4;`
+	_, err := nl.pool.Exec(ctx, query, status, messageId, lastError, outboxId)
+	return err
+}
+
+// LastRuns returns counts of outcomes of the latest run of every kind.
+func (nl *notificationLog) LastRuns(ctx context.Context) ([]models.RunReport, error) {
+	query := `WITH last AS (
+		SELECT DISTINCT ON (kind) kind, run_id FROM notification_log ORDER BY kind, created_at DESC
+	)
+	SELECT n.kind, n.run_id, n.status, count(*), min(min(n.created_at)) OVER (PARTITION BY n.run_id)
+	FROM notification_log n JOIN last l USING (kind, run_id)
+	GROUP BY n.kind, n.run_id, n.status
+	ORDER BY n.kind;`
+	rows, err := nl.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []models.RunReport
+	for rows.Next() {
+		var (
+			kind, runId string
+			status      models.DeliveryStatus
+			count       int
+			startedAt   time.Time
+		)
+		if err := rows.Scan(&kind, &runId, &status, &count, &startedAt); err != nil {
+			return nil, err
+		}
+
+		if len(reports) == 0 || reports[len(reports)-1].RunID != runId {
+			reports = append(reports, models.RunReport{RunID: runId, Kind: kind, StartedAt: startedAt, Counts: make(map[models.DeliveryStatus]int)})
+		}
+		reports[len(reports)-1].Counts[status] += count
+	}
+
+	return reports, rows.Err()
+}
@@ -3,12 +3,22 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"pgtk-schedule/internal/models"
 
+	"time"
+
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// notifyColumns maps notification kinds to column prefixes of notify_settings.
+var notifyColumns = map[models.NotifyKind]string{
+	models.NotifyMorning: "morning",
+	models.NotifyEvening: "evening",
+	models.NotifyWeek:    "week",
+}
+
 type notifySettings struct {
 	pool *pgxpool.Pool
 }
@@ -20,13 +30,21 @@ func NewNotifySettings(pool *pgxpool.Pool) *notifySettings {
 }
 
 func (ns *notifySettings) FindByStudentID(ctx context.Context, studentId int64) (models.NotifySettings, error) {
-	query := `SELECT id, morning, evening, week FROM notify_settings WHERE student_id = $1`
+	query := `SELECT id, morning, evening, week, morning_time, morning_days,
+	evening_time, evening_days, week_time, week_days, reminder, reminder_lead, paused_until
+	FROM notify_settings WHERE student_id = $1`
 	row := ns.pool.QueryRow(ctx, query, studentId)
 	notifySettings := models.NotifySettings{
 		StudentID: studentId,
 	}
 
-	err := row.Scan(&notifySettings.ID, &notifySettings.Morning, &notifySettings.Evening, &notifySettings.Week)
+	err := row.Scan(
+		&notifySettings.ID, &notifySettings.Morning, &notifySettings.Evening, &notifySettings.Week,
+		&notifySettings.MorningTime, &notifySettings.MorningDays,
+		&notifySettings.EveningTime, &notifySettings.EveningDays,
+		&notifySettings.WeekTime, &notifySettings.WeekDays,
+		&notifySettings.Reminder, &notifySettings.ReminderLead, &notifySettings.PausedUntil,
+	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return models.NotifySettings{}, models.ErrNotifySettingsNotFound
@@ -77,3 +95,117 @@ func (ns *notifySettings) ToggleWeek(ctx context.Context, studentId int64) error
 
 	return nil
 }
+
+// ShiftTime moves notification time by delta minutes wrapping around midnight.
+func (ns *notifySettings) ShiftTime(ctx context.Context, studentId int64, kind models.NotifyKind, delta int) error {
+	column, ok := notifyColumns[kind]
+	if !ok {
+		return models.ErrNotifyKindUnknown
+	}
+
+	query := fmt.Sprintf(`UPDATE notify_settings SET %[1]s_time = ((%[1]s_time + $1) %% 1440 + 1440) %% 1440
+	WHERE student_id = $2`, column)
+	rows, err := ns.pool.Exec(ctx, query, delta, studentId)
+	if err != nil {
+		return err
+	}
+
+	if rows.RowsAffected() != 1 {
+		return models.ErrNotifySettingsNotFound
+	}
+
+	return nil
+}
+
+func (ns *notifySettings) ToggleDay(ctx context.Context, studentId int64, kind models.NotifyKind, weekday time.Weekday) error {
+	column, ok := notifyColumns[kind]
+	if !ok {
+		return models.ErrNotifyKindUnknown
+	}
+
+	query := fmt.Sprintf(`UPDATE notify_settings SET %[1]s_days = %[1]s_days # $1 WHERE student_id = $2`, column)
+	rows, err := ns.pool.Exec(ctx, query, int16(1)<<weekday, studentId)
+	if err != nil {
+		return err
+	}
+
+	if rows.RowsAffected() != 1 {
+		return models.ErrNotifySettingsNotFound
+	}
+
+	return nil
+}
+
+func (ns *notifySettings) ToggleReminder(ctx context.Context, studentId int64) error {
+	query := `UPDATE notify_settings SET reminder = NOT reminder WHERE student_id = $1`
+	rows, err := ns.pool.Exec(ctx, query, studentId)
+	if err != nil {
+		return err
+	}
+
+	if rows.RowsAffected() != 1 {
+		return models.ErrNotifySettingsNotFound
+	}
+
+	return nil
+}
+
+func (ns *notifySettings) UpdateReminderLead(ctx context.Context, studentId int64, lead int) error {
+	query := `UPDATE notify_settings SET reminder_lead = $1 WHERE student_id = $2`
+	rows, err := ns.pool.Exec(ctx, query, lead, studentId)
+	if err != nil {
+		return err
+	}
+
+	if rows.RowsAffected() != 1 {
+		return models.ErrNotifySettingsNotFound
+	}
+
+	return nil
+}
+
+// UpdatePausedUntil pauses notifications until the date. Nil date resumes them.
+func (ns *notifySettings) UpdatePausedUntil(ctx context.Context, studentId int64, date *time.Time) error {
+	query := `UPDATE notify_settings SET paused_until = $1 WHERE student_id = $2`
+	rows, err := ns.pool.Exec(ctx, query, date, studentId)
+	if err != nil {
+		return err
+	}
+
+	if rows.RowsAffected() != 1 {
+		return models.ErrNotifySettingsNotFound
+	}
+
+	return nil
+}
+
+// ResumeExpired clears pauses ending on the date or earlier and returns affected students.
+func (ns *notifySettings) ResumeExpired(ctx context.Context, date time.Time) ([]int64, error) {
+	query := `UPDATE notify_settings SET paused_until = NULL
+	WHERE paused_until <= $1 RETURNING student_id`
+	rows, err := ns.pool.Query(ctx, query, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowTo[int64])
+}
+
+// ResumeDue clears pauses ending on the date or earlier of students with a notification scheduled
+// on the date at the minute or earlier and returns affected students.
+func (ns *notifySettings) ResumeDue(ctx context.Context, date time.Time, minute int) ([]int64, error) {
+	query := `UPDATE notify_settings SET paused_until = NULL
+	WHERE paused_until <= $1 AND (
+		(morning AND morning_time <= $2 AND morning_days & $3 <> 0) OR
+		(evening AND evening_time <= $2 AND evening_days & $3 <> 0) OR
+		(week AND week_time <= $2 AND week_days & $3 <> 0)
+	) RETURNING student_id`
+	rows, err := ns.pool.Query(ctx, query, date, minute, int16(1)<<date.Weekday())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowTo[int64])
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"pgtk-schedule/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type outbox struct {
+	pool *pgxpool.Pool
+}
+
+func NewOutbox(pool *pgxpool.Pool) *outbox {
+	return &outbox{
+		pool: pool,
+	}
+}
+
+// Create queues the message. The message is a new one unless EditMessageID is set.
+func (o *outbox) Create(ctx context.Context, message models.OutboxMessage) (int64, error) {
+	query := `INSERT INTO outbox(chat_id, kind, text, edit_message_id, pin) VALUES ($1, $2, $3, $4, $5) RETURNING id;`
+
+	var id int64
+	err := o.pool.QueryRow(ctx, query, message.ChatID, message.Kind, message.Text, message.EditMessageID, message.Pin).Scan(&id)
+	return id, err
+}
+
+// Claim leases due pending messages, so a crashed sender does not lose them:
+// they become due again once the lease expires.
+func (o *outbox) Claim(ctx context.Context, lease time.Duration, limit int) ([]models.OutboxMessage, error) {
+	query := `UPDATE outbox SET attempts = attempts + 1, next_attempt_at = now() + $1::int * interval '1 second'
+	WHERE id IN (
+		SELECT id FROM outbox WHERE status = 'pending' AND next_attempt_at <= now()
+		ORDER BY next_attempt_at, id LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, chat_id, kind, text, status, attempts, next_attempt_at, last_error, message_id, edit_message_id, pin, created_at, sent_at;`
+	rows, err := o.pool.Query(ctx, query, int(lease.Seconds()), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.OutboxMessage])
+}
+
+// Renew extends the lease of the claimed message right before it is sent. It reports false when the message
+// is no longer pending or has been claimed again, e.g. by another instance after the lease expired.
+func (o *outbox) Renew(ctx context.Context, id int64, attempts int, lease time.Duration) (bool, error) {
+	query := `UPDATE outbox SET next_attempt_at = now() + $1::int * interval '1 second'
+	WHERE id = $2 AND status = 'pending' AND attempts = $3;`
+	tag, err := o.pool.Exec(ctx, query, int(lease.Seconds()), id, attempts)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+func (o *outbox) MarkSent(ctx context.Context, id int64, messageId int64) error {
+	query := `UPDATE outbox SET status = 'sent', message_id = $1, last_error = NULL, sent_at = now() WHERE id = $2;`
+	_, err := o.pool.Exec(ctx, query, messageId, id)
+	return err
+}
+
+func (o *outbox) MarkRetry(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error {
+	query := `UPDATE outbox SET next_attempt_at = $1, last_error = $2 WHERE id = $3;`
+	_, err := o.pool.Exec(ctx, query, nextAttemptAt, lastError, id)
+	return err
+}
+
+func (o *outbox) MarkFailed(ctx context.Context, id int64, lastError string) error {
+	query := `UPDATE outbox SET status = 'failed', last_error = $1 WHERE id = $2;`
+	_, err := o.pool.Exec(ctx, query, lastError, id)
+	return err
+}
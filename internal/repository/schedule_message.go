@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"pgtk-schedule/internal/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type scheduleMessage struct {
+	pool *pgxpool.Pool
+}
+
+func NewScheduleMessage(pool *pgxpool.Pool) *scheduleMessage {
+	return &scheduleMessage{
+		pool: pool,
+	}
+}
+
+func (sm *scheduleMessage) Create(ctx context.Context, message models.ScheduleMessage) error {
+	query := `INSERT INTO schedule_messages(chat_id, outbox_id, message_id, stream, substream, header, date_from, date_to, lessons)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);`
+	_, err := sm.pool.Exec(ctx, query, message.ChatID, message.OutboxID, message.MessageID, message.Stream, message.Substream, message.Header, message.DateFrom, message.DateTo, message.Lessons)
+	return err
+}
+
+// FindSince returns delivered messages with lessons of the date or later.
+// Message identifiers of queued messages are taken from the outbox once they are sent.
+func (sm *scheduleMessage) FindSince(ctx context.Context, date time.Time) ([]models.ScheduleMessage, error) {
+	query := `SELECT sm.id, sm.chat_id, sm.outbox_id, COALESCE(sm.message_id, o.message_id) AS message_id, sm.stream, sm.substream, sm.header, sm.date_from, sm.date_to, sm.lessons
+	FROM schedule_messages sm
+	LEFT JOIN outbox o ON o.id = sm.outbox_id
+	WHERE sm.date_to >= $1 AND COALESCE(sm.message_id, o.message_id) IS NOT NULL
+	ORDER BY sm.id;`
+	rows, err := sm.pool.Query(ctx, query, date)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.ScheduleMessage])
+}
+
+func (sm *scheduleMessage) UpdateLessons(ctx context.Context, id int64, lessons []models.Lesson) error {
+	query := `UPDATE schedule_messages SET lessons = $1, updated_at = now() WHERE id = $2;`
+	_, err := sm.pool.Exec(ctx, query, lessons, id)
+	return err
+}
+
+func (sm *scheduleMessage) DeleteBefore(ctx context.Context, date time.Time) error {
+	query := `DELETE FROM schedule_messages WHERE date_to < $1;`
+	_, err := sm.pool.Exec(ctx, query, date)
+	return err
+}
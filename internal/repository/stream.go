@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"pgtk-schedule/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type stream struct {
+	pool *pgxpool.Pool
+}
+
+func NewStream(pool *pgxpool.Pool) *stream {
+	return &stream{
+		pool: pool,
+	}
+}
+
+// Save remembers names and substreams of streams from the portal.
+func (s *stream) Save(ctx context.Context, streams []models.Stream) error {
+	batch := &pgx.Batch{}
+	for _, stream := range streams {
+		substreams := stream.Substreams
+		if substreams == nil {
+			substreams = []string{}
+		}
+
+		batch.Queue(`INSERT INTO streams(id, name, substreams) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, substreams = excluded.substreams, seen_at = now();`,
+			stream.ID, stream.Name, substreams)
+	}
+
+	return s.pool.SendBatch(ctx, batch).Close()
+}
+
+// FindStale returns streams selected by students, subscriptions or group chats which are not among current ones.
+// Students are counted once per stream whether it is their group or a subscription.
+// Streams never seen on the portal are skipped, because their names are unknown.
+func (s *stream) FindStale(ctx context.Context, current []string) ([]models.StaleStream, error) {
+	query := `WITH members AS (
+		SELECT stream, id AS student_id FROM students WHERE role = 'student' AND stream IS NOT NULL
+		UNION
+		SELECT stream, student_id FROM subscriptions
+	), used AS (
+		SELECT stream FROM members
+		UNION
+		SELECT stream FROM chats WHERE stream IS NOT NULL
+	)
+	SELECT st.id, st.name, st.substreams, count(m.student_id) FROM streams st
+	JOIN used u ON u.stream = st.id
+	LEFT JOIN members m ON m.stream = st.id
+	WHERE NOT (st.id = ANY($1))
+	GROUP BY st.id ORDER BY st.name;`
+	rows, err := s.pool.Query(ctx, query, current)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.StaleStream, error) {
+		var stale models.StaleStream
+		err := row.Scan(&stale.ID, &stale.Name, &stale.Substreams, &stale.Students)
+		return stale, err
+	})
+}
+
+// Rollover moves students and their subscriptions to new streams and records every mapping for audit.
+// Substreams are kept, students whose substream is missing in the new stream are left marked as missing.
+// Mappings without a new stream are skipped. It returns the number of moved students.
+func (s *stream) Rollover(ctx context.Context, mappings []models.StreamMapping) (int64, error) {
+	var moved int64
+
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		for _, mapping := range mappings {
+			if mapping.New == nil {
+				continue
+			}
+
+			oldId, newId := mapping.Old.ID, mapping.New.ID
+
+			query := `UPDATE students SET stream = $1,
+			stream_missing_at = CASE WHEN COALESCE(substream, '') = '' OR substream = ANY($3) THEN NULL ELSE stream_missing_at END
+			WHERE stream = $2 AND role = 'student';`
+			tag, err := tx.Exec(ctx, query, newId, oldId, mapping.New.Substreams)
+			if err != nil {
+				return err
+			}
+			moved += tag.RowsAffected()
+
+			// Subscriptions already present for the new stream are kept instead of moved ones
+			query = `UPDATE subscriptions sub SET stream = $1 WHERE sub.stream = $2 AND NOT EXISTS (
+				SELECT 1 FROM subscriptions o WHERE o.student_id = sub.student_id AND o.stream = $1 AND o.substream = sub.substream
+			);`
+			if _, err := tx.Exec(ctx, query, newId, oldId); err != nil {
+				return err
+			}
+
+			if _, err := tx.Exec(ctx, `DELETE FROM subscriptions WHERE stream = $1;`, oldId); err != nil {
+				return err
+			}
+
+			// Chats keep the substream only when the new stream has it
+			query = `UPDATE chats SET stream = $1,
+			substream = CASE WHEN substream = ANY($3) THEN substream ELSE NULL END
+			WHERE stream = $2;`
+			if _, err := tx.Exec(ctx, query, newId, oldId, mapping.New.Substreams); err != nil {
+				return err
+			}
+
+			query = `INSERT INTO stream_rollovers(old_stream, old_name, new_stream, new_name, students) VALUES ($1, $2, $3, $4, $5);`
+			if _, err := tx.Exec(ctx, query, oldId, mapping.Old.Name, newId, mapping.New.Name, tag.RowsAffected()); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return moved, nil
+}
@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"pgtk-schedule/internal/models"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -26,13 +28,14 @@ func (s *student) Create(ctx context.Context, id int64, nickname string) error {
 }
 
 func (s *student) FindByID(ctx context.Context, id int64) (models.Student, error) {
-	query := `SELECT nickname, stream, substream FROM students WHERE id = $1;`
+	query := `SELECT nickname, stream, substream, role, teacher, active, stream_missing_at IS NOT NULL, onboarding_step
+	FROM students WHERE id = $1;`
 	row := s.pool.QueryRow(ctx, query, id)
 	student := models.Student{
 		ID: id,
 	}
 
-	err := row.Scan(&student.Nickname, &student.Stream, &student.Substream)
+	err := row.Scan(&student.Nickname, &student.Stream, &student.Substream, &student.Role, &student.Teacher, &student.Active, &student.StreamMissing, &student.OnboardingStep)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return student, models.ErrStudentNotFound
@@ -44,9 +47,78 @@ func (s *student) FindByID(ctx context.Context, id int64) (models.Student, error
 	return student, nil
 }
 
+// FindAll returns active students.
 func (s *student) FindAll(ctx context.Context, id int64, limit int) ([]models.Student, int64, error) {
-	query := `SELECT id, nickname, stream, substream FROM students
-	WHERE id > $1 ORDER BY id LIMIT $2`
+	query := `SELECT id, nickname, stream, substream, role, teacher, active,
+	stream_missing_at IS NOT NULL AS stream_missing, onboarding_step FROM students
+	WHERE active AND id > $1 ORDER BY id LIMIT $2`
+	rows, err := s.pool.Query(ctx, query, id, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	students, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Student])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	lastSeenID := id
+	if len(students) > 0 {
+		lastSeenID = students[len(students)-1].ID
+	}
+
+	return students, lastSeenID, nil
+}
+
+// FindAllDue returns students with enabled notification of the kind scheduled at minute of the date.
+// Students whose stream has disappeared from the portal are skipped.
+func (s *student) FindAllDue(ctx context.Context, kind models.NotifyKind, minute int, date time.Time, id int64, limit int) ([]models.Student, int64, error) {
+	return s.findAllScheduled(ctx, kind, `ns.%[1]s AND (ns.paused_until IS NULL OR ns.paused_until <= $3) AND s.active AND s.stream_missing_at IS NULL`, minute, date, id, limit)
+}
+
+// FindAllSkipped returns students whose notification of the kind is scheduled at minute of the date,
+// but is disabled, paused or cannot be delivered.
+func (s *student) FindAllSkipped(ctx context.Context, kind models.NotifyKind, minute int, date time.Time, id int64, limit int) ([]models.Student, int64, error) {
+	return s.findAllScheduled(ctx, kind, `NOT (ns.%[1]s AND (ns.paused_until IS NULL OR ns.paused_until <= $3) AND s.active AND s.stream_missing_at IS NULL)`, minute, date, id, limit)
+}
+
+func (s *student) findAllScheduled(ctx context.Context, kind models.NotifyKind, condition string, minute int, date time.Time, id int64, limit int) ([]models.Student, int64, error) {
+	column, ok := notifyColumns[kind]
+	if !ok {
+		return nil, 0, models.ErrNotifyKindUnknown
+	}
+
+	query := fmt.Sprintf(`SELECT s.id, s.nickname, s.stream, s.substream, s.role, s.teacher, s.active,
+	s.stream_missing_at IS NOT NULL AS stream_missing, s.onboarding_step FROM students s
+	JOIN notify_settings ns ON ns.student_id = s.id
+	WHERE ns.%[1]s_time = $1 AND ns.%[1]s_days & $2 <> 0 AND `+condition+` AND s.id > $4
+	ORDER BY s.id LIMIT $5`, column)
+	rows, err := s.pool.Query(ctx, query, minute, int16(1)<<date.Weekday(), models.Date(date), id, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	students, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Student])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	lastSeenID := id
+	if len(students) > 0 {
+		lastSeenID = students[len(students)-1].ID
+	}
+
+	return students, lastSeenID, nil
+}
+
+func (s *student) FindAllWithReminder(ctx context.Context, id int64, limit int) ([]models.Student, int64, error) {
+	query := `SELECT s.id, s.nickname, s.stream, s.substream, s.role, s.teacher, s.active,
+	s.stream_missing_at IS NOT NULL AS stream_missing, s.onboarding_step FROM students s
+	JOIN notify_settings ns ON ns.student_id = s.id
+	WHERE ns.reminder AND s.active AND s.stream_missing_at IS NULL AND s.id > $1
+	ORDER BY s.id LIMIT $2`
 	rows, err := s.pool.Query(ctx, query, id, limit)
 	if err != nil {
 		return nil, 0, err
@@ -67,7 +139,7 @@ func (s *student) FindAll(ctx context.Context, id int64, limit int) ([]models.St
 }
 
 func (s *student) UpdateStream(ctx context.Context, id int64, stream string) error {
-	query := `UPDATE students SET stream = $1 WHERE id = $2;`
+	query := `UPDATE students SET stream = $1, role = 'student', stream_missing_at = NULL WHERE id = $2;`
 	rows, err := s.pool.Exec(ctx, query, stream, id)
 	if err != nil {
 		return err
@@ -81,7 +153,7 @@ func (s *student) UpdateStream(ctx context.Context, id int64, stream string) err
 }
 
 func (s *student) UpdateSubstream(ctx context.Context, id int64, substream string) error {
-	query := `UPDATE students SET substream = $1 WHERE id = $2;`
+	query := `UPDATE students SET substream = $1, stream_missing_at = NULL WHERE id = $2;`
 	rows, err := s.pool.Exec(ctx, query, substream, id)
 	if err != nil {
 		return err
@@ -107,3 +179,108 @@ func (s *student) UpdateNickname(ctx context.Context, id int64, nickname string)
 
 	return nil
 }
+
+func (s *student) UpdateGroup(ctx context.Context, id int64, stream, substream string) error {
+	query := `UPDATE students SET stream = $1, substream = $2, role = 'student', stream_missing_at = NULL WHERE id = $3;`
+	rows, err := s.pool.Exec(ctx, query, stream, substream, id)
+	if err != nil {
+		return err
+	}
+
+	if rows.RowsAffected() != 1 {
+		return models.ErrStudentNotFound
+	}
+
+	return nil
+}
+
+func (s *student) UpdateTeacher(ctx context.Context, id int64, teacher string) error {
+	query := `UPDATE students SET teacher = $1, role = 'teacher', stream_missing_at = NULL WHERE id = $2;`
+	rows, err := s.pool.Exec(ctx, query, teacher, id)
+	if err != nil {
+		return err
+	}
+
+	if rows.RowsAffected() != 1 {
+		return models.ErrStudentNotFound
+	}
+
+	return nil
+}
+
+// Deactivate marks the student unreachable, so jobs stop messaging them.
+func (s *student) Deactivate(ctx context.Context, id int64) error {
+	query := `UPDATE students SET active = false, deactivated_at = now() WHERE id = $1 AND active;`
+	_, err := s.pool.Exec(ctx, query, id)
+	return err
+}
+
+func (s *student) Activate(ctx context.Context, id int64) error {
+	query := `UPDATE students SET active = true, deactivated_at = NULL WHERE id = $1;`
+	rows, err := s.pool.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	if rows.RowsAffected() != 1 {
+		return models.ErrStudentNotFound
+	}
+
+	return nil
+}
+
+// UpdateOnboardingStep saves the step of the onboarding, an empty step finishes it.
+func (s *student) UpdateOnboardingStep(ctx context.Context, id int64, step string) error {
+	query := `UPDATE students SET onboarding_step = NULLIF($1, '') WHERE id = $2;`
+	rows, err := s.pool.Exec(ctx, query, step, id)
+	if err != nil {
+		return err
+	}
+
+	if rows.RowsAffected() != 1 {
+		return models.ErrStudentNotFound
+	}
+
+	return nil
+}
+
+// MarkMissingStreams marks students whose stream or substream has not been among streams for absences refreshes
+// in a row and returns active students marked for the first time. Students whose group is found again are unmarked.
+// Substreams of a stream that failed to load are all considered found.
+func (s *student) MarkMissingStreams(ctx context.Context, streams []models.Stream, absences int) ([]int64, error) {
+	ids := make([]string, 0, len(streams))
+	unknown := make([]string, 0)
+	groups := make([]string, 0, len(streams))
+	for _, stream := range streams {
+		ids = append(ids, stream.ID)
+		if stream.SubstreamsUnknown {
+			unknown = append(unknown, stream.ID)
+		}
+		for _, substream := range stream.Substreams {
+			groups = append(groups, stream.ID+"\x1f"+substream)
+		}
+	}
+
+	// An empty substream stands for lessons common for all substreams
+	const found = `stream = ANY($1) AND (COALESCE(substream, '') = '' OR stream = ANY($2) OR stream || chr(31) || substream = ANY($3))`
+
+	query := `UPDATE students SET stream_missing_at = NULL, stream_absences = 0
+	WHERE (stream_missing_at IS NOT NULL OR stream_absences > 0) AND ` + found
+	if _, err := s.pool.Exec(ctx, query, ids, unknown, groups); err != nil {
+		return nil, err
+	}
+
+	query = `WITH marked AS (
+		UPDATE students SET stream_absences = stream_absences + 1,
+			stream_missing_at = CASE WHEN stream_absences + 1 >= $4 THEN now() END
+		WHERE stream_missing_at IS NULL AND role = 'student' AND stream IS NOT NULL AND NOT (` + found + `)
+		RETURNING id, active, stream_missing_at IS NOT NULL AS missing
+	)
+	SELECT id FROM marked WHERE active AND missing ORDER BY id`
+	rows, err := s.pool.Query(ctx, query, ids, unknown, groups, absences)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[int64])
+}
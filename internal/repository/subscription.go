@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"pgtk-schedule/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type subscription struct {
+	pool *pgxpool.Pool
+}
+
+func NewSubscription(pool *pgxpool.Pool) *subscription {
+	return &subscription{
+		pool: pool,
+	}
+}
+
+func (s *subscription) Create(ctx context.Context, studentId int64, stream, substream, label string) error {
+	query := `INSERT INTO subscriptions(student_id, stream, substream, label) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (student_id, stream, substream) DO NOTHING;`
+	_, err := s.pool.Exec(ctx, query, studentId, stream, substream, label)
+	return err
+}
+
+// Replace subscribes the student to the group instead of the previous one, so notifications of the previous group stop.
+// An empty previous stream only adds the subscription.
+func (s *subscription) Replace(ctx context.Context, studentId int64, previousStream, previousSubstream, stream, substream, label string) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if previousStream != "" {
+			query := `DELETE FROM subscriptions WHERE student_id = $1 AND stream = $2 AND substream = $3
+			AND NOT (stream = $4 AND substream = $5);`
+			if _, err := tx.Exec(ctx, query, studentId, previousStream, previousSubstream, stream, substream); err != nil {
+				return err
+			}
+		}
+
+		query := `INSERT INTO subscriptions(student_id, stream, substream, label) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (student_id, stream, substream) DO NOTHING;`
+		_, err := tx.Exec(ctx, query, studentId, stream, substream, label)
+		return err
+	})
+}
+
+func (s *subscription) FindByID(ctx context.Context, studentId, id int64) (models.Subscription, error) {
+	query := `SELECT id, student_id, stream, substream, label, notify FROM subscriptions
+	WHERE id = $1 AND student_id = $2;`
+	rows, err := s.pool.Query(ctx, query, id, studentId)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+	defer rows.Close()
+
+	subscription, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[models.Subscription])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Subscription{}, models.ErrSubscriptionNotFound
+		}
+		return models.Subscription{}, err
+	}
+
+	return subscription, nil
+}
+
+func (s *subscription) FindByStudentID(ctx context.Context, studentId int64) ([]models.Subscription, error) {
+	query := `SELECT id, student_id, stream, substream, label, notify FROM subscriptions
+	WHERE student_id = $1 ORDER BY id;`
+	rows, err := s.pool.Query(ctx, query, studentId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.Subscription])
+}
+
+func (s *subscription) ToggleNotify(ctx context.Context, studentId, id int64) error {
+	query := `UPDATE subscriptions SET notify = NOT notify WHERE id = $1 AND student_id = $2;`
+	rows, err := s.pool.Exec(ctx, query, id, studentId)
+	if err != nil {
+		return err
+	}
+
+	if rows.RowsAffected() != 1 {
+		return models.ErrSubscriptionNotFound
+	}
+
+	return nil
+}
+
+func (s *subscription) Delete(ctx context.Context, studentId, id int64) error {
+	query := `DELETE FROM subscriptions WHERE id = $1 AND student_id = $2;`
+	rows, err := s.pool.Exec(ctx, query, id, studentId)
+	if err != nil {
+		return err
+	}
+
+	if rows.RowsAffected() != 1 {
+		return models.ErrSubscriptionNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"pgtk-schedule/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type teacher struct {
+	pool *pgxpool.Pool
+}
+
+func NewTeacher(pool *pgxpool.Pool) *teacher {
+	return &teacher{
+		pool: pool,
+	}
+}
+
+// CreateMany stores new teachers. Already known teachers keep their identifiers.
+func (t *teacher) CreateMany(ctx context.Context, names []string) error {
+	query := `INSERT INTO teachers(name) SELECT unnest($1::text[])
+	ON CONFLICT (name) DO NOTHING;`
+	_, err := t.pool.Exec(ctx, query, names)
+	return err
+}
+
+func (t *teacher) FindAll(ctx context.Context) ([]models.Teacher, error) {
+	query := `SELECT id, name FROM teachers ORDER BY id;`
+	rows, err := t.pool.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.Teacher])
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"pgtk-schedule/internal/models"
+	"slices"
+	"time"
+)
+
+// snapshotRetention is how long sent lessons are kept after their date.
+const snapshotRetention = 7 * 24 * time.Hour
+
+type lessonSnapshotRepository interface {
+	Save(ctx context.Context, snapshot models.LessonSnapshot) error
+	FindByDates(ctx context.Context, from, to time.Time) ([]models.LessonSnapshot, error)
+	DeleteBefore(ctx context.Context, date time.Time) error
+}
+
+type firstLessonRepository interface {
+	Save(ctx context.Context, first models.FirstLesson) error
+	FindNotAlerted(ctx context.Context, date time.Time) ([]models.FirstLesson, error)
+	MarkAlerted(ctx context.Context, first models.FirstLesson) error
+	DeleteBefore(ctx context.Context, date time.Time) error
+}
+
+type changeSchedule interface {
+	DateLessons(stream, substream string, date time.Time) ([]models.Lesson, error)
+}
+
+type change struct {
+	repo            lessonSnapshotRepository
+	firstLessonRepo firstLessonRepository
+	schedule        changeSchedule
+}
+
+func NewChange(repo lessonSnapshotRepository, firstLessonRepo firstLessonRepository, schedule changeSchedule) *change {
+	return &change{
+		repo:            repo,
+		firstLessonRepo: firstLessonRepo,
+		schedule:        schedule,
+	}
+}
+
+// Remember stores lessons sent to the student, so later changes can be detected.
+func (c *change) Remember(ctx context.Context, studentId int64, stream, substream string, lessons []models.Lesson) error {
+	byDate := make(map[time.Time][]models.Lesson)
+	for _, lesson := range lessons {
+		date := models.Date(lesson.DateStart)
+		byDate[date] = append(byDate[date], lesson)
+	}
+
+	var errs []error
+	for date, lessons := range byDate {
+		errs = append(errs, c.repo.Save(ctx, models.LessonSnapshot{
+			StudentID: studentId,
+			Stream:    stream,
+			Substream: substream,
+			Date:      date,
+			Lessons:   lessons,
+		}))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Detect compares today and tomorrow lessons with the sent ones and returns room and time changes
+// of lessons that have not started yet. Returned changes are remembered as sent.
+func (c *change) Detect(ctx context.Context, now time.Time) ([]models.ScheduleChange, error) {
+	today := models.Date(now)
+	if err := c.repo.DeleteBefore(ctx, today.Add(-snapshotRetention)); err != nil {
+		log.Println(err.Error())
+	}
+
+	snapshots, err := c.repo.FindByDates(ctx, today, today.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []models.ScheduleChange
+	for _, snapshot := range snapshots {
+		current, err := c.currentLessons(snapshot.Stream, snapshot.Substream, snapshot.Date)
+		if err != nil {
+			log.Println(err.Error())
+			continue
+		}
+
+		changes := diffLessons(snapshot.Lessons, current, now)
+		if len(changes) == 0 {
+			continue
+		}
+
+		// The snapshot is saved before the change is returned, a failed save is detected again next time
+		snapshot.Lessons = current
+		if err := c.repo.Save(ctx, snapshot); err != nil {
+			log.Println(err.Error())
+			continue
+		}
+
+		result = append(result, models.ScheduleChange{
+			StudentID: snapshot.StudentID,
+			Stream:    snapshot.Stream,
+			Substream: snapshot.Substream,
+			Date:      snapshot.Date,
+			Changes:   changes,
+		})
+	}
+
+	return result, nil
+}
+
+// currentLessons returns lessons of the date. Empty lessons and streams that are gone from the portal
+// are returned as no lessons, other errors of a single stream should not stop detection for others.
+func (c *change) currentLessons(stream, substream string, date time.Time) ([]models.Lesson, error) {
+	lessons, err := c.schedule.DateLessons(stream, substream, date)
+	if err != nil && !errors.Is(err, models.ErrLessonsAreEmpty) && !errors.Is(err, models.ErrStreamIsUnknown) {
+		return nil, err
+	}
+
+	return lessons, nil
+}
+
+// RememberFirst stores the first lesson of every date sent in the morning notification.
+func (c *change) RememberFirst(ctx context.Context, studentId int64, stream, substream string, lessons []models.Lesson) error {
+	firsts := make(map[time.Time]models.Lesson)
+	for _, lesson := range lessons {
+		date := models.Date(lesson.DateStart)
+		if first, ok := firsts[date]; !ok || lesson.DateStart.Before(first.DateStart) {
+			firsts[date] = lesson
+		}
+	}
+
+	var errs []error
+	for date, lesson := range firsts {
+		errs = append(errs, c.firstLessonRepo.Save(ctx, models.FirstLesson{
+			StudentID: studentId,
+			Stream:    stream,
+			Substream: substream,
+			Date:      date,
+			Lesson:    lesson,
+		}))
+	}
+
+	return errors.Join(errs...)
+}
+
+// DetectFirstCancel returns today first lessons from the morning notification that are cancelled
+// before they start. Every student is returned once a day.
+func (c *change) DetectFirstCancel(ctx context.Context, now time.Time) ([]models.FirstLessonCancel, error) {
+	today := models.Date(now)
+	if err := c.firstLessonRepo.DeleteBefore(ctx, today); err != nil {
+		log.Println(err.Error())
+	}
+
+	firsts, err := c.firstLessonRepo.FindNotAlerted(ctx, today)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []models.FirstLessonCancel
+	for _, first := range firsts {
+		// An empty day is a cancelled day, but a stream gone from the portal tells nothing about lessons
+		current, err := c.schedule.DateLessons(first.Stream, first.Substream, first.Date)
+		if err != nil && !errors.Is(err, models.ErrLessonsAreEmpty) {
+			if !errors.Is(err, models.ErrStreamIsUnknown) {
+				log.Println(err.Error())
+			}
+			continue
+		}
+
+		after, cancelled := firstCancelled(first.Lesson, current, now)
+		if !cancelled {
+			continue
+		}
+
+		if err := c.firstLessonRepo.MarkAlerted(ctx, first); err != nil {
+			log.Println(err.Error())
+			continue
+		}
+
+		result = append(result, models.FirstLessonCancel{
+			StudentID: first.StudentID,
+			Stream:    first.Stream,
+			Substream: first.Substream,
+			Date:      first.Date,
+			Before:    first.Lesson,
+			After:     after,
+		})
+	}
+
+	return result, nil
+}
+
+// firstCancelled reports whether the first lesson that has not started yet is gone and the day now starts later.
+// It returns the new first lesson, nil when no lessons are left. A first lesson found by identifier or by name,
+// type and teacher has been moved rather than cancelled, Detect alerts about it.
+func firstCancelled(first models.Lesson, current []models.Lesson, now time.Time) (*models.Lesson, bool) {
+	if !first.DateStart.After(now) {
+		return nil, false
+	}
+
+	for _, lesson := range current {
+		if lesson.ID == first.ID || sameLesson(lesson, first) {
+			return nil, false
+		}
+	}
+
+	var after *models.Lesson
+	for i, lesson := range current {
+		if after == nil || lesson.DateStart.Before(after.DateStart) {
+			after = &current[i]
+		}
+	}
+
+	if after != nil && !after.DateStart.After(first.DateStart) {
+		return nil, false
+	}
+
+	return after, true
+}
+
+// diffLessons returns lessons that got another room or time. Lessons are matched by identifier,
+// then by name, type and teacher, because the portal may recreate a moved lesson.
+func diffLessons(before, after []models.Lesson, now time.Time) []models.LessonChange {
+	matched := make([]bool, len(after))
+	match := func(same func(a models.Lesson) bool) (models.Lesson, bool) {
+		for i, a := range after {
+			if !matched[i] && same(a) {
+				matched[i] = true
+				return a, true
+			}
+		}
+		return models.Lesson{}, false
+	}
+
+	var changes []models.LessonChange
+	var unmatched []models.Lesson
+	for _, b := range before {
+		a, ok := match(func(a models.Lesson) bool { return a.ID == b.ID })
+		if !ok {
+			unmatched = append(unmatched, b)
+			continue
+		}
+
+		if changed(b, a, now) {
+			changes = append(changes, models.LessonChange{Before: b, After: a})
+		}
+	}
+
+	for _, b := range unmatched {
+		a, ok := match(func(a models.Lesson) bool { return sameLesson(a, b) })
+		if ok && changed(b, a, now) {
+			changes = append(changes, models.LessonChange{Before: b, After: a})
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b models.LessonChange) int {
+		return a.After.DateStart.Compare(b.After.DateStart)
+	})
+
+	return changes
+}
+
+// sameLesson reports whether two lessons have the same name, type and teacher.
+func sameLesson(a, b models.Lesson) bool {
+	return a.Name == b.Name && a.Type == b.Type && a.Teacher == b.Teacher
+}
+
+// changed reports whether a lesson that has not started yet got another room or time.
+func changed(before, after models.Lesson, now time.Time) bool {
+	if !before.DateStart.After(now) || !after.DateStart.After(now) {
+		return false
+	}
+
+	return before.Cabinet != after.Cabinet || !before.DateStart.Equal(after.DateStart) || !before.DateEnd.Equal(after.DateEnd)
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"pgtk-schedule/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSnapshots struct {
+	snapshots []models.LessonSnapshot
+	saved     []int64
+}
+
+func (r *fakeSnapshots) Save(ctx context.Context, snapshot models.LessonSnapshot) error {
+	r.saved = append(r.saved, snapshot.StudentID)
+	return nil
+}
+
+func (r *fakeSnapshots) FindByDates(ctx context.Context, from, to time.Time) ([]models.LessonSnapshot, error) {
+	return r.snapshots, nil
+}
+
+func (r *fakeSnapshots) DeleteBefore(ctx context.Context, date time.Time) error {
+	return nil
+}
+
+type fakeFirstLessons struct {
+	firsts  []models.FirstLesson
+	alerted []int64
+}
+
+func (r *fakeFirstLessons) Save(ctx context.Context, first models.FirstLesson) error {
+	return nil
+}
+
+func (r *fakeFirstLessons) FindNotAlerted(ctx context.Context, date time.Time) ([]models.FirstLesson, error) {
+	return r.firsts, nil
+}
+
+func (r *fakeFirstLessons) MarkAlerted(ctx context.Context, first models.FirstLesson) error {
+	r.alerted = append(r.alerted, first.StudentID)
+	return nil
+}
+
+func (r *fakeFirstLessons) DeleteBefore(ctx context.Context, date time.Time) error {
+	return nil
+}
+
+// fakeChangeSchedule returns lessons by stream, streams missing from the map fail with the error.
+type fakeChangeSchedule struct {
+	lessons map[string][]models.Lesson
+	err     error
+}
+
+func (s fakeChangeSchedule) DateLessons(stream, substream string, date time.Time) ([]models.Lesson, error) {
+	lessons, ok := s.lessons[stream]
+	if !ok {
+		return nil, s.err
+	}
+	if len(lessons) == 0 {
+		return nil, models.ErrLessonsAreEmpty
+	}
+	return lessons, nil
+}
+
+func TestDiffLessons(t *testing.T) {
+	now := time.Date(2025, time.April, 25, 8, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2025, time.April, 25, hour, minute, 0, 0, time.UTC)
+	}
+
+	first := models.Lesson{ID: "1", Name: "Go", Type: "Лекция", Cabinet: "101", DateStart: at(8, 30), DateEnd: at(10, 0)}
+	second := models.Lesson{ID: "2", Name: "Физика", Type: "Практика", Cabinet: "202", DateStart: at(10, 10), DateEnd: at(11, 40)}
+	started := models.Lesson{ID: "3", Name: "История", Type: "Лекция", Cabinet: "303", DateStart: at(7, 0), DateEnd: at(8, 20)}
+
+	with := func(l models.Lesson, fn func(l *models.Lesson)) models.Lesson {
+		fn(&l)
+		return l
+	}
+
+	moved := with(second, func(l *models.Lesson) { l.DateStart, l.DateEnd = at(12, 0), at(13, 30) })
+	recreated := with(second, func(l *models.Lesson) { l.ID = "20"; l.Cabinet = "205" })
+	roomChanged := with(first, func(l *models.Lesson) { l.Cabinet = "105" })
+
+	tests := []struct {
+		name     string
+		before   []models.Lesson
+		after    []models.Lesson
+		expected []models.LessonChange
+	}{
+		{
+			name:   "no changes",
+			before: []models.Lesson{first, second},
+			after:  []models.Lesson{first, second},
+		},
+		{
+			name:     "room change",
+			before:   []models.Lesson{first, second},
+			after:    []models.Lesson{roomChanged, second},
+			expected: []models.LessonChange{{Before: first, After: roomChanged}},
+		},
+		{
+			name:     "time change",
+			before:   []models.Lesson{first, second},
+			after:    []models.Lesson{first, moved},
+			expected: []models.LessonChange{{Before: second, After: moved}},
+		},
+		{
+			name:     "recreated lesson is matched by name",
+			before:   []models.Lesson{first, second},
+			after:    []models.Lesson{first, recreated},
+			expected: []models.LessonChange{{Before: second, After: recreated}},
+		},
+		{
+			name:   "started lesson is ignored",
+			before: []models.Lesson{started},
+			after:  []models.Lesson{with(started, func(l *models.Lesson) { l.Cabinet = "304" })},
+		},
+		{
+			name:   "removed lesson is not a room or time change",
+			before: []models.Lesson{first, second},
+			after:  []models.Lesson{first},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, diffLessons(tt.before, tt.after, now))
+		})
+	}
+}
+
+func TestFirstCancelled(t *testing.T) {
+	now := time.Date(2025, time.May, 5, 6, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return time.Date(2025, time.May, 5, hour, minute, 0, 0, time.UTC)
+	}
+
+	first := models.Lesson{ID: "1", Name: "Go", DateStart: at(8, 30), DateEnd: at(10, 0)}
+	second := models.Lesson{ID: "2", Name: "Физика", DateStart: at(10, 10), DateEnd: at(11, 40)}
+	earlier := models.Lesson{ID: "3", Name: "История", DateStart: at(7, 0), DateEnd: at(8, 20)}
+
+	tests := []struct {
+		name      string
+		first     models.Lesson
+		current   []models.Lesson
+		now       time.Time
+		after     *models.Lesson
+		cancelled bool
+	}{
+		{
+			name:    "first lesson is kept",
+			first:   first,
+			current: []models.Lesson{first, second},
+			now:     now,
+		},
+		{
+			name:    "day starts earlier",
+			first:   first,
+			current: []models.Lesson{earlier, second},
+			now:     now,
+		},
+		{
+			name:      "day starts later",
+			first:     first,
+			current:   []models.Lesson{second},
+			now:       now,
+			after:     &second,
+			cancelled: true,
+		},
+		{
+			name:      "no lessons are left",
+			first:     first,
+			now:       now,
+			cancelled: true,
+		},
+		{
+			name:    "first lesson is moved later",
+			first:   first,
+			current: []models.Lesson{{ID: "1", Name: "Go", DateStart: at(10, 10), DateEnd: at(11, 40)}},
+			now:     now,
+		},
+		{
+			name:    "first lesson is recreated later",
+			first:   first,
+			current: []models.Lesson{{ID: "4", Name: "Go", DateStart: at(11, 50), DateEnd: at(13, 20)}, second},
+			now:     now,
+		},
+		{
+			name:    "first lesson has started",
+			first:   first,
+			current: []models.Lesson{second},
+			now:     at(8, 30),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after, cancelled := firstCancelled(tt.first, tt.current, tt.now)
+			assert.Equal(t, tt.cancelled, cancelled)
+			assert.Equal(t, tt.after, after)
+		})
+	}
+}
+
+func TestChangeDetectSkipsFailedStreams(t *testing.T) {
+	now := time.Date(2025, time.April, 25, 8, 0, 0, 0, time.UTC)
+	lesson := models.Lesson{ID: "1", Name: "Go", Cabinet: "101", DateStart: now.Add(time.Hour), DateEnd: now.Add(2 * time.Hour)}
+	moved := lesson
+	moved.Cabinet = "105"
+
+	snapshot := func(studentId int64, stream string) models.LessonSnapshot {
+		return models.LessonSnapshot{StudentID: studentId, Stream: stream, Date: models.Date(now), Lessons: []models.Lesson{lesson}}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "portal error", err: errors.New("portal is down")},
+		{name: "unknown stream", err: models.ErrStreamIsUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeSnapshots{snapshots: []models.LessonSnapshot{snapshot(1, "ИСП-21"), snapshot(2, "ИСП-11"), snapshot(3, "ИСП-31")}}
+			schedule := fakeChangeSchedule{
+				lessons: map[string][]models.Lesson{"ИСП-21": {moved}, "ИСП-31": {moved}},
+				err:     tt.err,
+			}
+
+			changes, err := NewChange(repo, &fakeFirstLessons{}, schedule).Detect(t.Context(), now)
+			require.NoError(t, err)
+
+			require.Len(t, changes, 2)
+			assert.Equal(t, int64(1), changes[0].StudentID)
+			assert.Equal(t, int64(3), changes[1].StudentID)
+			assert.Equal(t, []int64{1, 3}, repo.saved)
+		})
+	}
+}
+
+func TestChangeDetectFirstCancel(t *testing.T) {
+	now := time.Date(2025, time.May, 5, 6, 0, 0, 0, time.UTC)
+	first := models.Lesson{ID: "1", Name: "Go", DateStart: now.Add(2 * time.Hour), DateEnd: now.Add(3 * time.Hour)}
+	second := models.Lesson{ID: "2", Name: "Физика", DateStart: now.Add(4 * time.Hour), DateEnd: now.Add(5 * time.Hour)}
+
+	firsts := &fakeFirstLessons{firsts: []models.FirstLesson{
+		{StudentID: 1, Stream: "ИСП-21", Date: models.Date(now), Lesson: first},
+		{StudentID: 2, Stream: "ИСП-11", Date: models.Date(now), Lesson: first},
+		{StudentID: 3, Stream: "ИСП-31", Date: models.Date(now), Lesson: first},
+		{StudentID: 4, Stream: "ИСП-41", Date: models.Date(now), Lesson: first},
+		{StudentID: 5, Stream: "ИСП-51", Date: models.Date(now), Lesson: first},
+	}}
+	moved := first
+	moved.DateStart, moved.DateEnd = second.DateEnd, second.DateEnd.Add(time.Hour)
+	schedule := fakeChangeSchedule{
+		lessons: map[string][]models.Lesson{"ИСП-21": {second}, "ИСП-31": {second}, "ИСП-41": {}, "ИСП-51": {second, moved}},
+		err:     errors.New("portal is down"),
+	}
+
+	cancels, err := NewChange(&fakeSnapshots{}, firsts, schedule).DetectFirstCancel(t.Context(), now)
+	require.NoError(t, err)
+
+	require.Len(t, cancels, 3)
+	assert.Equal(t, int64(1), cancels[0].StudentID)
+	assert.Equal(t, &second, cancels[0].After)
+	assert.Equal(t, int64(3), cancels[1].StudentID)
+	// The fourth student has no lessons left, the moved lesson of the fifth one is not a cancel
+	assert.Equal(t, int64(4), cancels[2].StudentID)
+	assert.Nil(t, cancels[2].After)
+	assert.Equal(t, []int64{1, 3, 4}, firsts.alerted)
+
+	t.Run("unknown stream is not a cancelled day", func(t *testing.T) {
+		firsts := &fakeFirstLessons{firsts: []models.FirstLesson{{StudentID: 1, Stream: "ИСП-61", Date: models.Date(now), Lesson: first}}}
+		schedule := fakeChangeSchedule{err: models.ErrStreamIsUnknown}
+
+		cancels, err := NewChange(&fakeSnapshots{}, firsts, schedule).DetectFirstCancel(t.Context(), now)
+		require.NoError(t, err)
+		assert.Empty(t, cancels)
+	})
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"log"
+	"math"
+	"pgtk-schedule/internal/models"
+	"time"
+)
+
+type chatRepository interface {
+	Save(ctx context.Context, id int64, title string) error
+	FindByID(ctx context.Context, id int64) (models.Chat, error)
+	UpdateStream(ctx context.Context, id int64, stream, substream string) error
+	Deactivate(ctx context.Context, id int64) error
+	Migrate(ctx context.Context, from, to int64) error
+	ShiftTime(ctx context.Context, id int64, kind models.NotifyKind, delta int) error
+	TogglePin(ctx context.Context, id int64) error
+	UpdatePinned(ctx context.Context, id int64, messageId int64) (*int64, error)
+	FindAllDue(ctx context.Context, kind models.NotifyKind, minute int, date time.Time, id int64, limit int) ([]models.Chat, int64, error)
+}
+
+type chat struct {
+	repo chatRepository
+}
+
+func NewChat(repo chatRepository) *chat {
+	return &chat{
+		repo: repo,
+	}
+}
+
+func (c *chat) Save(ctx context.Context, id int64, title string) error {
+	return c.repo.Save(ctx, id, title)
+}
+
+func (c *chat) FindByID(ctx context.Context, id int64) (models.Chat, error) {
+	return c.repo.FindByID(ctx, id)
+}
+
+func (c *chat) UpdateStream(ctx context.Context, id int64, stream, substream string) error {
+	return c.repo.UpdateStream(ctx, id, stream, substream)
+}
+
+func (c *chat) Deactivate(ctx context.Context, id int64) error {
+	return c.repo.Deactivate(ctx, id)
+}
+
+func (c *chat) Migrate(ctx context.Context, from, to int64) error {
+	return c.repo.Migrate(ctx, from, to)
+}
+
+func (c *chat) ShiftTime(ctx context.Context, id int64, kind models.NotifyKind, delta int) error {
+	return c.repo.ShiftTime(ctx, id, kind, delta)
+}
+
+func (c *chat) TogglePin(ctx context.Context, id int64) error {
+	return c.repo.TogglePin(ctx, id)
+}
+
+// Pinned remembers the pinned message of the chat and returns the previously pinned one.
+func (c *chat) Pinned(ctx context.Context, id int64, messageId int64) (*int64, error) {
+	return c.repo.UpdatePinned(ctx, id, messageId)
+}
+
+// ForEachDue calls fn for every bound chat whose notification of the kind is scheduled at the minute.
+func (c *chat) ForEachDue(kind models.NotifyKind, at time.Time, fn func(chat models.Chat) error) {
+	const limit = 25
+	var lastId int64 = math.MinInt64
+
+	minute := at.Hour()*60 + at.Minute()
+	for {
+		chats, currentLastId, err := c.repo.FindAllDue(context.Background(), kind, minute, at, lastId, limit)
+		if err != nil {
+			log.Println(err.Error())
+			return
+		}
+
+		if currentLastId == lastId {
+			return
+		}
+
+		lastId = currentLastId
+
+		for _, chat := range chats {
+			if err := fn(chat); err != nil {
+				log.Println(err.Error(), chat.ID)
+			}
+		}
+	}
+}
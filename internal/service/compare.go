@@ -0,0 +1,193 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"pgtk-schedule/internal/models"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	collegeDayStart = 8 * time.Hour
+	collegeDayEnd   = 20 * time.Hour
+	minFreeWindow   = 45 * time.Minute
+
+	compareCellWidth = 14
+)
+
+type compareSchedule interface {
+	CurrentWeekLessons(stream, substream string) ([]models.Lesson, error)
+}
+
+type compare struct {
+	schedule compareSchedule
+}
+
+func NewCompare(schedule compareSchedule) *compare {
+	return &compare{
+		schedule: schedule,
+	}
+}
+
+// Week compares current week lessons of the groups day by day from monday to saturday.
+func (c *compare) Week(groups []models.Group) ([]models.DayComparison, error) {
+	if len(groups) < 2 {
+		return nil, models.ErrNotEnoughGroups
+	}
+
+	groupLessons := make([][]models.Lesson, len(groups))
+	var first time.Time
+	for i, group := range groups {
+		lessons, err := c.schedule.CurrentWeekLessons(group.Stream, group.Substream)
+		if err != nil {
+			if errors.Is(err, models.ErrLessonsAreEmpty) {
+				continue
+			}
+			return nil, err
+		}
+
+		groupLessons[i] = lessons
+		if len(lessons) > 0 && (first.IsZero() || lessons[0].DateStart.Before(first)) {
+			first = lessons[0].DateStart
+		}
+	}
+
+	if first.IsZero() {
+		return nil, models.ErrLessonsAreEmpty
+	}
+
+	monday := dayStart(first).AddDate(0, 0, -weekdayIndex(first.Weekday()))
+
+	days := make([]models.DayComparison, 0, 6)
+	for i := range 6 {
+		date := monday.AddDate(0, 0, i)
+
+		day := models.DayComparison{
+			Date:    date,
+			Lessons: make([][]models.Lesson, len(groups)),
+		}
+
+		var busy []models.FreeWindow
+		for g, lessons := range groupLessons {
+			for _, lesson := range lessons {
+				if !dayStart(lesson.DateStart).Equal(date) {
+					continue
+				}
+
+				day.Lessons[g] = append(day.Lessons[g], lesson)
+				busy = append(busy, models.FreeWindow{Start: lesson.DateStart, End: lesson.DateEnd})
+			}
+		}
+
+		day.Free = freeWindows(date.Add(collegeDayStart), date.Add(collegeDayEnd), busy)
+		days = append(days, day)
+	}
+
+	return days, nil
+}
+
+// freeWindows returns gaps between busy intervals inside [from, to] that are long enough to meet.
+func freeWindows(from, to time.Time, busy []models.FreeWindow) []models.FreeWindow {
+	slices.SortFunc(busy, func(a, b models.FreeWindow) int {
+		return a.Start.Compare(b.Start)
+	})
+
+	var free []models.FreeWindow
+	cursor := from
+	for _, b := range busy {
+		if b.Start.After(cursor) {
+			end := b.Start
+			if end.After(to) {
+				end = to
+			}
+			if end.Sub(cursor) >= minFreeWindow {
+				free = append(free, models.FreeWindow{Start: cursor, End: end})
+			}
+		}
+
+		if b.End.After(cursor) {
+			cursor = b.End
+		}
+	}
+
+	if to.Sub(cursor) >= minFreeWindow {
+		free = append(free, models.FreeWindow{Start: cursor, End: to})
+	}
+
+	return free
+}
+
+// dayStart truncates time to midnight keeping its location.
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// DayToString renders free windows and a side-by-side view of the day.
+func (c *compare) DayToString(groups []models.Group, day models.DayComparison) string {
+	sb := strings.Builder{}
+
+	sb.WriteString(fmt.Sprintf("<b>📆 %s (%s)</b>\n", weekdays[day.Date.Weekday()], day.Date.Format("02.01.2006")))
+
+	if len(day.Free) == 0 {
+		sb.WriteString("Общего свободного времени нет\n")
+	} else {
+		windows := make([]string, 0, len(day.Free))
+		for _, w := range day.Free {
+			windows = append(windows, w.Start.Format("15:04")+"-"+w.End.Format("15:04"))
+		}
+		sb.WriteString("Свободно: " + strings.Join(windows, ", ") + "\n")
+	}
+
+	starts := make([]time.Time, 0)
+	for _, lessons := range day.Lessons {
+		for _, lesson := range lessons {
+			if !slices.ContainsFunc(starts, lesson.DateStart.Equal) {
+				starts = append(starts, lesson.DateStart)
+			}
+		}
+	}
+
+	if len(starts) == 0 {
+		return sb.String()
+	}
+
+	slices.SortFunc(starts, time.Time.Compare)
+
+	sb.WriteString("<pre>")
+	sb.WriteString(strings.Repeat(" ", 6))
+	for _, group := range groups {
+		sb.WriteString(" " + cell(group.Title))
+	}
+	sb.WriteString("\n")
+
+	for _, start := range starts {
+		sb.WriteString(start.Format("15:04") + " ")
+		for _, lessons := range day.Lessons {
+			name := "—"
+			for _, lesson := range lessons {
+				if lesson.DateStart.Equal(start) {
+					name = lesson.Name
+					break
+				}
+			}
+			sb.WriteString(" " + cell(name))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("</pre>")
+
+	return sb.String()
+}
+
+// cell truncates or pads text to the fixed column width.
+func cell(text string) string {
+	if utf8.RuneCountInString(text) > compareCellWidth {
+		text = string([]rune(text)[:compareCellWidth-1]) + "…"
+	}
+
+	return html.EscapeString(text) + strings.Repeat(" ", compareCellWidth-utf8.RuneCountInString(text))
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"pgtk-schedule/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCompareSchedule map[string][]models.Lesson
+
+func (s fakeCompareSchedule) CurrentWeekLessons(stream, substream string) ([]models.Lesson, error) {
+	lessons, ok := s[stream+"/"+substream]
+	if !ok {
+		return nil, models.ErrLessonsAreEmpty
+	}
+	return lessons, nil
+}
+
+func TestCompareWeek(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2025, time.February, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	schedule := fakeCompareSchedule{
+		"1/A": {
+			{Name: "Go", DateStart: at(4, 9, 0), DateEnd: at(4, 10, 30)},
+			{Name: "Физика", DateStart: at(4, 10, 40), DateEnd: at(4, 12, 10)},
+		},
+		"2/": {
+			{Name: "Химия", DateStart: at(4, 12, 0), DateEnd: at(4, 13, 30)},
+			{Name: "Химия", DateStart: at(4, 17, 0), DateEnd: at(4, 18, 30)},
+		},
+	}
+
+	groups := []models.Group{
+		{Stream: "1", Substream: "A", Title: "ИСП-21"},
+		{Stream: "2", Title: "ИСП-22"},
+	}
+
+	days, err := NewCompare(schedule).Week(groups)
+	require.NoError(t, err)
+	require.Len(t, days, 6)
+
+	assert.Equal(t, at(3, 0, 0), days[0].Date)
+	assert.Equal(t, []models.FreeWindow{{Start: at(3, 8, 0), End: at(3, 20, 0)}}, days[0].Free)
+
+	tuesday := days[1]
+	assert.Equal(t, at(4, 0, 0), tuesday.Date)
+	assert.Len(t, tuesday.Lessons[0], 2)
+	assert.Len(t, tuesday.Lessons[1], 2)
+	assert.Equal(t, []models.FreeWindow{
+		{Start: at(4, 8, 0), End: at(4, 9, 0)},
+		{Start: at(4, 13, 30), End: at(4, 17, 0)},
+		{Start: at(4, 18, 30), End: at(4, 20, 0)},
+	}, tuesday.Free)
+}
+
+func TestCompareWeekErrors(t *testing.T) {
+	c := NewCompare(fakeCompareSchedule{})
+
+	_, err := c.Week([]models.Group{{Stream: "1"}})
+	assert.ErrorIs(t, err, models.ErrNotEnoughGroups)
+
+	_, err = c.Week([]models.Group{{Stream: "1"}, {Stream: "2"}})
+	assert.ErrorIs(t, err, models.ErrLessonsAreEmpty)
+}
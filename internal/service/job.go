@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type jobRunRepository interface {
+	Start(ctx context.Context, job string, slot time.Time) (bool, error)
+	Finish(ctx context.Context, job string, slot time.Time, runErr error) error
+	LastSlot(ctx context.Context, job string) (time.Time, error)
+}
+
+type leaderElector interface {
+	IsLeader(ctx context.Context) (bool, error)
+}
+
+type jobRunner struct {
+	repo   jobRunRepository
+	leader leaderElector
+	grace  time.Duration
+}
+
+// NewJobRunner runs jobs on the leader instance only. Slots missed within grace are caught up.
+func NewJobRunner(repo jobRunRepository, leader leaderElector, grace time.Duration) *jobRunner {
+	return &jobRunner{
+		repo:   repo,
+		leader: leader,
+		grace:  grace,
+	}
+}
+
+// Run runs fn for the slot once across all instances.
+func (j *jobRunner) Run(ctx context.Context, job string, slot time.Time, fn func(slot time.Time) error) error {
+	isLeader, err := j.leader.IsLeader(ctx)
+	if err != nil || !isLeader {
+		return err
+	}
+
+	return j.run(ctx, job, slot, fn)
+}
+
+// CatchUp runs fn for the slot and for every slot after the last run missed within the grace window,
+// e.g. while the instance was restarting.
+func (j *jobRunner) CatchUp(ctx context.Context, job string, slot time.Time, step time.Duration, fn func(slot time.Time) error) error {
+	isLeader, err := j.leader.IsLeader(ctx)
+	if err != nil || !isLeader {
+		return err
+	}
+
+	last, err := j.repo.LastSlot(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	// A job that has never run has nothing to catch up
+	from := slot
+	if !last.IsZero() {
+		from = slot.Add(-j.grace)
+		if next := last.Add(step); next.After(from) {
+			from = next
+		}
+	}
+
+	// Align to slots
+	from = slot.Add(-slot.Sub(from) / step * step)
+
+	for s := from; !s.After(slot); s = s.Add(step) {
+		if err := j.run(ctx, job, s, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (j *jobRunner) run(ctx context.Context, job string, slot time.Time, fn func(slot time.Time) error) error {
+	started, err := j.repo.Start(ctx, job, slot)
+	if err != nil || !started {
+		return err
+	}
+
+	runErr := fn(slot)
+	if runErr != nil {
+		log.Println(job, slot, runErr.Error())
+	}
+
+	return j.repo.Finish(ctx, job, slot, runErr)
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeJobRuns struct {
+	slots map[time.Time]struct{}
+	last  time.Time
+}
+
+func (r *fakeJobRuns) Start(ctx context.Context, job string, slot time.Time) (bool, error) {
+	if _, ok := r.slots[slot]; ok {
+		return false, nil
+	}
+
+	r.slots[slot] = struct{}{}
+	if slot.After(r.last) {
+		r.last = slot
+	}
+
+	return true, nil
+}
+
+func (r *fakeJobRuns) Finish(ctx context.Context, job string, slot time.Time, runErr error) error {
+	return nil
+}
+
+func (r *fakeJobRuns) LastSlot(ctx context.Context, job string) (time.Time, error) {
+	return r.last, nil
+}
+
+type fakeLeader bool
+
+func (l fakeLeader) IsLeader(ctx context.Context) (bool, error) {
+	return bool(l), nil
+}
+
+func TestJobRunnerCatchUp(t *testing.T) {
+	slot := time.Date(2025, time.April, 21, 5, 3, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		leader   bool
+		last     time.Time
+		expected []time.Time
+	}{
+		{
+			name:     "first run",
+			leader:   true,
+			expected: []time.Time{slot},
+		},
+		{
+			name:     "missed slots after restart",
+			leader:   true,
+			last:     slot.Add(-3 * time.Minute),
+			expected: []time.Time{slot.Add(-2 * time.Minute), slot.Add(-time.Minute), slot},
+		},
+		{
+			name:     "slots older than grace are not caught up",
+			leader:   true,
+			last:     slot.Add(-time.Hour),
+			expected: []time.Time{slot.Add(-5 * time.Minute), slot.Add(-4 * time.Minute), slot.Add(-3 * time.Minute), slot.Add(-2 * time.Minute), slot.Add(-time.Minute), slot},
+		},
+		{
+			name:     "slot already run",
+			leader:   true,
+			last:     slot,
+			expected: nil,
+		},
+		{
+			name:     "follower does not run jobs",
+			leader:   false,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeJobRuns{slots: make(map[time.Time]struct{}), last: tt.last}
+			if !tt.last.IsZero() {
+				repo.slots[tt.last] = struct{}{}
+			}
+			runner := NewJobRunner(repo, fakeLeader(tt.leader), 5*time.Minute)
+
+			var ran []time.Time
+			err := runner.CatchUp(t.Context(), "notify", slot, time.Minute, func(slot time.Time) error {
+				ran = append(ran, slot)
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ran)
+		})
+	}
+}
+
+func TestJobRunnerRunOnce(t *testing.T) {
+	repo := &fakeJobRuns{slots: make(map[time.Time]struct{})}
+	runner := NewJobRunner(repo, fakeLeader(true), time.Hour)
+	slot := time.Date(2025, time.April, 21, 5, 0, 0, 0, time.UTC)
+
+	var runs int
+	for range 2 {
+		err := runner.Run(t.Context(), "update", slot, func(slot time.Time) error {
+			runs++
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, runs)
+}
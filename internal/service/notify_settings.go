@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"pgtk-schedule/internal/models"
+	"time"
 )
 
 type notifySettingsRepository interface {
@@ -10,6 +11,13 @@ type notifySettingsRepository interface {
 	ToggleMorning(ctx context.Context, studentId int64) error
 	ToggleEvening(ctx context.Context, studentId int64) error
 	ToggleWeek(ctx context.Context, studentId int64) error
+	ShiftTime(ctx context.Context, studentId int64, kind models.NotifyKind, delta int) error
+	ToggleDay(ctx context.Context, studentId int64, kind models.NotifyKind, weekday time.Weekday) error
+	ToggleReminder(ctx context.Context, studentId int64) error
+	UpdateReminderLead(ctx context.Context, studentId int64, lead int) error
+	UpdatePausedUntil(ctx context.Context, studentId int64, date *time.Time) error
+	ResumeExpired(ctx context.Context, date time.Time) ([]int64, error)
+	ResumeDue(ctx context.Context, date time.Time, minute int) ([]int64, error)
 }
 
 type notifySettings struct {
@@ -37,3 +45,54 @@ func (ns *notifySettings) ToggleEvening(ctx context.Context, studentId int64) er
 func (ns *notifySettings) ToggleWeek(ctx context.Context, studentId int64) error {
 	return ns.repo.ToggleWeek(ctx, studentId)
 }
+
+func (ns *notifySettings) ShiftTime(ctx context.Context, studentId int64, kind models.NotifyKind, delta int) error {
+	return ns.repo.ShiftTime(ctx, studentId, kind, delta)
+}
+
+// SetTime moves notification time of the kind to the minute since midnight.
+func (ns *notifySettings) SetTime(ctx context.Context, studentId int64, kind models.NotifyKind, minute int) error {
+	settings, err := ns.repo.FindByStudentID(ctx, studentId)
+	if err != nil {
+		return err
+	}
+
+	current, _ := settings.Schedule(kind)
+	if current == minute {
+		return nil
+	}
+
+	return ns.repo.ShiftTime(ctx, studentId, kind, minute-current)
+}
+
+func (ns *notifySettings) ToggleDay(ctx context.Context, studentId int64, kind models.NotifyKind, weekday time.Weekday) error {
+	return ns.repo.ToggleDay(ctx, studentId, kind, weekday)
+}
+
+func (ns *notifySettings) ToggleReminder(ctx context.Context, studentId int64) error {
+	return ns.repo.ToggleReminder(ctx, studentId)
+}
+
+func (ns *notifySettings) UpdateReminderLead(ctx context.Context, studentId int64, lead int) error {
+	return ns.repo.UpdateReminderLead(ctx, studentId, lead)
+}
+
+// Pause suppresses notifications until the date.
+func (ns *notifySettings) Pause(ctx context.Context, studentId int64, until time.Time) error {
+	date := models.Date(until)
+	return ns.repo.UpdatePausedUntil(ctx, studentId, &date)
+}
+
+func (ns *notifySettings) Resume(ctx context.Context, studentId int64) error {
+	return ns.repo.UpdatePausedUntil(ctx, studentId, nil)
+}
+
+// ResumeExpired resumes notifications paused until today or earlier and returns affected students.
+func (ns *notifySettings) ResumeExpired(ctx context.Context, today time.Time) ([]int64, error) {
+	return ns.repo.ResumeExpired(ctx, models.Date(today))
+}
+
+// ResumeDue resumes expired pauses of students with a notification scheduled at the minute of at or earlier today.
+func (ns *notifySettings) ResumeDue(ctx context.Context, at time.Time) ([]int64, error) {
+	return ns.repo.ResumeDue(ctx, models.Date(at), at.Hour()*60+at.Minute())
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"pgtk-schedule/internal/models"
+	"time"
+)
+
+const (
+	// outboxMaxAttempts is how many times a message is sent before it is marked as failed.
+	outboxMaxAttempts = 5
+	// outboxBackoff is the delay before the first retry. It doubles on every attempt.
+	outboxBackoff = 30 * time.Second
+	// outboxLease is how long a claimed message is hidden from other claims. It is renewed before the message is sent,
+	// so a long batch or a flood pause does not let it expire.
+	outboxLease = time.Minute
+)
+
+type outboxRepository interface {
+	Create(ctx context.Context, message models.OutboxMessage) (int64, error)
+	Claim(ctx context.Context, lease time.Duration, limit int) ([]models.OutboxMessage, error)
+	Renew(ctx context.Context, id int64, attempts int, lease time.Duration) (bool, error)
+	MarkSent(ctx context.Context, id int64, messageId int64) error
+	MarkRetry(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error
+	MarkFailed(ctx context.Context, id int64, lastError string) error
+}
+
+type notificationLogRepository interface {
+	Create(ctx context.Context, entry models.NotificationLog) error
+	CreateOnce(ctx context.Context, entry models.NotificationLog) (int64, bool, error)
+	AttachOutbox(ctx context.Context, id int64, outboxId int64) error
+	Fail(ctx context.Context, id int64, lastError string) error
+	UpdateByOutboxID(ctx context.Context, outboxId int64, status models.DeliveryStatus, messageId *int64, lastError *string) error
+	LastRuns(ctx context.Context) ([]models.RunReport, error)
+}
+
+type outbox struct {
+	repo    outboxRepository
+	logRepo notificationLogRepository
+}
+
+func NewOutbox(repo outboxRepository, logRepo notificationLogRepository) *outbox {
+	return &outbox{
+		repo:    repo,
+		logRepo: logRepo,
+	}
+}
+
+// Enqueue stores the message for delivery by the sender and records it in the log of the run.
+func (o *outbox) Enqueue(ctx context.Context, runId string, chatId int64, kind, text string) (int64, error) {
+	return o.enqueue(ctx, runId, models.OutboxMessage{ChatID: chatId, Kind: kind, Text: text})
+}
+
+// EnqueuePinned stores the message that is pinned in the chat once it is sent.
+func (o *outbox) EnqueuePinned(ctx context.Context, runId string, chatId int64, kind, text string) (int64, error) {
+	return o.enqueue(ctx, runId, models.OutboxMessage{ChatID: chatId, Kind: kind, Text: text, Pin: true})
+}
+
+// EnqueueEdit stores a new text of the sent message for delivery by the sender.
+func (o *outbox) EnqueueEdit(ctx context.Context, runId string, chatId int64, messageId int64, text string) (int64, error) {
+	return o.enqueue(ctx, runId, models.OutboxMessage{ChatID: chatId, Kind: models.OutboxEdit, Text: text, EditMessageID: &messageId})
+}
+
+// EnqueueOnce stores the message unless a message with the key has already been queued to the chat,
+// so a run repeated after a restart does not send it twice. It reports whether the message is queued.
+func (o *outbox) EnqueueOnce(ctx context.Context, runId string, chatId int64, kind, key, text string) (bool, error) {
+	entry := models.NotificationLog{
+		RunID:     runId,
+		Kind:      kind,
+		StudentID: chatId,
+		Status:    models.DeliveryQueued,
+		Key:       &key,
+	}
+
+	// The key is taken before the message is queued, so concurrent runs queue it once
+	logId, created, err := o.logRepo.CreateOnce(ctx, entry)
+	if err != nil || !created {
+		return false, err
+	}
+
+	id, err := o.repo.Create(ctx, models.OutboxMessage{ChatID: chatId, Kind: kind, Text: text})
+	if err != nil {
+		return false, errors.Join(err, o.logRepo.Fail(ctx, logId, err.Error()))
+	}
+
+	return true, o.logRepo.AttachOutbox(ctx, logId, id)
+}
+
+// enqueue stores the message and records the result of queueing in the log of the run.
+func (o *outbox) enqueue(ctx context.Context, runId string, message models.OutboxMessage) (int64, error) {
+	id, err := o.repo.Create(ctx, message)
+	if err != nil {
+		return 0, errors.Join(err, o.Record(ctx, runId, message.ChatID, message.Kind, models.DeliveryFailed, err))
+	}
+
+	entry := models.NotificationLog{
+		RunID:     runId,
+		Kind:      message.Kind,
+		StudentID: message.ChatID,
+		Status:    models.DeliveryQueued,
+		OutboxID:  &id,
+	}
+
+	return id, o.logRepo.Create(ctx, entry)
+}
+
+// Record logs an outcome of the run for the student when nothing is queued.
+func (o *outbox) Record(ctx context.Context, runId string, studentId int64, kind string, status models.DeliveryStatus, cause error) error {
+	entry := models.NotificationLog{
+		RunID:     runId,
+		Kind:      kind,
+		StudentID: studentId,
+		Status:    status,
+	}
+
+	if cause != nil {
+		text := cause.Error()
+		entry.Error = &text
+	}
+
+	return o.logRepo.Create(ctx, entry)
+}
+
+// LastRuns returns summaries of the latest run of every job.
+func (o *outbox) LastRuns(ctx context.Context) ([]models.RunReport, error) {
+	return o.logRepo.LastRuns(ctx)
+}
+
+// Claim returns due messages. Attempts of returned messages are already incremented.
+func (o *outbox) Claim(ctx context.Context, limit int) ([]models.OutboxMessage, error) {
+	return o.repo.Claim(ctx, outboxLease, limit)
+}
+
+// Renew extends the lease of the claimed message and reports whether the sender still owns it.
+// The number of attempts identifies the claim, because every claim increments it.
+func (o *outbox) Renew(ctx context.Context, message models.OutboxMessage) (bool, error) {
+	return o.repo.Renew(ctx, message.ID, message.Attempts, outboxLease)
+}
+
+func (o *outbox) Sent(ctx context.Context, message models.OutboxMessage, messageId int) error {
+	id := int64(messageId)
+	if err := o.repo.MarkSent(ctx, message.ID, id); err != nil {
+		return err
+	}
+
+	return o.logRepo.UpdateByOutboxID(ctx, message.ID, models.DeliverySent, &id, nil)
+}
+
+// Retry schedules the message after a transient error. Zero after means exponential backoff.
+// The message is marked as failed when attempts run out.
+func (o *outbox) Retry(ctx context.Context, message models.OutboxMessage, err error, after time.Duration) error {
+	if message.Attempts >= outboxMaxAttempts {
+		return o.Fail(ctx, message, err)
+	}
+
+	if after == 0 {
+		after = outboxBackoff << max(message.Attempts-1, 0)
+	}
+
+	return o.repo.MarkRetry(ctx, message.ID, time.Now().Add(after), err.Error())
+}
+
+// Fail marks the message as failed after a permanent error.
+func (o *outbox) Fail(ctx context.Context, message models.OutboxMessage, err error) error {
+	text := err.Error()
+	if err := o.repo.MarkFailed(ctx, message.ID, text); err != nil {
+		return err
+	}
+
+	return o.logRepo.UpdateByOutboxID(ctx, message.ID, models.DeliveryFailed, nil, &text)
+}
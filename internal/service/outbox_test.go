@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pgtk-schedule/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeOutboxRepo struct {
+	retryAt map[int64]time.Time
+	failed  map[int64]string
+	created []models.OutboxMessage
+	err     error
+}
+
+func (r *fakeOutboxRepo) Create(ctx context.Context, message models.OutboxMessage) (int64, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	r.created = append(r.created, message)
+	return int64(len(r.created)), nil
+}
+
+func (r *fakeOutboxRepo) Claim(ctx context.Context, lease time.Duration, limit int) ([]models.OutboxMessage, error) {
+	return nil, nil
+}
+
+func (r *fakeOutboxRepo) Renew(ctx context.Context, id int64, attempts int, lease time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (r *fakeOutboxRepo) MarkSent(ctx context.Context, id int64, messageId int64) error {
+	return nil
+}
+
+func (r *fakeOutboxRepo) MarkRetry(ctx context.Context, id int64, nextAttemptAt time.Time, lastError string) error {
+	r.retryAt[id] = nextAttemptAt
+	return nil
+}
+
+func (r *fakeOutboxRepo) MarkFailed(ctx context.Context, id int64, lastError string) error {
+	r.failed[id] = lastError
+	return nil
+}
+
+type fakeNotificationLogRepo struct {
+	statuses map[int64]models.DeliveryStatus
+	keys     map[string]int64
+	failed   []int64
+	seq      int64
+}
+
+func (r *fakeNotificationLogRepo) Create(ctx context.Context, entry models.NotificationLog) error {
+	return nil
+}
+
+func (r *fakeNotificationLogRepo) CreateOnce(ctx context.Context, entry models.NotificationLog) (int64, bool, error) {
+	key := fmt.Sprintf("%s/%d/%s", entry.Kind, entry.StudentID, *entry.Key)
+	if _, ok := r.keys[key]; ok {
+		return 0, false, nil
+	}
+
+	r.seq++
+	r.keys[key] = r.seq
+	return r.seq, true, nil
+}
+
+func (r *fakeNotificationLogRepo) AttachOutbox(ctx context.Context, id int64, outboxId int64) error {
+	return nil
+}
+
+func (r *fakeNotificationLogRepo) Fail(ctx context.Context, id int64, lastError string) error {
+	r.failed = append(r.failed, id)
+	for key, keyId := range r.keys {
+		if keyId == id {
+			delete(r.keys, key)
+		}
+	}
+	return nil
+}
+
+func (r *fakeNotificationLogRepo) UpdateByOutboxID(ctx context.Context, outboxId int64, status models.DeliveryStatus, messageId *int64, lastError *string) error {
+	r.statuses[outboxId] = status
+	return nil
+}
+
+func (r *fakeNotificationLogRepo) LastRuns(ctx context.Context) ([]models.RunReport, error) {
+	return nil, nil
+}
+
+func TestOutboxRetry(t *testing.T) {
+	sendErr := errors.New("connection reset")
+
+	tests := []struct {
+		name     string
+		attempts int
+		after    time.Duration
+		delay    time.Duration
+		failed   bool
+	}{
+		{name: "first attempt", attempts: 1, delay: outboxBackoff},
+		{name: "backoff doubles", attempts: 3, delay: 4 * outboxBackoff},
+		{name: "retry after overrides backoff", attempts: 3, after: 7 * time.Second, delay: 7 * time.Second},
+		{name: "attempts run out", attempts: outboxMaxAttempts, failed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeOutboxRepo{retryAt: make(map[int64]time.Time), failed: make(map[int64]string)}
+			logRepo := &fakeNotificationLogRepo{statuses: make(map[int64]models.DeliveryStatus)}
+			o := NewOutbox(repo, logRepo)
+
+			before := time.Now()
+			err := o.Retry(t.Context(), models.OutboxMessage{ID: 1, Attempts: tt.attempts}, sendErr, tt.after)
+			assert.NoError(t, err)
+
+			if tt.failed {
+				assert.Equal(t, sendErr.Error(), repo.failed[1])
+				assert.Equal(t, models.DeliveryFailed, logRepo.statuses[1])
+				assert.NotContains(t, repo.retryAt, int64(1))
+				return
+			}
+
+			assert.NotContains(t, repo.failed, int64(1))
+			assert.NotContains(t, logRepo.statuses, int64(1))
+			assert.WithinRange(t, repo.retryAt[1], before.Add(tt.delay), time.Now().Add(tt.delay))
+		})
+	}
+}
+
+func TestOutboxEnqueueOnce(t *testing.T) {
+	repo := &fakeOutboxRepo{}
+	logRepo := &fakeNotificationLogRepo{keys: make(map[string]int64)}
+	o := NewOutbox(repo, logRepo)
+
+	queued, err := o.EnqueueOnce(t.Context(), "reminder-2025-02-03", 1, models.OutboxReminder, "1/1738571400", "text")
+	require.NoError(t, err)
+	assert.True(t, queued)
+
+	// A run repeated after a restart finds the key
+	queued, err = o.EnqueueOnce(t.Context(), "reminder-2025-02-03", 1, models.OutboxReminder, "1/1738571400", "text")
+	require.NoError(t, err)
+	assert.False(t, queued)
+
+	queued, err = o.EnqueueOnce(t.Context(), "reminder-2025-02-03", 2, models.OutboxReminder, "1/1738571400", "text")
+	require.NoError(t, err)
+	assert.True(t, queued)
+	assert.Len(t, repo.created, 2)
+
+	t.Run("failed message can be queued again", func(t *testing.T) {
+		repo.err = errors.New("connection reset")
+		_, err := o.EnqueueOnce(t.Context(), "reminder-2025-02-03", 3, models.OutboxReminder, "1/1738571400", "text")
+		require.ErrorIs(t, err, repo.err)
+		assert.Len(t, logRepo.failed, 1)
+
+		repo.err = nil
+		queued, err := o.EnqueueOnce(t.Context(), "reminder-2025-02-03", 3, models.OutboxReminder, "1/1738571400", "text")
+		require.NoError(t, err)
+		assert.True(t, queued)
+	})
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"pgtk-schedule/internal/models"
+	"sync"
+	"time"
+)
+
+// reminderStale is how late a reminder may be sent, e.g. after a restart.
+const reminderStale = 5 * time.Minute
+
+type reminderStudentRepository interface {
+	FindByID(ctx context.Context, id int64) (models.Student, error)
+	FindAllWithReminder(ctx context.Context, id int64, limit int) ([]models.Student, int64, error)
+}
+
+type reminderSettingsRepository interface {
+	FindByStudentID(ctx context.Context, studentId int64) (models.NotifySettings, error)
+}
+
+type reminderSubscriptionService interface {
+	Notified(ctx context.Context, student models.Student) ([]models.Subscription, error)
+	Title(subscription models.Subscription) string
+}
+
+type reminderSchedule interface {
+	DateLessons(stream, substream string, date time.Time) ([]models.Lesson, error)
+}
+
+type reminderTeacherService interface {
+	DateLessons(teacher string, date time.Time) ([]models.Lesson, error)
+}
+
+type reminder struct {
+	studentRepo         reminderStudentRepository
+	settingsRepo        reminderSettingsRepository
+	subscriptionService reminderSubscriptionService
+	schedule            reminderSchedule
+	teacherService      reminderTeacherService
+
+	plan map[int64][]models.Reminder
+	sent map[string]time.Time
+	mu   sync.Mutex
+}
+
+func NewReminder(studentRepo reminderStudentRepository, settingsRepo reminderSettingsRepository, subscriptionService reminderSubscriptionService, schedule reminderSchedule, teacherService reminderTeacherService) *reminder {
+	return &reminder{
+		studentRepo:         studentRepo,
+		settingsRepo:        settingsRepo,
+		subscriptionService: subscriptionService,
+		schedule:            schedule,
+		teacherService:      teacherService,
+		plan:                make(map[int64][]models.Reminder),
+		sent:                make(map[string]time.Time),
+	}
+}
+
+// Rebuild plans reminders for today and tomorrow lessons of every student with enabled reminders.
+// It must be called after each schedule update, so moved lessons are rescheduled.
+func (r *reminder) Rebuild(ctx context.Context, now time.Time) error {
+	const limit = 25
+	var lastId int64 = math.MinInt64
+
+	plan := make(map[int64][]models.Reminder)
+	for {
+		students, currentLastId, err := r.studentRepo.FindAllWithReminder(ctx, lastId, limit)
+		if err != nil {
+			return err
+		}
+
+		if currentLastId == lastId {
+			break
+		}
+
+		lastId = currentLastId
+
+		for _, student := range students {
+			reminders, err := r.studentReminders(ctx, student, now)
+			if err != nil {
+				log.Println(err.Error(), student.ID)
+				continue
+			}
+
+			plan[student.ID] = reminders
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.plan = plan
+	for key, at := range r.sent {
+		if at.Before(now.Add(-24 * time.Hour)) {
+			delete(r.sent, key)
+		}
+	}
+
+	return nil
+}
+
+// RebuildStudent replans reminders of the student after settings change.
+func (r *reminder) RebuildStudent(ctx context.Context, studentId int64, now time.Time) error {
+	student, err := r.studentRepo.FindByID(ctx, studentId)
+	if err != nil {
+		return err
+	}
+
+	reminders, err := r.studentReminders(ctx, student, now)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(reminders) == 0 {
+		delete(r.plan, studentId)
+		return nil
+	}
+
+	r.plan[studentId] = reminders
+
+	return nil
+}
+
+// Due returns reminders planned at the minute of at or up to reminderStale earlier, so reminders missed
+// during a restart are still sent. Every reminder is returned once by the instance, reminders returned again
+// after a restart are deduplicated by the notification log.
+func (r *reminder) Due(at time.Time) []models.Reminder {
+	at = at.Truncate(time.Minute)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []models.Reminder
+	for _, reminders := range r.plan {
+		for _, reminder := range reminders {
+			if reminder.At.After(at) || reminder.At.Before(at.Add(-reminderStale)) {
+				continue
+			}
+
+			key := fmt.Sprintf("%d/%s/%d", reminder.StudentID, reminder.Lesson.ID, reminder.Lesson.DateStart.Unix())
+			if _, ok := r.sent[key]; ok {
+				continue
+			}
+			r.sent[key] = reminder.At
+
+			due = append(due, reminder)
+		}
+	}
+
+	return due
+}
+
+func (r *reminder) studentReminders(ctx context.Context, student models.Student, now time.Time) ([]models.Reminder, error) {
+	settings, err := r.settingsRepo.FindByStudentID(ctx, student.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !settings.Reminder || settings.Paused(now) {
+		return nil, nil
+	}
+
+	lead := time.Duration(settings.ReminderLead) * time.Minute
+	from := now.Truncate(time.Minute)
+
+	var reminders []models.Reminder
+	add := func(title string, lessons []models.Lesson) {
+		for _, lesson := range lessons {
+			at := lesson.DateStart.Add(-lead)
+			if at.Before(from) {
+				continue
+			}
+
+			reminders = append(reminders, models.Reminder{
+				StudentID: student.ID,
+				Title:     title,
+				Lesson:    lesson,
+				Lead:      settings.ReminderLead,
+				At:        at,
+			})
+		}
+	}
+
+	// Days are taken from now rather than the clock, so a rebuild after midnight plans the right day
+	days := [...]time.Time{now, now.AddDate(0, 0, 1)}
+
+	if student.IsTeacher() {
+		for _, day := range days {
+			lessons, err := r.teacherService.DateLessons(*student.Teacher, day)
+			if err != nil {
+				if errors.Is(err, models.ErrLessonsAreEmpty) {
+					continue
+				}
+				return nil, err
+			}
+
+			add("", lessons)
+		}
+
+		return reminders, nil
+	}
+
+	subscriptions, err := r.subscriptionService.Notified(ctx, student)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, subscription := range subscriptions {
+		title := ""
+		if len(subscriptions) > 1 {
+			title = r.subscriptionService.Title(subscription)
+		}
+
+		for _, day := range days {
+			lessons, err := r.schedule.DateLessons(subscription.Stream, subscription.Substream, day)
+			if err != nil {
+				if errors.Is(err, models.ErrLessonsAreEmpty) || errors.Is(err, models.ErrStreamIsUnknown) {
+					continue
+				}
+				return nil, err
+			}
+
+			add(title, lessons)
+		}
+	}
+
+	return reminders, nil
+}
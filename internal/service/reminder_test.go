@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"pgtk-schedule/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReminderStudents struct {
+	students []models.Student
+}
+
+func (r *fakeReminderStudents) FindByID(ctx context.Context, id int64) (models.Student, error) {
+	for _, student := range r.students {
+		if student.ID == id {
+			return student, nil
+		}
+	}
+	return models.Student{}, models.ErrStudentNotFound
+}
+
+func (r *fakeReminderStudents) FindAllWithReminder(ctx context.Context, id int64, limit int) ([]models.Student, int64, error) {
+	var students []models.Student
+	for _, student := range r.students {
+		if student.ID > id && len(students) < limit {
+			students = append(students, student)
+		}
+	}
+
+	if len(students) == 0 {
+		return nil, id, nil
+	}
+	return students, students[len(students)-1].ID, nil
+}
+
+type fakeReminderSettings map[int64]models.NotifySettings
+
+func (r fakeReminderSettings) FindByStudentID(ctx context.Context, studentId int64) (models.NotifySettings, error) {
+	return r[studentId], nil
+}
+
+type fakeReminderSubscriptions struct{}
+
+func (fakeReminderSubscriptions) Notified(ctx context.Context, student models.Student) ([]models.Subscription, error) {
+	return []models.Subscription{{StudentID: student.ID, Stream: *student.Stream}}, nil
+}
+
+func (fakeReminderSubscriptions) Title(subscription models.Subscription) string {
+	return subscription.Stream
+}
+
+type fakeReminderSchedule struct {
+	today []models.Lesson
+}
+
+func (s *fakeReminderSchedule) DateLessons(stream, substream string, date time.Time) ([]models.Lesson, error) {
+	if len(s.today) == 0 || date.YearDay() != s.today[0].DateStart.YearDay() {
+		return nil, models.ErrLessonsAreEmpty
+	}
+	return s.today, nil
+}
+
+func TestReminderDue(t *testing.T) {
+	stream := "1"
+	now := time.Date(2025, time.February, 3, 8, 0, 0, 0, time.UTC)
+	first := models.Lesson{ID: "1", Name: "Go", DateStart: now.Add(30 * time.Minute)}
+	second := models.Lesson{ID: "2", Name: "Физика", DateStart: now.Add(2 * time.Hour)}
+
+	schedule := &fakeReminderSchedule{today: []models.Lesson{first, second}}
+	students := &fakeReminderStudents{students: []models.Student{{ID: 1, Stream: &stream}, {ID: 2, Stream: &stream}}}
+	settings := fakeReminderSettings{
+		1: {Reminder: true, ReminderLead: 10},
+		2: {Reminder: false, ReminderLead: 10},
+	}
+
+	r := NewReminder(students, settings, fakeReminderSubscriptions{}, schedule, nil)
+	require.NoError(t, r.Rebuild(t.Context(), now))
+
+	assert.Empty(t, r.Due(now.Add(19*time.Minute)))
+
+	due := r.Due(now.Add(20 * time.Minute))
+	require.Len(t, due, 1)
+	assert.Equal(t, int64(1), due[0].StudentID)
+	assert.Equal(t, "Go", due[0].Lesson.Name)
+
+	t.Run("sent reminders are not repeated after rebuild", func(t *testing.T) {
+		require.NoError(t, r.Rebuild(t.Context(), now.Add(20*time.Minute)))
+		assert.Empty(t, r.Due(now.Add(21*time.Minute)))
+	})
+
+	t.Run("moved lesson is rescheduled", func(t *testing.T) {
+		moved := second
+		moved.DateStart = now.Add(3 * time.Hour)
+		schedule.today = []models.Lesson{first, moved}
+		require.NoError(t, r.Rebuild(t.Context(), now.Add(time.Hour)))
+
+		assert.Empty(t, r.Due(now.Add(110*time.Minute)))
+
+		due := r.Due(now.Add(170 * time.Minute))
+		require.Len(t, due, 1)
+		assert.Equal(t, moved.DateStart, due[0].Lesson.DateStart)
+	})
+
+	t.Run("reminders are sent late only within reminderStale", func(t *testing.T) {
+		late := models.Lesson{ID: "4", Name: "История", DateStart: now.Add(5 * time.Hour)}
+		schedule.today = []models.Lesson{late}
+		require.NoError(t, r.Rebuild(t.Context(), now.Add(time.Hour)))
+
+		at := late.DateStart.Add(-10 * time.Minute)
+		assert.Empty(t, r.Due(at.Add(reminderStale+time.Minute)))
+
+		due := r.Due(at.Add(reminderStale))
+		require.Len(t, due, 1)
+		assert.Equal(t, "История", due[0].Lesson.Name)
+	})
+
+	t.Run("paused student is skipped", func(t *testing.T) {
+		pausedUntil := models.Date(now.AddDate(0, 0, 1))
+		settings[1] = models.NotifySettings{Reminder: true, ReminderLead: 10, PausedUntil: &pausedUntil}
+		schedule.today = []models.Lesson{{ID: "3", Name: "Химия", DateStart: now.Add(4 * time.Hour)}}
+		require.NoError(t, r.Rebuild(t.Context(), now.Add(time.Hour)))
+
+		assert.Empty(t, r.Due(now.Add(230*time.Minute)))
+	})
+}
@@ -39,12 +39,14 @@ type schedulePortal interface {
 
 type schedule struct {
 	portal schedulePortal
+	loc    *time.Location
 	mu     sync.RWMutex
 }
 
-func NewSchedule(portal schedulePortal) *schedule {
+func NewSchedule(portal schedulePortal, loc *time.Location) *schedule {
 	return &schedule{
 		portal: portal,
+		loc:    loc,
 	}
 }
 
@@ -56,18 +58,19 @@ func (s *schedule) Update() error {
 	return err
 }
 
-func (s *schedule) dateLessons(stream, substream string, date time.Time) ([]models.Lesson, error) {
+// DateLessons returns lessons of the date within the current week. Dates are compared in the college timezone.
+func (s *schedule) DateLessons(stream, substream string, date time.Time) ([]models.Lesson, error) {
 	l, err := s.CurrentWeekLessons(stream, substream)
 	if err != nil {
 		return nil, err
 	}
 
-	date = date.Truncate(24 * time.Hour)
+	year, month, day := date.In(s.loc).Date()
 
 	lessons := make([]models.Lesson, 0, len(l))
 	for _, lesson := range l {
-		lessonDate := lesson.DateStart.Truncate(24 * time.Hour)
-		if !date.Equal(lessonDate) {
+		lessonYear, lessonMonth, lessonDay := lesson.DateStart.In(s.loc).Date()
+		if year != lessonYear || month != lessonMonth || day != lessonDay {
 			continue
 		}
 
@@ -82,11 +85,11 @@ func (s *schedule) dateLessons(stream, substream string, date time.Time) ([]mode
 }
 
 func (s *schedule) TodayLessons(stream, substream string) ([]models.Lesson, error) {
-	return s.dateLessons(stream, substream, time.Now())
+	return s.DateLessons(stream, substream, time.Now())
 }
 
 func (s *schedule) TomorrowLessons(stream, substream string) ([]models.Lesson, error) {
-	return s.dateLessons(stream, substream, time.Now().Add(24*time.Hour))
+	return s.DateLessons(stream, substream, time.Now().In(s.loc).AddDate(0, 0, 1))
 }
 
 func (s *schedule) CurrentWeekLessons(stream, substream string) ([]models.Lesson, error) {
@@ -112,6 +115,13 @@ func (s *schedule) CurrentWeekLessons(stream, substream string) ([]models.Lesson
 }
 
 func (s *schedule) LessonsToString(lessons []models.Lesson) string {
+	return lessonsToString(lessons, func(i int, l models.Lesson) string {
+		return fmt.Sprintf("<b>%d)</b> %s (%s)\nПреподаватель: %s\nВремя: %s-%s\nКабинет: %s", i+1, l.Name, l.Type, l.Teacher, l.DateStart.Format("15:04"), l.DateEnd.Format("15:04"), l.Cabinet)
+	})
+}
+
+// lessonsToString groups lessons by weekday and renders every lesson with format.
+func lessonsToString(lessons []models.Lesson, format func(i int, l models.Lesson) string) string {
 	mapLessons := make(map[string][]models.Lesson, len(weekdays))
 
 	for _, lesson := range lessons {
@@ -153,7 +163,7 @@ func (s *schedule) LessonsToString(lessons []models.Lesson) string {
 		sb.WriteString("\n")
 
 		for i, l := range lessons {
-			stringLesson := fmt.Sprintf("<b>%d)</b> %s (%s)\nПреподаватель: %s\nВремя: %s-%s\nКабинет: %s", i+1, l.Name, l.Type, l.Teacher, l.DateStart.Format("15:04"), l.DateEnd.Format("15:04"), l.Cabinet)
+			stringLesson := format(i, l)
 			sb.Grow(utf8.RuneCountInString(stringLesson) + 1)
 			sb.WriteString(stringLesson)
 			sb.WriteString("\n\n")
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"pgtk-schedule/internal/models"
+	"time"
+)
+
+type scheduleMessageRepository interface {
+	Create(ctx context.Context, message models.ScheduleMessage) error
+	FindSince(ctx context.Context, date time.Time) ([]models.ScheduleMessage, error)
+	UpdateLessons(ctx context.Context, id int64, lessons []models.Lesson) error
+	DeleteBefore(ctx context.Context, date time.Time) error
+}
+
+type scheduleMessageSchedule interface {
+	CurrentWeekLessons(stream, substream string) ([]models.Lesson, error)
+}
+
+type scheduleMessage struct {
+	repo     scheduleMessageRepository
+	schedule scheduleMessageSchedule
+}
+
+func NewScheduleMessage(repo scheduleMessageRepository, schedule scheduleMessageSchedule) *scheduleMessage {
+	return &scheduleMessage{
+		repo:     repo,
+		schedule: schedule,
+	}
+}
+
+// Track remembers the sent message, so it is edited when lessons of its days change.
+// Week messages cover the whole week of the lessons, other messages only the days of the lessons.
+func (s *scheduleMessage) Track(ctx context.Context, message models.ScheduleMessage, week bool) error {
+	if len(message.Lessons) == 0 {
+		return nil
+	}
+
+	message.DateFrom = models.Date(message.Lessons[0].DateStart)
+	message.DateTo = models.Date(message.Lessons[len(message.Lessons)-1].DateStart)
+	if week {
+		message.DateFrom = message.DateFrom.AddDate(0, 0, -(int(message.DateFrom.Weekday())+6)%7)
+		message.DateTo = message.DateFrom.AddDate(0, 0, 6)
+	}
+
+	return s.repo.Create(ctx, message)
+}
+
+// Outdated returns sent messages of today or later days whose lessons differ from the current ones.
+// Returned messages contain the current lessons and are remembered as edited.
+func (s *scheduleMessage) Outdated(ctx context.Context, now time.Time) ([]models.ScheduleMessage, error) {
+	today := models.Date(now)
+	if err := s.repo.DeleteBefore(ctx, today); err != nil {
+		log.Println(err.Error())
+	}
+
+	messages, err := s.repo.FindSince(ctx, today)
+	if err != nil {
+		return nil, err
+	}
+
+	weeks := make(map[models.Subscription][]models.Lesson)
+	var result []models.ScheduleMessage
+	for _, message := range messages {
+		subscription := models.Subscription{Stream: message.Stream, Substream: message.Substream}
+		lessons, ok := weeks[subscription]
+		if !ok {
+			lessons, err = s.schedule.CurrentWeekLessons(message.Stream, message.Substream)
+			if err != nil && !errors.Is(err, models.ErrLessonsAreEmpty) && !errors.Is(err, models.ErrStreamIsUnknown) {
+				return nil, err
+			}
+			weeks[subscription] = lessons
+		}
+
+		current := lessonsBetween(lessons, message.DateFrom, message.DateTo)
+		// Empty lessons are more likely a portal failure than a cancelled week
+		if len(current) == 0 || sameLessons(message.Lessons, current) {
+			continue
+		}
+
+		if err := s.repo.UpdateLessons(ctx, message.ID, current); err != nil {
+			return nil, err
+		}
+
+		message.Lessons = current
+		result = append(result, message)
+	}
+
+	return result, nil
+}
+
+// lessonsBetween returns lessons between dates inclusive.
+func lessonsBetween(lessons []models.Lesson, from, to time.Time) []models.Lesson {
+	var result []models.Lesson
+	for _, lesson := range lessons {
+		date := models.Date(lesson.DateStart)
+		if date.Before(from) || date.After(to) {
+			continue
+		}
+
+		result = append(result, lesson)
+	}
+
+	return result
+}
+
+// sameLessons reports whether lessons render the same. Identifiers are ignored.
+func sameLessons(a, b []models.Lesson) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Type != b[i].Type || a[i].Teacher != b[i].Teacher || a[i].Cabinet != b[i].Cabinet ||
+			!a[i].DateStart.Equal(b[i].DateStart) || !a[i].DateEnd.Equal(b[i].DateEnd) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"pgtk-schedule/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeScheduleMessages struct {
+	messages []models.ScheduleMessage
+}
+
+func (r *fakeScheduleMessages) Create(ctx context.Context, message models.ScheduleMessage) error {
+	message.ID = int64(len(r.messages) + 1)
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+func (r *fakeScheduleMessages) FindSince(ctx context.Context, date time.Time) ([]models.ScheduleMessage, error) {
+	var result []models.ScheduleMessage
+	for _, message := range r.messages {
+		if !message.DateTo.Before(date) {
+			result = append(result, message)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeScheduleMessages) UpdateLessons(ctx context.Context, id int64, lessons []models.Lesson) error {
+	r.messages[id-1].Lessons = lessons
+	return nil
+}
+
+func (r *fakeScheduleMessages) DeleteBefore(ctx context.Context, date time.Time) error {
+	return nil
+}
+
+type fakeWeekSchedule []models.Lesson
+
+func (s fakeWeekSchedule) CurrentWeekLessons(stream, substream string) ([]models.Lesson, error) {
+	return s, nil
+}
+
+func TestScheduleMessageOutdated(t *testing.T) {
+	// Friday
+	now := time.Date(2025, time.April, 25, 6, 0, 0, 0, time.UTC)
+	at := func(day, hour int) time.Time {
+		return time.Date(2025, time.April, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	thursday := models.Lesson{ID: "1", Name: "Go", Cabinet: "101", DateStart: at(24, 9), DateEnd: at(24, 10)}
+	friday := models.Lesson{ID: "2", Name: "Физика", Cabinet: "202", DateStart: at(25, 9), DateEnd: at(25, 10)}
+	saturday := models.Lesson{ID: "3", Name: "История", Cabinet: "303", DateStart: at(26, 9), DateEnd: at(26, 10)}
+
+	moved := friday
+	moved.Cabinet = "205"
+	recreated := saturday
+	recreated.ID = "30"
+
+	repo := &fakeScheduleMessages{}
+	messageService := NewScheduleMessage(repo, fakeWeekSchedule{thursday, moved, recreated})
+
+	messageId := int64(42)
+	track := func(week bool, lessons ...models.Lesson) {
+		message := models.ScheduleMessage{ChatID: 1, MessageID: &messageId, Stream: "ИС-21", Lessons: lessons}
+		require.NoError(t, messageService.Track(t.Context(), message, week))
+	}
+
+	track(false, thursday)
+	track(false, friday)
+	track(false, saturday)
+	track(true, thursday, friday, saturday)
+
+	assert.True(t, at(21, 0).Equal(repo.messages[3].DateFrom))
+	assert.True(t, at(27, 0).Equal(repo.messages[3].DateTo))
+
+	outdated, err := messageService.Outdated(t.Context(), now)
+	require.NoError(t, err)
+	require.Len(t, outdated, 2)
+
+	assert.Equal(t, int64(2), outdated[0].ID)
+	assert.Equal(t, []models.Lesson{moved}, outdated[0].Lessons)
+	assert.Equal(t, int64(4), outdated[1].ID)
+	assert.Equal(t, []models.Lesson{thursday, moved, recreated}, outdated[1].Lessons)
+
+	outdated, err = messageService.Outdated(t.Context(), now)
+	require.NoError(t, err)
+	assert.Empty(t, outdated)
+}
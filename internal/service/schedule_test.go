@@ -0,0 +1,54 @@
+package service
+
+import (
+	"pgtk-schedule/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSchedulePortal []models.Lesson
+
+func (p fakeSchedulePortal) Update() error {
+	return nil
+}
+
+func (p fakeSchedulePortal) CurrentWeekLessons(stream, substream string) ([]models.Lesson, error) {
+	return append([]models.Lesson(nil), p...), nil
+}
+
+func TestScheduleDateLessons(t *testing.T) {
+	loc := time.FixedZone("Asia/Yekaterinburg", 5*60*60)
+	monday := models.Lesson{ID: "1", Name: "Go", DateStart: time.Date(2025, time.February, 3, 16, 0, 0, 0, loc)}
+	tuesday := models.Lesson{ID: "2", Name: "Физика", DateStart: time.Date(2025, time.February, 4, 8, 30, 0, 0, loc)}
+	wednesday := models.Lesson{ID: "3", Name: "История", DateStart: time.Date(2025, time.February, 5, 0, 30, 0, 0, loc)}
+
+	s := NewSchedule(fakeSchedulePortal{wednesday, tuesday, monday}, loc)
+
+	t.Run("slot after local midnight gets the new day", func(t *testing.T) {
+		// 04:30 local is still the previous day in UTC
+		at := time.Date(2025, time.February, 4, 4, 30, 0, 0, loc)
+
+		lessons, err := s.DateLessons("stream", "", at)
+		require.NoError(t, err)
+		assert.Equal(t, []models.Lesson{tuesday}, lessons)
+
+		lessons, err = s.DateLessons("stream", "", at.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		assert.Equal(t, []models.Lesson{wednesday}, lessons)
+	})
+
+	t.Run("date in another zone is compared in the college timezone", func(t *testing.T) {
+		// 20:00 UTC is 01:00 of the next day in the college timezone
+		lessons, err := s.DateLessons("stream", "", time.Date(2025, time.February, 3, 20, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		assert.Equal(t, []models.Lesson{tuesday}, lessons)
+	})
+
+	t.Run("day without lessons", func(t *testing.T) {
+		_, err := s.DateLessons("stream", "", time.Date(2025, time.February, 6, 4, 30, 0, 0, loc))
+		require.ErrorIs(t, err, models.ErrLessonsAreEmpty)
+	})
+}
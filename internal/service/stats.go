@@ -0,0 +1,158 @@
+package service
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"pgtk-schedule/internal/models"
+	"slices"
+	"strings"
+	"time"
+)
+
+// academicHour is a duration of one academic hour. A regular pair takes two of them.
+const academicHour = 45 * time.Minute
+
+type statsPortal interface {
+	TermLessons(stream, substream string) ([]models.Lesson, error)
+}
+
+type stats struct {
+	portal statsPortal
+}
+
+func NewStats(portal statsPortal) *stats {
+	return &stats{
+		portal: portal,
+	}
+}
+
+// Term aggregates lessons of the stream over every week of the current term.
+func (s *stats) Term(stream, substream string) (models.Stats, error) {
+	lessons, err := s.portal.TermLessons(stream, substream)
+	if err != nil {
+		return models.Stats{}, err
+	}
+
+	return aggregateStats(lessons), nil
+}
+
+func aggregateStats(lessons []models.Lesson) models.Stats {
+	var result models.Stats
+
+	disciplines := make(map[string]*models.DisciplineStats)
+	types := make(map[string]map[string]*models.LessonTypeStats)
+	teachers := make(map[string]*models.TeacherStats)
+	weekdays := make(map[time.Weekday]int)
+
+	for _, lesson := range lessons {
+		hours := lessonHours(lesson)
+
+		result.Pairs++
+		result.Hours += hours
+
+		d, ok := disciplines[lesson.Name]
+		if !ok {
+			d = &models.DisciplineStats{Name: lesson.Name}
+			disciplines[lesson.Name] = d
+			types[lesson.Name] = make(map[string]*models.LessonTypeStats)
+		}
+		d.Pairs++
+		d.Hours += hours
+
+		lt, ok := types[lesson.Name][lesson.Type]
+		if !ok {
+			lt = &models.LessonTypeStats{Type: lesson.Type}
+			types[lesson.Name][lesson.Type] = lt
+		}
+		lt.Pairs++
+		lt.Hours += hours
+
+		if lesson.Teacher != "" {
+			t, ok := teachers[lesson.Teacher]
+			if !ok {
+				t = &models.TeacherStats{Name: lesson.Teacher}
+				teachers[lesson.Teacher] = t
+			}
+			t.Pairs++
+			t.Hours += hours
+		}
+
+		weekdays[lesson.DateStart.Weekday()]++
+	}
+
+	result.Disciplines = make([]models.DisciplineStats, 0, len(disciplines))
+	for name, d := range disciplines {
+		d.Types = make([]models.LessonTypeStats, 0, len(types[name]))
+		for _, lt := range types[name] {
+			d.Types = append(d.Types, *lt)
+		}
+		slices.SortFunc(d.Types, func(a, b models.LessonTypeStats) int {
+			return cmp.Or(cmp.Compare(b.Pairs, a.Pairs), strings.Compare(a.Type, b.Type))
+		})
+
+		result.Disciplines = append(result.Disciplines, *d)
+	}
+	slices.SortFunc(result.Disciplines, func(a, b models.DisciplineStats) int {
+		return cmp.Or(cmp.Compare(b.Pairs, a.Pairs), strings.Compare(a.Name, b.Name))
+	})
+
+	result.Teachers = make([]models.TeacherStats, 0, len(teachers))
+	for _, t := range teachers {
+		result.Teachers = append(result.Teachers, *t)
+	}
+	slices.SortFunc(result.Teachers, func(a, b models.TeacherStats) int {
+		return cmp.Or(cmp.Compare(b.Pairs, a.Pairs), strings.Compare(a.Name, b.Name))
+	})
+
+	result.Weekdays = make([]models.WeekdayStats, 0, len(weekdays))
+	for weekday, pairs := range weekdays {
+		result.Weekdays = append(result.Weekdays, models.WeekdayStats{Weekday: weekday, Pairs: pairs})
+	}
+	slices.SortFunc(result.Weekdays, func(a, b models.WeekdayStats) int {
+		return cmp.Or(cmp.Compare(b.Pairs, a.Pairs), cmp.Compare(weekdayIndex(a.Weekday), weekdayIndex(b.Weekday)))
+	})
+
+	return result
+}
+
+// lessonHours converts lesson duration to academic hours.
+func lessonHours(lesson models.Lesson) int {
+	duration := lesson.DateEnd.Sub(lesson.DateStart)
+	if duration <= 0 {
+		return 0
+	}
+
+	return int(math.Round(float64(duration) / float64(academicHour)))
+}
+
+// weekdayIndex makes monday the first day of the week.
+func weekdayIndex(weekday time.Weekday) int {
+	return (int(weekday) + 6) % 7
+}
+
+func (s *stats) StatsToString(stats models.Stats) string {
+	sb := strings.Builder{}
+
+	sb.WriteString(fmt.Sprintf("<b>📊 Статистика за семестр</b>\nВсего пар: %d (%d ак. ч.)\n\n", stats.Pairs, stats.Hours))
+
+	sb.WriteString("<b>📚 Дисциплины</b>\n")
+	for _, d := range stats.Disciplines {
+		sb.WriteString(fmt.Sprintf("<b>%s</b>: %d пар (%d ак. ч.)\n", d.Name, d.Pairs, d.Hours))
+		for _, lt := range d.Types {
+			sb.WriteString(fmt.Sprintf("  • %s: %d пар (%d ак. ч.)\n", lt.Type, lt.Pairs, lt.Hours))
+		}
+	}
+
+	sb.WriteString("\n<b>👨‍🏫 Преподаватели</b>\n")
+	for _, t := range stats.Teachers {
+		sb.WriteString(fmt.Sprintf("%s: %d пар (%d ак. ч.)\n", t.Name, t.Pairs, t.Hours))
+	}
+
+	sb.WriteString("\n<b>📆 Самые загруженные дни</b>\n")
+	for _, w := range stats.Weekdays {
+		sb.WriteString(fmt.Sprintf("%s: %d пар\n", weekdays[w.Weekday], w.Pairs))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"pgtk-schedule/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStatsPortal struct {
+	lessons []models.Lesson
+	err     error
+}
+
+func (p *fakeStatsPortal) TermLessons(stream, substream string) ([]models.Lesson, error) {
+	return p.lessons, p.err
+}
+
+func TestStatsTerm(t *testing.T) {
+	monday := time.Date(2025, time.February, 3, 9, 0, 0, 0, time.UTC)
+	tuesday := monday.AddDate(0, 0, 1)
+	nextMonday := monday.AddDate(0, 0, 7)
+	pair := 90 * time.Minute
+
+	portal := &fakeStatsPortal{
+		lessons: []models.Lesson{
+			{Name: "Go", Type: "лекция", Teacher: "Иванов И.И.", DateStart: monday, DateEnd: monday.Add(pair)},
+			{Name: "Go", Type: "практика", Teacher: "Иванов И.И.", DateStart: tuesday, DateEnd: tuesday.Add(pair)},
+			{Name: "Go", Type: "лекция", Teacher: "Петров П.П.", DateStart: nextMonday, DateEnd: nextMonday.Add(pair)},
+			{Name: "Физика", Type: "лекция", Teacher: "Петров П.П.", DateStart: nextMonday.Add(2 * time.Hour), DateEnd: nextMonday.Add(2*time.Hour + 45*time.Minute)},
+		},
+	}
+
+	s := NewStats(portal)
+
+	stats, err := s.Term("1", "")
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, stats.Pairs)
+	assert.Equal(t, 7, stats.Hours)
+
+	assert.Equal(t, []models.DisciplineStats{
+		{
+			Name:  "Go",
+			Pairs: 3,
+			Hours: 6,
+			Types: []models.LessonTypeStats{
+				{Type: "лекция", Pairs: 2, Hours: 4},
+				{Type: "практика", Pairs: 1, Hours: 2},
+			},
+		},
+		{
+			Name:  "Физика",
+			Pairs: 1,
+			Hours: 1,
+			Types: []models.LessonTypeStats{
+				{Type: "лекция", Pairs: 1, Hours: 1},
+			},
+		},
+	}, stats.Disciplines)
+
+	assert.Equal(t, []models.TeacherStats{
+		{Name: "Иванов И.И.", Pairs: 2, Hours: 4},
+		{Name: "Петров П.П.", Pairs: 2, Hours: 3},
+	}, stats.Teachers)
+
+	assert.Equal(t, []models.WeekdayStats{
+		{Weekday: time.Monday, Pairs: 3},
+		{Weekday: time.Tuesday, Pairs: 1},
+	}, stats.Weekdays)
+}
+
+func TestStatsTermError(t *testing.T) {
+	s := NewStats(&fakeStatsPortal{err: models.ErrLessonsAreEmpty})
+
+	_, err := s.Term("1", "")
+	assert.ErrorIs(t, err, models.ErrLessonsAreEmpty)
+}
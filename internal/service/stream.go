@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"pgtk-schedule/internal/models"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+type streamRepository interface {
+	Save(ctx context.Context, streams []models.Stream) error
+	FindStale(ctx context.Context, current []string) ([]models.StaleStream, error)
+	Rollover(ctx context.Context, mappings []models.StreamMapping) (int64, error)
+}
+
+type streamPortal interface {
+	Streams() []models.Stream
+}
+
+type stream struct {
+	repo   streamRepository
+	portal streamPortal
+}
+
+func NewStream(repo streamRepository, portal streamPortal) *stream {
+	return &stream{
+		repo:   repo,
+		portal: portal,
+	}
+}
+
+// Remember saves streams from the portal, so their names are known after they disappear.
+func (s *stream) Remember(ctx context.Context) error {
+	streams := s.portal.Streams()
+	if len(streams) == 0 {
+		return nil
+	}
+
+	return s.repo.Save(ctx, streams)
+}
+
+// Propose matches streams selected by students, but missing on the portal, to current streams
+// with the same name or the name of the next course.
+func (s *stream) Propose(ctx context.Context) ([]models.StreamMapping, error) {
+	current := s.portal.Streams()
+	if len(current) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, 0, len(current))
+	for _, stream := range current {
+		ids = append(ids, stream.ID)
+	}
+
+	stale, err := s.repo.FindStale(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return proposeMappings(stale, current), nil
+}
+
+// Apply moves students and chats by mappings reviewed by the admin and returns the number of moved students.
+// Mappings without a new stream are skipped.
+func (s *stream) Apply(ctx context.Context, mappings []models.StreamMapping) (int64, error) {
+	return s.repo.Rollover(ctx, mappings)
+}
+
+// proposeMappings picks the current stream with the same name or the name of the next course for every stale one.
+// Ambiguous matches are left without a new stream.
+func proposeMappings(stale []models.StaleStream, current []models.Stream) []models.StreamMapping {
+	mappings := make([]models.StreamMapping, 0, len(stale))
+	for _, old := range stale {
+		mapping := models.StreamMapping{Old: old}
+
+		// Streams renamed without a new course are preferred to the next course
+		for _, match := range []func(a, b string) bool{sameStreamName, nextCourseName} {
+			var found []int
+			for i, stream := range current {
+				if match(old.Name, stream.Name) {
+					found = append(found, i)
+				}
+			}
+
+			if len(found) == 1 {
+				mapping.New = &current[found[0]]
+			}
+			if len(found) > 0 {
+				break
+			}
+		}
+
+		mappings = append(mappings, mapping)
+	}
+
+	return mappings
+}
+
+// sameStreamName compares stream names ignoring case and punctuation.
+func sameStreamName(a, b string) bool {
+	ra, rb := normalizeStreamName(a), normalizeStreamName(b)
+	return len(ra) > 0 && string(ra) == string(rb)
+}
+
+// nextCourseName reports whether b is the name of a for the next course, e.g. "ИСП-31" for "ИСП-21".
+// Only the first digit, which is the course, may differ, so other groups of the same course are not matched.
+func nextCourseName(a, b string) bool {
+	ra, rb := normalizeStreamName(a), normalizeStreamName(b)
+	if len(ra) != len(rb) {
+		return false
+	}
+
+	course := slices.IndexFunc(ra, unicode.IsDigit)
+	if course == -1 || !unicode.IsDigit(rb[course]) || rb[course] != ra[course]+1 {
+		return false
+	}
+
+	return string(ra[:course]) == string(rb[:course]) && string(ra[course+1:]) == string(rb[course+1:])
+}
+
+func normalizeStreamName(s string) []rune {
+	return []rune(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s))
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"pgtk-schedule/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextCourseName(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     string
+		expected bool
+	}{
+		{name: "next course", a: "ИСП-21", b: "ИСП-31", expected: true},
+		{name: "case and punctuation", a: "исп 21", b: "ИСП-31", expected: true},
+		{name: "other group of the course", a: "ПКС-30", b: "ПКС-31", expected: false},
+		{name: "previous course", a: "ИСП-21", b: "ИСП-11", expected: false},
+		{name: "other specialty", a: "ИСП-21", b: "ИСС-31", expected: false},
+		{name: "same name", a: "ИСП-21", b: "ИСП-21", expected: false},
+		{name: "no digits", a: "ИСП", b: "ИСП", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, nextCourseName(tt.a, tt.b))
+		})
+	}
+}
+
+func TestProposeMappings(t *testing.T) {
+	current := []models.Stream{
+		{ID: "201", Name: "ИСП-21"},
+		{ID: "202", Name: "ИСП-32"},
+		{ID: "203", Name: "ИСП-31"},
+		{ID: "204", Name: "ПКС-31"},
+		{ID: "205", Name: "ТМ 21"},
+		{ID: "206", Name: "тм-21"},
+	}
+	stale := func(id, name string) models.StaleStream {
+		return models.StaleStream{Stream: models.Stream{ID: id, Name: name}, Students: 1}
+	}
+
+	mappings := proposeMappings([]models.StaleStream{
+		stale("101", "ИСП-21"),
+		stale("102", "ПКС-30"),
+		stale("103", "ИСП-22"),
+		stale("104", "ТМ-11"),
+		stale("105", "ИСП-23"),
+	}, current)
+
+	// ИСП-21 keeps the name, ПКС-30 is not the previous course of ПКС-31, ТМ-11 has two next courses
+	expected := []*models.Stream{&current[0], nil, &current[1], nil, nil}
+	assert.Len(t, mappings, len(expected))
+	for i, mapping := range mappings {
+		assert.Equal(t, expected[i], mapping.New, mapping.Old.Name)
+	}
+}
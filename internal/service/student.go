@@ -5,15 +5,26 @@ import (
 	"log"
 	"math"
 	"pgtk-schedule/internal/models"
+	"time"
 )
 
+// streamAbsences is how many refreshes in a row a group must be missing from the portal to be marked missing.
+const streamAbsences = 3
+
 type studentRepository interface {
 	Create(ctx context.Context, id int64, nickname string) error
 	FindByID(ctx context.Context, id int64) (models.Student, error)
 	UpdateStream(ctx context.Context, id int64, stream string) error
 	UpdateSubstream(ctx context.Context, id int64, substream string) error
 	UpdateNickname(ctx context.Context, id int64, nickname string) error
+	UpdateTeacher(ctx context.Context, id int64, teacher string) error
+	Deactivate(ctx context.Context, id int64) error
+	Activate(ctx context.Context, id int64) error
 	FindAll(ctx context.Context, id int64, limit int) ([]models.Student, int64, error)
+	FindAllDue(ctx context.Context, kind models.NotifyKind, minute int, date time.Time, id int64, limit int) ([]models.Student, int64, error)
+	FindAllSkipped(ctx context.Context, kind models.NotifyKind, minute int, date time.Time, id int64, limit int) ([]models.Student, int64, error)
+	MarkMissingStreams(ctx context.Context, streams []models.Stream, absences int) ([]int64, error)
+	UpdateOnboardingStep(ctx context.Context, id int64, step string) error
 }
 
 type student struct {
@@ -46,12 +57,63 @@ func (s *student) UpdateNickname(ctx context.Context, id int64, nickname string)
 	return s.repo.UpdateNickname(ctx, id, nickname)
 }
 
+func (s *student) UpdateTeacher(ctx context.Context, id int64, teacher string) error {
+	return s.repo.UpdateTeacher(ctx, id, teacher)
+}
+
+func (s *student) UpdateOnboardingStep(ctx context.Context, id int64, step string) error {
+	return s.repo.UpdateOnboardingStep(ctx, id, step)
+}
+
+func (s *student) Deactivate(ctx context.Context, id int64) error {
+	return s.repo.Deactivate(ctx, id)
+}
+
+func (s *student) Activate(ctx context.Context, id int64) error {
+	return s.repo.Activate(ctx, id)
+}
+
+// MarkMissingStreams marks students whose group has disappeared from streams and returns the ones to prompt.
+// An empty list means the portal has not been loaded, so nobody is marked. A group is missing only after
+// streamAbsences refreshes in a row, so a single broken portal response does not pause notifications.
+func (s *student) MarkMissingStreams(ctx context.Context, streams []models.Stream) ([]int64, error) {
+	if len(streams) == 0 {
+		return nil, nil
+	}
+
+	return s.repo.MarkMissingStreams(ctx, streams, streamAbsences)
+}
+
 func (s *student) ForEach(fn func(student models.Student) error) {
+	s.forEach(func(lastId int64, limit int) ([]models.Student, int64, error) {
+		return s.repo.FindAll(context.Background(), lastId, limit)
+	}, fn)
+}
+
+// ForEachDue calls fn for every student whose notification of the kind is scheduled at the minute.
+// Students with paused notifications are skipped.
+func (s *student) ForEachDue(kind models.NotifyKind, at time.Time, fn func(student models.Student) error) {
+	minute := at.Hour()*60 + at.Minute()
+	s.forEach(func(lastId int64, limit int) ([]models.Student, int64, error) {
+		return s.repo.FindAllDue(context.Background(), kind, minute, at, lastId, limit)
+	}, fn)
+}
+
+// ForEachSkipped calls fn for every student whose notification of the kind is scheduled at the minute,
+// but is disabled, paused or the student is inactive.
+func (s *student) ForEachSkipped(kind models.NotifyKind, at time.Time, fn func(student models.Student) error) {
+	minute := at.Hour()*60 + at.Minute()
+	s.forEach(func(lastId int64, limit int) ([]models.Student, int64, error) {
+		return s.repo.FindAllSkipped(context.Background(), kind, minute, at, lastId, limit)
+	}, fn)
+}
+
+func (s *student) forEach(find func(lastId int64, limit int) ([]models.Student, int64, error), fn func(student models.Student) error) {
 	const limit = 25
 	var lastId int64 = math.MinInt64
 
 	for {
-		students, currentLastId, err := s.repo.FindAll(context.Background(), lastId, limit)
+		students, currentLastId, err := find(lastId, limit)
 		if err != nil {
 			log.Println(err.Error())
 			return
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"pgtk-schedule/internal/models"
+)
+
+type subscriptionRepository interface {
+	Create(ctx context.Context, studentId int64, stream, substream, label string) error
+	Replace(ctx context.Context, studentId int64, previousStream, previousSubstream, stream, substream, label string) error
+	FindByID(ctx context.Context, studentId, id int64) (models.Subscription, error)
+	FindByStudentID(ctx context.Context, studentId int64) ([]models.Subscription, error)
+	ToggleNotify(ctx context.Context, studentId, id int64) error
+	Delete(ctx context.Context, studentId, id int64) error
+}
+
+type subscriptionStudentRepository interface {
+	FindByID(ctx context.Context, id int64) (models.Student, error)
+	UpdateGroup(ctx context.Context, id int64, stream, substream string) error
+}
+
+type subscriptionPortal interface {
+	Streams() []models.Stream
+}
+
+type subscription struct {
+	repo        subscriptionRepository
+	studentRepo subscriptionStudentRepository
+	portal      subscriptionPortal
+}
+
+func NewSubscription(repo subscriptionRepository, studentRepo subscriptionStudentRepository, portal subscriptionPortal) *subscription {
+	return &subscription{
+		repo:        repo,
+		studentRepo: studentRepo,
+		portal:      portal,
+	}
+}
+
+// Follow adds the group to student subscriptions. Following the same group twice is a no-op.
+func (s *subscription) Follow(ctx context.Context, studentId int64, stream, substream string) error {
+	return s.repo.Create(ctx, studentId, stream, substream, s.label(stream, substream))
+}
+
+// Replace sets the group of the student instead of the previous one, other subscriptions are kept.
+// Subscriptions are replaced before the group, so a failed call can be repeated.
+func (s *subscription) Replace(ctx context.Context, studentId int64, stream, substream string) error {
+	student, err := s.studentRepo.FindByID(ctx, studentId)
+	if err != nil {
+		return err
+	}
+
+	var previousStream, previousSubstream string
+	if student.Stream != nil {
+		previousStream = *student.Stream
+	}
+	if student.Substream != nil {
+		previousSubstream = *student.Substream
+	}
+
+	if err := s.repo.Replace(ctx, studentId, previousStream, previousSubstream, stream, substream, s.label(stream, substream)); err != nil {
+		return err
+	}
+
+	return s.studentRepo.UpdateGroup(ctx, studentId, stream, substream)
+}
+
+func (s *subscription) FindByStudentID(ctx context.Context, studentId int64) ([]models.Subscription, error) {
+	return s.repo.FindByStudentID(ctx, studentId)
+}
+
+func (s *subscription) ToggleNotify(ctx context.Context, studentId, id int64) error {
+	return s.repo.ToggleNotify(ctx, studentId, id)
+}
+
+func (s *subscription) Delete(ctx context.Context, studentId, id int64) error {
+	return s.repo.Delete(ctx, studentId, id)
+}
+
+// Notified returns groups with enabled notifications.
+// Students without subscriptions are notified about their current group.
+func (s *subscription) Notified(ctx context.Context, student models.Student) ([]models.Subscription, error) {
+	all, err := s.repo.FindByStudentID(ctx, student.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(all) == 0 {
+		if student.Stream == nil {
+			return nil, nil
+		}
+
+		substream := ""
+		if student.Substream != nil {
+			substream = *student.Substream
+		}
+
+		return []models.Subscription{{StudentID: student.ID, Stream: *student.Stream, Substream: substream, Notify: true}}, nil
+	}
+
+	subscriptions := make([]models.Subscription, 0, len(all))
+	for _, subscription := range all {
+		if subscription.Notify {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+
+	return subscriptions, nil
+}
+
+// Activate makes the subscription the group used by schedule buttons.
+func (s *subscription) Activate(ctx context.Context, studentId, id int64) (models.Subscription, error) {
+	subscription, err := s.repo.FindByID(ctx, studentId, id)
+	if err != nil {
+		return models.Subscription{}, err
+	}
+
+	if err := s.studentRepo.UpdateGroup(ctx, studentId, subscription.Stream, subscription.Substream); err != nil {
+		return models.Subscription{}, err
+	}
+
+	return subscription, nil
+}
+
+// Title returns the label of the subscription or builds it from the portal when label is missing.
+func (s *subscription) Title(subscription models.Subscription) string {
+	if subscription.Label != "" {
+		return subscription.Label
+	}
+
+	return s.label(subscription.Stream, subscription.Substream)
+}
+
+func (s *subscription) label(stream, substream string) string {
+	name := stream
+	for _, st := range s.portal.Streams() {
+		if st.ID == stream {
+			name = st.Name
+			break
+		}
+	}
+
+	if substream == "" {
+		return name
+	}
+
+	return name + " (" + substream + ")"
+}
@@ -1,9 +1,13 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"pgtk-schedule/internal/models"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -13,32 +17,117 @@ type teacherPortal interface {
 }
 
 type scheduleService interface {
+	CurrentWeekLessons(stream, substream string) ([]models.Lesson, error)
 	TodayLessons(stream, substream string) ([]models.Lesson, error)
+	TomorrowLessons(stream, substream string) ([]models.Lesson, error)
+	DateLessons(stream, substream string, date time.Time) ([]models.Lesson, error)
+}
+
+type teacherRepository interface {
+	CreateMany(ctx context.Context, names []string) error
+	FindAll(ctx context.Context) ([]models.Teacher, error)
 }
 
 type teacher struct {
 	portal          teacherPortal
 	scheduleService scheduleService
+	repo            teacherRepository
+
+	ids   map[string]int64
+	names map[int64]string
+	mu    sync.RWMutex
 }
 
-func NewTeacher(portal teacherPortal, scheduleService scheduleService) *teacher {
+func NewTeacher(portal teacherPortal, scheduleService scheduleService, repo teacherRepository) *teacher {
 	return &teacher{
 		portal:          portal,
 		scheduleService: scheduleService,
+		repo:            repo,
+		ids:             make(map[string]int64),
+		names:           make(map[int64]string),
 	}
 }
 
-func (t *teacher) TodayList() ([]string, error) {
-	loc, err := time.LoadLocation(t.portal.Timezone())
+// Index assigns identifiers to teachers of the current week. Identifiers are persisted, so they survive updates and restarts.
+func (t *teacher) Index(ctx context.Context) error {
+	names, err := t.teacherNames(t.scheduleService.CurrentWeekLessons)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	now := time.Now().In(loc)
+	return t.index(ctx, names)
+}
+
+func (t *teacher) index(ctx context.Context, names []string) error {
+	if err := t.repo.CreateMany(ctx, names); err != nil {
+		return err
+	}
+
+	teachers, err := t.repo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	ids := make(map[string]int64, len(teachers))
+	byID := make(map[int64]string, len(teachers))
+	for _, teacher := range teachers {
+		ids[teacher.Name] = teacher.ID
+		byID[teacher.ID] = teacher.Name
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ids = ids
+	t.names = byID
+
+	return nil
+}
+
+// withIDs attaches identifiers to names. Unknown names are indexed on the fly.
+func (t *teacher) withIDs(names []string) ([]models.Teacher, error) {
+	t.mu.RLock()
+	missing := slices.ContainsFunc(names, func(name string) bool {
+		_, ok := t.ids[name]
+		return !ok
+	})
+	t.mu.RUnlock()
+
+	if missing {
+		if err := t.index(context.Background(), names); err != nil {
+			return nil, err
+		}
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	teachers := make([]models.Teacher, 0, len(names))
+	for _, name := range names {
+		teachers = append(teachers, models.Teacher{ID: t.ids[name], Name: name})
+	}
+
+	return teachers, nil
+}
+
+// Resolve returns the teacher by identifier.
+func (t *teacher) Resolve(id int64) (models.Teacher, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	name, ok := t.names[id]
+	if !ok {
+		return models.Teacher{}, models.ErrTeacherNotFound
+	}
+
+	return models.Teacher{ID: id, Name: name}, nil
+}
 
+// collect returns lessons of every stream and substream. Lessons shared by substreams are returned once.
+func (t *teacher) collect(lessonsFn func(stream, substream string) ([]models.Lesson, error)) ([]models.Lesson, error) {
 	streams := t.portal.Streams()
 
-	teacherSet := make(map[string]struct{}, 0)
+	seen := make(map[string]struct{})
+	var result []models.Lesson
 
 	for _, stream := range streams {
 		substreams := stream.Substreams
@@ -47,7 +136,7 @@ func (t *teacher) TodayList() ([]string, error) {
 		}
 
 		for _, substream := range substreams {
-			lessons, err := t.scheduleService.TodayLessons(stream.ID, substream)
+			lessons, err := lessonsFn(stream.ID, substream)
 			if err != nil {
 				if errors.Is(err, models.ErrLessonsAreEmpty) {
 					continue
@@ -56,20 +145,80 @@ func (t *teacher) TodayList() ([]string, error) {
 			}
 
 			for _, lesson := range lessons {
-				if now.Before(lesson.DateEnd) {
-					teacherSet[lesson.Teacher] = struct{}{}
+				key := lesson.Stream + "/" + lesson.ID
+				if _, ok := seen[key]; ok {
+					continue
 				}
+				seen[key] = struct{}{}
+
+				result = append(result, lesson)
 			}
 		}
 	}
 
+	return result, nil
+}
+
+func (t *teacher) TodayList() ([]models.Teacher, error) {
+	loc, err := time.LoadLocation(t.portal.Timezone())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().In(loc)
+
+	lessons, err := t.collect(t.scheduleService.TodayLessons)
+	if err != nil {
+		return nil, err
+	}
+
+	teacherSet := make(map[string]struct{}, 0)
+
+	for _, lesson := range lessons {
+		if lesson.Teacher != "" && now.Before(lesson.DateEnd) {
+			teacherSet[lesson.Teacher] = struct{}{}
+		}
+	}
+
 	teachers := make([]string, 0, len(teacherSet))
 
 	for teacher := range teacherSet {
 		teachers = append(teachers, teacher)
 	}
 
-	return teachers, nil
+	slices.Sort(teachers)
+
+	return t.withIDs(teachers)
+}
+
+// List returns sorted teachers having lessons on the current week.
+func (t *teacher) List() ([]models.Teacher, error) {
+	names, err := t.teacherNames(t.scheduleService.CurrentWeekLessons)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.withIDs(names)
+}
+
+func (t *teacher) teacherNames(lessonsFn func(stream, substream string) ([]models.Lesson, error)) ([]string, error) {
+	lessons, err := t.collect(lessonsFn)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	for _, lesson := range lessons {
+		if lesson.Teacher == "" || slices.Contains(names, lesson.Teacher) {
+			continue
+		}
+
+		names = append(names, lesson.Teacher)
+	}
+
+	slices.Sort(names)
+
+	return names, nil
 }
 
 func (t *teacher) Find(teacher string) (models.Lesson, error) {
@@ -80,44 +229,30 @@ func (t *teacher) Find(teacher string) (models.Lesson, error) {
 
 	now := time.Now().In(loc)
 
-	streams := t.portal.Streams()
+	lessons, err := t.collect(t.scheduleService.TodayLessons)
+	if err != nil {
+		return models.Lesson{}, err
+	}
 
 	var nearestLesson models.Lesson
 
-	for _, stream := range streams {
-		substreams := stream.Substreams
-		if len(stream.Substreams) == 0 {
-			substreams = []string{""}
+	for _, lesson := range lessons {
+		if lesson.Teacher != teacher {
+			continue
 		}
 
-		for _, substream := range substreams {
-			lessons, err := t.scheduleService.TodayLessons(stream.ID, substream)
-			if err != nil {
-				if errors.Is(err, models.ErrLessonsAreEmpty) {
-					continue
-				}
-				return models.Lesson{}, err
-			}
-
-			for _, lesson := range lessons {
-				if !strings.Contains(lesson.Teacher, teacher) {
-					continue
-				}
-
-				if now.After(lesson.DateEnd) {
-					continue
-				}
+		if now.After(lesson.DateEnd) {
+			continue
+		}
 
-				if nearestLesson.ID == "" {
-					nearestLesson = lesson
-					continue
-				}
+		if nearestLesson.ID == "" {
+			nearestLesson = lesson
+			continue
+		}
 
-				if lesson.DateEnd.Before(nearestLesson.DateEnd) {
-					nearestLesson = lesson
-					continue
-				}
-			}
+		if lesson.DateEnd.Before(nearestLesson.DateEnd) {
+			nearestLesson = lesson
+			continue
 		}
 	}
 
@@ -127,3 +262,66 @@ func (t *teacher) Find(teacher string) (models.Lesson, error) {
 
 	return nearestLesson, nil
 }
+
+func (t *teacher) WeekLessons(teacher string) ([]models.Lesson, error) {
+	return t.teacherLessons(teacher, t.scheduleService.CurrentWeekLessons)
+}
+
+func (t *teacher) TodayLessons(teacher string) ([]models.Lesson, error) {
+	return t.teacherLessons(teacher, t.scheduleService.TodayLessons)
+}
+
+func (t *teacher) TomorrowLessons(teacher string) ([]models.Lesson, error) {
+	return t.teacherLessons(teacher, t.scheduleService.TomorrowLessons)
+}
+
+// DateLessons returns lessons of the teacher on the date within the current week.
+func (t *teacher) DateLessons(teacher string, date time.Time) ([]models.Lesson, error) {
+	return t.teacherLessons(teacher, func(stream, substream string) ([]models.Lesson, error) {
+		return t.scheduleService.DateLessons(stream, substream, date)
+	})
+}
+
+func (t *teacher) teacherLessons(teacher string, lessonsFn func(stream, substream string) ([]models.Lesson, error)) ([]models.Lesson, error) {
+	all, err := t.collect(lessonsFn)
+	if err != nil {
+		return nil, err
+	}
+
+	lessons := make([]models.Lesson, 0)
+	for _, lesson := range all {
+		if lesson.Teacher == teacher {
+			lessons = append(lessons, lesson)
+		}
+	}
+
+	if len(lessons) == 0 {
+		return nil, models.ErrLessonsAreEmpty
+	}
+
+	slices.SortFunc(lessons, func(a, b models.Lesson) int {
+		return a.DateStart.Compare(b.DateStart)
+	})
+
+	return lessons, nil
+}
+
+// LessonsToString renders teacher lessons with groups instead of the teacher name.
+func (t *teacher) LessonsToString(lessons []models.Lesson) string {
+	names := make(map[string]string)
+	for _, stream := range t.portal.Streams() {
+		names[stream.ID] = stream.Name
+	}
+
+	return lessonsToString(lessons, func(i int, l models.Lesson) string {
+		group := names[l.Stream]
+		if group == "" {
+			group = l.Stream
+		}
+		if strings.Contains(l.Type, "подгрупп") && l.Substream != "" {
+			group += " (" + l.Substream + ")"
+		}
+
+		return fmt.Sprintf("<b>%d)</b> %s (%s)\nГруппа: %s\nВремя: %s-%s\nКабинет: %s", i+1, l.Name, l.Type, group, l.DateStart.Format("15:04"), l.DateEnd.Format("15:04"), l.Cabinet)
+	})
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"pgtk-schedule/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTeacherPortal struct {
+	streams []models.Stream
+}
+
+func (p *fakeTeacherPortal) Streams() []models.Stream {
+	return p.streams
+}
+
+func (p *fakeTeacherPortal) Timezone() string {
+	return "UTC"
+}
+
+type fakeTeacherSchedule map[string][]models.Lesson
+
+func (s fakeTeacherSchedule) lessons(stream, substream string) ([]models.Lesson, error) {
+	lessons, ok := s[stream+"/"+substream]
+	if !ok {
+		return nil, models.ErrLessonsAreEmpty
+	}
+	return lessons, nil
+}
+
+func (s fakeTeacherSchedule) CurrentWeekLessons(stream, substream string) ([]models.Lesson, error) {
+	return s.lessons(stream, substream)
+}
+
+func (s fakeTeacherSchedule) TodayLessons(stream, substream string) ([]models.Lesson, error) {
+	return s.lessons(stream, substream)
+}
+
+func (s fakeTeacherSchedule) TomorrowLessons(stream, substream string) ([]models.Lesson, error) {
+	return s.lessons(stream, substream)
+}
+
+func (s fakeTeacherSchedule) DateLessons(stream, substream string, date time.Time) ([]models.Lesson, error) {
+	return s.lessons(stream, substream)
+}
+
+type fakeTeacherRepository struct {
+	teachers []models.Teacher
+}
+
+func (r *fakeTeacherRepository) CreateMany(ctx context.Context, names []string) error {
+	for _, name := range names {
+		known := false
+		for _, teacher := range r.teachers {
+			if teacher.Name == name {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			var id int64 = 1
+			if len(r.teachers) > 0 {
+				id = r.teachers[len(r.teachers)-1].ID + 1
+			}
+			r.teachers = append(r.teachers, models.Teacher{ID: id, Name: name})
+		}
+	}
+
+	return nil
+}
+
+func (r *fakeTeacherRepository) FindAll(ctx context.Context) ([]models.Teacher, error) {
+	return r.teachers, nil
+}
+
+func TestTeacherWeekLessons(t *testing.T) {
+	monday := time.Date(2025, time.February, 3, 9, 0, 0, 0, time.UTC)
+
+	lecture := models.Lesson{ID: "1", Name: "Go", Teacher: "Иванов Иван Иванович", Stream: "1", DateStart: monday.Add(2 * time.Hour)}
+	practice := models.Lesson{ID: "2", Name: "Go", Teacher: "Иванов Иван Иванович", Stream: "2", DateStart: monday}
+	other := models.Lesson{ID: "3", Name: "Физика", Teacher: "Петров Пётр Петрович", Stream: "2", DateStart: monday}
+
+	portal := &fakeTeacherPortal{
+		streams: []models.Stream{
+			{ID: "1", Name: "ИСП-21", Substreams: []string{"A", "B"}},
+			{ID: "2", Name: "ИСП-22"},
+		},
+	}
+	schedule := fakeTeacherSchedule{
+		"1/A": {lecture},
+		"1/B": {lecture},
+		"2/":  {practice, other},
+	}
+
+	teacher := NewTeacher(portal, schedule, &fakeTeacherRepository{})
+
+	lessons, err := teacher.WeekLessons("Иванов Иван Иванович")
+	require.NoError(t, err)
+	assert.Equal(t, []models.Lesson{practice, lecture}, lessons)
+
+	_, err = teacher.WeekLessons("Сидоров Сидор Сидорович")
+	assert.ErrorIs(t, err, models.ErrLessonsAreEmpty)
+
+	teachers, err := teacher.List()
+	require.NoError(t, err)
+	assert.Equal(t, []models.Teacher{
+		{ID: 1, Name: "Иванов Иван Иванович"},
+		{ID: 2, Name: "Петров Пётр Петрович"},
+	}, teachers)
+}
+
+func TestTeacherResolveNamesakes(t *testing.T) {
+	monday := time.Date(2025, time.February, 3, 9, 0, 0, 0, time.UTC)
+
+	portal := &fakeTeacherPortal{
+		streams: []models.Stream{{ID: "1", Name: "ИСП-21"}},
+	}
+	schedule := fakeTeacherSchedule{
+		"1/": {
+			{ID: "1", Name: "Go", Teacher: "Иванов Иван Петрович", Stream: "1", DateStart: monday},
+			{ID: "2", Name: "Go", Teacher: "Иванов Иван Иванович", Stream: "1", DateStart: monday},
+		},
+	}
+	repo := &fakeTeacherRepository{
+		teachers: []models.Teacher{{ID: 7, Name: "Иванов Иван Петрович"}},
+	}
+
+	teacher := NewTeacher(portal, schedule, repo)
+	require.NoError(t, teacher.Index(t.Context()))
+
+	petrovich, err := teacher.Resolve(7)
+	require.NoError(t, err)
+	assert.Equal(t, "Иванов Иван Петрович", petrovich.Name)
+
+	ivanovich, err := teacher.Resolve(8)
+	require.NoError(t, err)
+	assert.Equal(t, "Иванов Иван Иванович", ivanovich.Name)
+
+	_, err = teacher.Resolve(9)
+	assert.ErrorIs(t, err, models.ErrTeacherNotFound)
+}
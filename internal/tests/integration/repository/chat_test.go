@@ -0,0 +1,136 @@
+//go:build integration
+
+package repository
+
+import (
+	"os"
+	"pgtk-schedule/internal/models"
+	"pgtk-schedule/internal/repository"
+	"pgtk-schedule/pkg/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChat(t *testing.T) {
+	dbConn := os.Getenv("DB_CONN")
+	if dbConn == "" {
+		t.Skip("DB_CONN not found")
+	}
+
+	pool, err := database.NewPgx(dbConn)
+	require.NoError(t, err)
+
+	for _, table := range []string{"chats", "schedule_messages"} {
+		_, err = pool.Exec(t.Context(), "DELETE FROM "+table)
+		require.NoError(t, err)
+	}
+
+	chatRepo := repository.NewChat(pool)
+	// Monday
+	morning := time.Date(2025, time.May, 12, 5, 0, 0, 0, time.UTC)
+
+	t.Run("save chat", func(t *testing.T) {
+		require.NoError(t, chatRepo.Save(t.Context(), -100, "ИС-21"))
+
+		chat, err := chatRepo.FindByID(t.Context(), -100)
+		require.NoError(t, err)
+		assert.Equal(t, models.Chat{ID: -100, Title: "ИС-21", Active: true, MorningTime: 5 * 60, EveningTime: 18 * 60, Pin: true}, chat)
+
+		_, err = chatRepo.FindByID(t.Context(), -1)
+		assert.ErrorIs(t, err, models.ErrChatNotFound)
+	})
+
+	t.Run("unbound chat is not due", func(t *testing.T) {
+		chats, _, err := chatRepo.FindAllDue(t.Context(), models.NotifyMorning, 5*60, morning, -1000, 10)
+		require.NoError(t, err)
+		assert.Empty(t, chats)
+	})
+
+	t.Run("bound chat is due", func(t *testing.T) {
+		require.NoError(t, chatRepo.UpdateStream(t.Context(), -100, "stream", ""))
+		assert.ErrorIs(t, chatRepo.UpdateStream(t.Context(), -1, "stream", ""), models.ErrChatNotFound)
+
+		chats, lastId, err := chatRepo.FindAllDue(t.Context(), models.NotifyMorning, 5*60, morning, -1000, 10)
+		require.NoError(t, err)
+		require.Len(t, chats, 1)
+		assert.Equal(t, int64(-100), lastId)
+		assert.Nil(t, chats[0].Substream)
+
+		chats, _, err = chatRepo.FindAllDue(t.Context(), models.NotifyWeek, 5*60, morning, -1000, 10)
+		require.NoError(t, err)
+		assert.Empty(t, chats)
+	})
+
+	t.Run("chat settings", func(t *testing.T) {
+		require.NoError(t, chatRepo.ShiftTime(t.Context(), -100, models.NotifyMorning, -310))
+		require.NoError(t, chatRepo.TogglePin(t.Context(), -100))
+		assert.ErrorIs(t, chatRepo.ShiftTime(t.Context(), -100, models.NotifyWeek, 10), models.ErrNotifyKindUnknown)
+
+		chat, err := chatRepo.FindByID(t.Context(), -100)
+		require.NoError(t, err)
+		assert.Equal(t, 24*60-10, chat.MorningTime)
+		assert.False(t, chat.Pin)
+
+		require.NoError(t, chatRepo.ShiftTime(t.Context(), -100, models.NotifyMorning, 310))
+		require.NoError(t, chatRepo.TogglePin(t.Context(), -100))
+	})
+
+	t.Run("pinned message is replaced", func(t *testing.T) {
+		previous, err := chatRepo.UpdatePinned(t.Context(), -100, 10)
+		require.NoError(t, err)
+		assert.Nil(t, previous)
+
+		previous, err = chatRepo.UpdatePinned(t.Context(), -100, 11)
+		require.NoError(t, err)
+		require.NotNil(t, previous)
+		assert.Equal(t, int64(10), *previous)
+
+		_, err = chatRepo.UpdatePinned(t.Context(), -1, 11)
+		assert.ErrorIs(t, err, models.ErrChatNotFound)
+	})
+
+	t.Run("migrated chat keeps stream", func(t *testing.T) {
+		require.NoError(t, chatRepo.Migrate(t.Context(), -100, -1001))
+
+		chat, err := chatRepo.FindByID(t.Context(), -1001)
+		require.NoError(t, err)
+		require.NotNil(t, chat.Stream)
+		assert.Equal(t, "stream", *chat.Stream)
+	})
+
+	t.Run("migration replaces a chat saved for the supergroup", func(t *testing.T) {
+		require.NoError(t, chatRepo.Save(t.Context(), -200, "ИС-22"))
+		require.NoError(t, chatRepo.UpdateStream(t.Context(), -200, "stream", ""))
+		// The bot has been added to the supergroup before the migration event
+		require.NoError(t, chatRepo.Save(t.Context(), -2001, "ИС-22"))
+
+		_, err := pool.Exec(t.Context(), `INSERT INTO schedule_messages(chat_id, message_id, stream, date_from, date_to, lessons)
+		VALUES (-200, 1, 'stream', now(), now(), '[]');`)
+		require.NoError(t, err)
+
+		require.NoError(t, chatRepo.Migrate(t.Context(), -200, -2001))
+
+		chat, err := chatRepo.FindByID(t.Context(), -2001)
+		require.NoError(t, err)
+		require.NotNil(t, chat.Stream)
+		assert.Equal(t, "stream", *chat.Stream)
+
+		_, err = chatRepo.FindByID(t.Context(), -200)
+		assert.ErrorIs(t, err, models.ErrChatNotFound)
+
+		var messages int
+		require.NoError(t, pool.QueryRow(t.Context(), "SELECT count(*) FROM schedule_messages WHERE chat_id = -2001").Scan(&messages))
+		assert.Equal(t, 1, messages)
+	})
+
+	t.Run("inactive chat is not due", func(t *testing.T) {
+		require.NoError(t, chatRepo.Deactivate(t.Context(), -1001))
+
+		chats, _, err := chatRepo.FindAllDue(t.Context(), models.NotifyMorning, 5*60, morning, -2000, 10)
+		require.NoError(t, err)
+		assert.Empty(t, chats)
+	})
+}
@@ -0,0 +1,65 @@
+//go:build integration
+
+package repository
+
+import (
+	"os"
+	"pgtk-schedule/internal/models"
+	"pgtk-schedule/internal/repository"
+	"pgtk-schedule/pkg/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFirstLesson(t *testing.T) {
+	dbConn := os.Getenv("DB_CONN")
+	if dbConn == "" {
+		t.Skip("DB_CONN not found")
+	}
+
+	pool, err := database.NewPgx(dbConn)
+	require.NoError(t, err)
+
+	_, err = pool.Exec(t.Context(), "DELETE FROM students")
+	require.NoError(t, err)
+
+	studentRepo := repository.NewStudent(pool)
+	firstLessonRepo := repository.NewFirstLesson(pool)
+
+	require.NoError(t, studentRepo.Create(t.Context(), 1, "test"))
+
+	today := time.Date(2025, time.May, 5, 0, 0, 0, 0, time.UTC)
+	first := models.FirstLesson{
+		StudentID: 1,
+		Stream:    "ИС-21",
+		Date:      today,
+		Lesson:    models.Lesson{ID: "1", Name: "Математика", DateStart: today.Add(8 * time.Hour)},
+	}
+
+	t.Run("saved lesson is found until alerted", func(t *testing.T) {
+		require.NoError(t, firstLessonRepo.Save(t.Context(), first))
+
+		firsts, err := firstLessonRepo.FindNotAlerted(t.Context(), today)
+		require.NoError(t, err)
+		require.Len(t, firsts, 1)
+		assert.Equal(t, "Математика", firsts[0].Lesson.Name)
+
+		require.NoError(t, firstLessonRepo.MarkAlerted(t.Context(), first))
+
+		firsts, err = firstLessonRepo.FindNotAlerted(t.Context(), today)
+		require.NoError(t, err)
+		assert.Empty(t, firsts)
+	})
+
+	t.Run("delete before", func(t *testing.T) {
+		require.NoError(t, firstLessonRepo.Save(t.Context(), first))
+		require.NoError(t, firstLessonRepo.DeleteBefore(t.Context(), today.AddDate(0, 0, 1)))
+
+		firsts, err := firstLessonRepo.FindNotAlerted(t.Context(), today)
+		require.NoError(t, err)
+		assert.Empty(t, firsts)
+	})
+}
@@ -0,0 +1,71 @@
+//go:build integration
+
+package repository
+
+import (
+	"errors"
+	"os"
+	"pgtk-schedule/internal/repository"
+	"pgtk-schedule/pkg/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobRun(t *testing.T) {
+	dbConn := os.Getenv("DB_CONN")
+	if dbConn == "" {
+		t.Skip("DB_CONN not found")
+	}
+
+	pool, err := database.NewPgx(dbConn)
+	require.NoError(t, err)
+
+	_, err = pool.Exec(t.Context(), "DELETE FROM job_runs")
+	require.NoError(t, err)
+
+	jobRunRepo := repository.NewJobRun(pool)
+	slot := time.Date(2025, time.April, 21, 5, 0, 0, 0, time.UTC)
+
+	t.Run("slot runs once", func(t *testing.T) {
+		last, err := jobRunRepo.LastSlot(t.Context(), "notify")
+		require.NoError(t, err)
+		assert.True(t, last.IsZero())
+
+		started, err := jobRunRepo.Start(t.Context(), "notify", slot)
+		require.NoError(t, err)
+		assert.True(t, started)
+
+		started, err = jobRunRepo.Start(t.Context(), "notify", slot)
+		require.NoError(t, err)
+		assert.False(t, started)
+
+		require.NoError(t, jobRunRepo.Finish(t.Context(), "notify", slot, errors.New("boom")))
+
+		last, err = jobRunRepo.LastSlot(t.Context(), "notify")
+		require.NoError(t, err)
+		assert.True(t, slot.Equal(last))
+	})
+
+	t.Run("only one leader", func(t *testing.T) {
+		first := repository.NewLeader(pool, 42)
+		second := repository.NewLeader(pool, 42)
+
+		isLeader, err := first.IsLeader(t.Context())
+		require.NoError(t, err)
+		assert.True(t, isLeader)
+
+		isLeader, err = second.IsLeader(t.Context())
+		require.NoError(t, err)
+		assert.False(t, isLeader)
+
+		require.NoError(t, first.Close(t.Context()))
+
+		isLeader, err = second.IsLeader(t.Context())
+		require.NoError(t, err)
+		assert.True(t, isLeader)
+		require.NoError(t, second.Close(t.Context()))
+	})
+}
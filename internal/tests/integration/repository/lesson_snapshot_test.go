@@ -0,0 +1,77 @@
+//go:build integration
+
+package repository
+
+import (
+	"os"
+	"pgtk-schedule/internal/models"
+	"pgtk-schedule/internal/repository"
+	"pgtk-schedule/pkg/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLessonSnapshot(t *testing.T) {
+	dbConn := os.Getenv("DB_CONN")
+	if dbConn == "" {
+		t.Skip("DB_CONN not found")
+	}
+
+	pool, err := database.NewPgx(dbConn)
+	require.NoError(t, err)
+
+	_, err = pool.Exec(t.Context(), "DELETE FROM students")
+	require.NoError(t, err)
+
+	studentRepo := repository.NewStudent(pool)
+	lessonSnapshotRepo := repository.NewLessonSnapshot(pool)
+
+	require.NoError(t, studentRepo.Create(t.Context(), 1, "test"))
+
+	today := time.Date(2025, time.April, 21, 0, 0, 0, 0, time.UTC)
+	tomorrow := today.AddDate(0, 0, 1)
+	lesson := models.Lesson{
+		ID:        "1",
+		Name:      "Математика",
+		Cabinet:   "101",
+		DateStart: today.Add(9 * time.Hour),
+		DateEnd:   today.Add(10*time.Hour + 30*time.Minute),
+	}
+
+	t.Run("save upserts snapshot", func(t *testing.T) {
+		snapshot := models.LessonSnapshot{StudentID: 1, Stream: "ИС-21", Date: today, Lessons: []models.Lesson{lesson}}
+		require.NoError(t, lessonSnapshotRepo.Save(t.Context(), snapshot))
+
+		lesson.Cabinet = "202"
+		snapshot.Lessons = []models.Lesson{lesson}
+		require.NoError(t, lessonSnapshotRepo.Save(t.Context(), snapshot))
+
+		snapshots, err := lessonSnapshotRepo.FindByDates(t.Context(), today, tomorrow)
+		require.NoError(t, err)
+		require.Len(t, snapshots, 1)
+		require.Len(t, snapshots[0].Lessons, 1)
+		assert.Equal(t, "202", snapshots[0].Lessons[0].Cabinet)
+		assert.True(t, today.Equal(snapshots[0].Date))
+	})
+
+	t.Run("inactive students are skipped", func(t *testing.T) {
+		require.NoError(t, studentRepo.Deactivate(t.Context(), 1))
+
+		snapshots, err := lessonSnapshotRepo.FindByDates(t.Context(), today, tomorrow)
+		require.NoError(t, err)
+		assert.Len(t, snapshots, 0)
+
+		require.NoError(t, studentRepo.Activate(t.Context(), 1))
+	})
+
+	t.Run("delete before", func(t *testing.T) {
+		require.NoError(t, lessonSnapshotRepo.DeleteBefore(t.Context(), tomorrow))
+
+		snapshots, err := lessonSnapshotRepo.FindByDates(t.Context(), today, tomorrow)
+		require.NoError(t, err)
+		assert.Len(t, snapshots, 0)
+	})
+}
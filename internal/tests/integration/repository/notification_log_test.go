@@ -0,0 +1,85 @@
+//go:build integration
+
+package repository
+
+import (
+	"os"
+	"pgtk-schedule/internal/models"
+	"pgtk-schedule/internal/repository"
+	"pgtk-schedule/pkg/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationLog(t *testing.T) {
+	dbConn := os.Getenv("DB_CONN")
+	if dbConn == "" {
+		t.Skip("DB_CONN not found")
+	}
+
+	pool, err := database.NewPgx(dbConn)
+	require.NoError(t, err)
+
+	_, err = pool.Exec(t.Context(), "DELETE FROM notification_log")
+	require.NoError(t, err)
+
+	logRepo := repository.NewNotificationLog(pool)
+
+	kind := string(models.NotifyMorning)
+	yesterday := models.RunID(kind, time.Now().AddDate(0, 0, -1))
+	today := models.RunID(kind, time.Now())
+	outboxId := int64(100)
+
+	entries := []models.NotificationLog{
+		{RunID: yesterday, Kind: kind, StudentID: 1, Status: models.DeliverySent},
+		{RunID: today, Kind: kind, StudentID: 1, Status: models.DeliveryQueued, OutboxID: &outboxId},
+		{RunID: today, Kind: kind, StudentID: 2, Status: models.DeliveryEmpty},
+		{RunID: today, Kind: kind, StudentID: 3, Status: models.DeliverySkipped},
+		{RunID: today, Kind: kind, StudentID: 4, Status: models.DeliverySkipped},
+	}
+	for _, entry := range entries {
+		require.NoError(t, logRepo.Create(t.Context(), entry))
+	}
+
+	messageId := int64(42)
+	require.NoError(t, logRepo.UpdateByOutboxID(t.Context(), outboxId, models.DeliverySent, &messageId, nil))
+
+	reports, err := logRepo.LastRuns(t.Context())
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, today, reports[0].RunID)
+	assert.Equal(t, map[models.DeliveryStatus]int{
+		models.DeliverySent:    1,
+		models.DeliveryEmpty:   1,
+		models.DeliverySkipped: 2,
+	}, reports[0].Counts)
+
+	t.Run("entries with a key are created once", func(t *testing.T) {
+		key := "1/1738571400"
+		entry := models.NotificationLog{RunID: models.RunID(models.OutboxReminder, time.Now()), Kind: models.OutboxReminder, StudentID: 1, Status: models.DeliveryQueued, Key: &key}
+
+		id, created, err := logRepo.CreateOnce(t.Context(), entry)
+		require.NoError(t, err)
+		assert.True(t, created)
+		require.NoError(t, logRepo.AttachOutbox(t.Context(), id, 101))
+
+		_, created, err = logRepo.CreateOnce(t.Context(), entry)
+		require.NoError(t, err)
+		assert.False(t, created)
+
+		other := entry
+		other.StudentID = 2
+		id, created, err = logRepo.CreateOnce(t.Context(), other)
+		require.NoError(t, err)
+		assert.True(t, created)
+
+		// A failed entry releases the key
+		require.NoError(t, logRepo.Fail(t.Context(), id, "connection reset"))
+		_, created, err = logRepo.CreateOnce(t.Context(), other)
+		require.NoError(t, err)
+		assert.True(t, created)
+	})
+}
@@ -0,0 +1,88 @@
+//go:build integration
+
+package repository
+
+import (
+	"os"
+	"pgtk-schedule/internal/models"
+	"pgtk-schedule/internal/repository"
+	"pgtk-schedule/pkg/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutbox(t *testing.T) {
+	dbConn := os.Getenv("DB_CONN")
+	if dbConn == "" {
+		t.Skip("DB_CONN not found")
+	}
+
+	pool, err := database.NewPgx(dbConn)
+	require.NoError(t, err)
+
+	_, err = pool.Exec(t.Context(), "DELETE FROM outbox")
+	require.NoError(t, err)
+
+	outboxRepo := repository.NewOutbox(pool)
+
+	first, err := outboxRepo.Create(t.Context(), models.OutboxMessage{ChatID: 1, Kind: string(models.NotifyMorning), Text: "first"})
+	require.NoError(t, err)
+	second, err := outboxRepo.Create(t.Context(), models.OutboxMessage{ChatID: 2, Kind: models.OutboxBroadcast, Text: "second", Pin: true})
+	require.NoError(t, err)
+
+	t.Run("claim leases messages", func(t *testing.T) {
+		messages, err := outboxRepo.Claim(t.Context(), time.Minute, 10)
+		require.NoError(t, err)
+		require.Len(t, messages, 2)
+		assert.Equal(t, 1, messages[0].Attempts)
+		assert.Equal(t, models.OutboxPending, messages[0].Status)
+		for _, message := range messages {
+			assert.Equal(t, message.ID == second, message.Pin)
+		}
+
+		messages, err = outboxRepo.Claim(t.Context(), time.Minute, 10)
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("sent and retried messages", func(t *testing.T) {
+		require.NoError(t, outboxRepo.MarkSent(t.Context(), first, 42))
+		require.NoError(t, outboxRepo.MarkRetry(t.Context(), second, time.Now().Add(-time.Second), "timeout"))
+
+		messages, err := outboxRepo.Claim(t.Context(), time.Minute, 10)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, second, messages[0].ID)
+		assert.Equal(t, 2, messages[0].Attempts)
+		require.NotNil(t, messages[0].LastError)
+		assert.Equal(t, "timeout", *messages[0].LastError)
+	})
+
+	t.Run("renew keeps the lease of the claim only", func(t *testing.T) {
+		leased, err := outboxRepo.Renew(t.Context(), second, 2, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, leased)
+
+		// The message has been claimed again since the first attempt
+		leased, err = outboxRepo.Renew(t.Context(), second, 1, time.Minute)
+		require.NoError(t, err)
+		assert.False(t, leased)
+
+		leased, err = outboxRepo.Renew(t.Context(), first, 1, time.Minute)
+		require.NoError(t, err)
+		assert.False(t, leased)
+	})
+
+	t.Run("failed messages are not claimed", func(t *testing.T) {
+		require.NoError(t, outboxRepo.MarkFailed(t.Context(), second, "blocked"))
+		_, err := pool.Exec(t.Context(), "UPDATE outbox SET next_attempt_at = now() - interval '1 minute'")
+		require.NoError(t, err)
+
+		messages, err := outboxRepo.Claim(t.Context(), time.Minute, 10)
+		require.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+}
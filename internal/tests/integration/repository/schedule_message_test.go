@@ -0,0 +1,95 @@
+//go:build integration
+
+package repository
+
+import (
+	"os"
+	"pgtk-schedule/internal/models"
+	"pgtk-schedule/internal/repository"
+	"pgtk-schedule/pkg/database"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleMessage(t *testing.T) {
+	dbConn := os.Getenv("DB_CONN")
+	if dbConn == "" {
+		t.Skip("DB_CONN not found")
+	}
+
+	pool, err := database.NewPgx(dbConn)
+	require.NoError(t, err)
+
+	_, err = pool.Exec(t.Context(), "DELETE FROM schedule_messages")
+	require.NoError(t, err)
+	_, err = pool.Exec(t.Context(), "DELETE FROM outbox")
+	require.NoError(t, err)
+
+	outboxRepo := repository.NewOutbox(pool)
+	scheduleMessageRepo := repository.NewScheduleMessage(pool)
+
+	today := time.Date(2025, time.April, 30, 0, 0, 0, 0, time.UTC)
+	lesson := models.Lesson{ID: "1", Name: "Математика", Cabinet: "101", DateStart: today.Add(9 * time.Hour), DateEnd: today.Add(10 * time.Hour)}
+
+	outboxId, err := outboxRepo.Create(t.Context(), models.OutboxMessage{ChatID: 1, Kind: string(models.NotifyMorning), Text: "schedule"})
+	require.NoError(t, err)
+
+	messageId := int64(10)
+	require.NoError(t, scheduleMessageRepo.Create(t.Context(), models.ScheduleMessage{
+		ChatID: 1, OutboxID: &outboxId, Stream: "ИС-21", Header: "header\n", DateFrom: today, DateTo: today, Lessons: []models.Lesson{lesson},
+	}))
+	require.NoError(t, scheduleMessageRepo.Create(t.Context(), models.ScheduleMessage{
+		ChatID: 2, MessageID: &messageId, Stream: "ИС-21", DateFrom: today.AddDate(0, 0, -1), DateTo: today.AddDate(0, 0, -1), Lessons: []models.Lesson{lesson},
+	}))
+
+	t.Run("queued messages are not found", func(t *testing.T) {
+		messages, err := scheduleMessageRepo.FindSince(t.Context(), today.AddDate(0, 0, -1))
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, int64(2), messages[0].ChatID)
+	})
+
+	t.Run("sent messages take identifier from outbox", func(t *testing.T) {
+		require.NoError(t, outboxRepo.MarkSent(t.Context(), outboxId, 42))
+
+		messages, err := scheduleMessageRepo.FindSince(t.Context(), today)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		require.NotNil(t, messages[0].MessageID)
+		assert.Equal(t, int64(42), *messages[0].MessageID)
+		assert.Equal(t, "header\n", messages[0].Header)
+		assert.True(t, today.Equal(messages[0].DateFrom))
+
+		lesson.Cabinet = "202"
+		require.NoError(t, scheduleMessageRepo.UpdateLessons(t.Context(), messages[0].ID, []models.Lesson{lesson}))
+
+		messages, err = scheduleMessageRepo.FindSince(t.Context(), today)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, "202", messages[0].Lessons[0].Cabinet)
+	})
+
+	t.Run("edits are queued", func(t *testing.T) {
+		messageId := int64(42)
+		id, err := outboxRepo.Create(t.Context(), models.OutboxMessage{ChatID: 1, Kind: models.OutboxEdit, Text: "updated", EditMessageID: &messageId})
+		require.NoError(t, err)
+
+		messages, err := outboxRepo.Claim(t.Context(), time.Minute, 10)
+		require.NoError(t, err)
+		require.Len(t, messages, 1)
+		assert.Equal(t, id, messages[0].ID)
+		require.NotNil(t, messages[0].EditMessageID)
+		assert.Equal(t, int64(42), *messages[0].EditMessageID)
+	})
+
+	t.Run("delete before", func(t *testing.T) {
+		require.NoError(t, scheduleMessageRepo.DeleteBefore(t.Context(), today))
+
+		messages, err := scheduleMessageRepo.FindSince(t.Context(), today.AddDate(0, 0, -1))
+		require.NoError(t, err)
+		assert.Len(t, messages, 1)
+	})
+}
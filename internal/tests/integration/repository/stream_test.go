@@ -0,0 +1,110 @@
+//go:build integration
+
+package repository
+
+import (
+	"os"
+	"pgtk-schedule/internal/models"
+	"pgtk-schedule/internal/repository"
+	"pgtk-schedule/pkg/database"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream(t *testing.T) {
+	dbConn := os.Getenv("DB_CONN")
+	if dbConn == "" {
+		t.Skip("DB_CONN not found")
+	}
+
+	pool, err := database.NewPgx(dbConn)
+	require.NoError(t, err)
+
+	for _, table := range []string{"students", "chats", "streams", "stream_rollovers"} {
+		_, err = pool.Exec(t.Context(), "DELETE FROM "+table)
+		require.NoError(t, err)
+	}
+
+	studentRepo := repository.NewStudent(pool)
+	subscriptionRepo := repository.NewSubscription(pool)
+	streamRepo := repository.NewStream(pool)
+	chatRepo := repository.NewChat(pool)
+
+	old := []models.Stream{
+		{ID: "101", Name: "ИСП-21", Substreams: []string{"1 подгруппа", "2 подгруппа"}},
+		{ID: "102", Name: "ТМ-11"},
+		{ID: "103", Name: "ПКС-21"},
+		{ID: "104", Name: "ЭК-21"},
+	}
+	require.NoError(t, streamRepo.Save(t.Context(), old))
+
+	for id, stream := range map[int64]string{1: "101", 2: "101", 3: "102"} {
+		require.NoError(t, studentRepo.Create(t.Context(), id, "test"))
+		require.NoError(t, studentRepo.UpdateStream(t.Context(), id, stream))
+		require.NoError(t, subscriptionRepo.Create(t.Context(), id, stream, "", ""))
+	}
+	require.NoError(t, studentRepo.UpdateSubstream(t.Context(), 1, "1 подгруппа"))
+	require.NoError(t, studentRepo.UpdateSubstream(t.Context(), 2, "2 подгруппа"))
+
+	require.NoError(t, chatRepo.Save(t.Context(), -1, "ИСП-21"))
+	require.NoError(t, chatRepo.UpdateStream(t.Context(), -1, "101", "2 подгруппа"))
+
+	// Streams used only by a group chat or a subscription
+	require.NoError(t, chatRepo.Save(t.Context(), -2, "ПКС-21"))
+	require.NoError(t, chatRepo.UpdateStream(t.Context(), -2, "103", ""))
+	require.NoError(t, subscriptionRepo.Create(t.Context(), 3, "104", "", ""))
+
+	current := models.Stream{ID: "201", Name: "ИСП-21", Substreams: []string{"1 подгруппа"}}
+
+	t.Run("find stale streams", func(t *testing.T) {
+		stale, err := streamRepo.FindStale(t.Context(), []string{current.ID, "102"})
+		require.NoError(t, err)
+		require.Len(t, stale, 3)
+		assert.Equal(t, models.StaleStream{Stream: old[0], Students: 2}, stale[0])
+		// Streams without substreams are saved with an empty list
+		assert.Equal(t, models.StaleStream{Stream: models.Stream{ID: "103", Name: "ПКС-21", Substreams: []string{}}, Students: 0}, stale[1])
+		assert.Equal(t, models.StaleStream{Stream: models.Stream{ID: "104", Name: "ЭК-21", Substreams: []string{}}, Students: 1}, stale[2])
+	})
+
+	t.Run("rollover", func(t *testing.T) {
+		_, err := studentRepo.MarkMissingStreams(t.Context(), []models.Stream{current}, 1)
+		require.NoError(t, err)
+
+		moved, err := streamRepo.Rollover(t.Context(), []models.StreamMapping{
+			{Old: models.StaleStream{Stream: old[0], Students: 2}, New: &current},
+			{Old: models.StaleStream{Stream: old[1], Students: 1}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), moved)
+
+		student, err := studentRepo.FindByID(t.Context(), 1)
+		require.NoError(t, err)
+		assert.Equal(t, current.ID, *student.Stream)
+		assert.False(t, student.StreamMissing)
+
+		// The substream is missing in the new stream, so the student still has to select it
+		student, err = studentRepo.FindByID(t.Context(), 2)
+		require.NoError(t, err)
+		assert.Equal(t, current.ID, *student.Stream)
+		assert.True(t, student.StreamMissing)
+
+		student, err = studentRepo.FindByID(t.Context(), 3)
+		require.NoError(t, err)
+		assert.Equal(t, "102", *student.Stream)
+
+		// The chat is moved, but the missing substream is reset to the whole stream
+		chat, err := chatRepo.FindByID(t.Context(), -1)
+		require.NoError(t, err)
+		require.NotNil(t, chat.Stream)
+		assert.Equal(t, current.ID, *chat.Stream)
+		assert.Nil(t, chat.Substream)
+
+		var subscriptions, rollovers int
+		require.NoError(t, pool.QueryRow(t.Context(), "SELECT count(*) FROM subscriptions WHERE stream = $1", current.ID).Scan(&subscriptions))
+		require.NoError(t, pool.QueryRow(t.Context(), "SELECT count(*) FROM stream_rollovers WHERE old_stream = '101' AND new_stream = '201' AND students = 2").Scan(&rollovers))
+		assert.Equal(t, 2, subscriptions)
+		assert.Equal(t, 1, rollovers)
+	})
+}
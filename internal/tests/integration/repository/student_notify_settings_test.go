@@ -8,6 +8,7 @@ import (
 	"pgtk-schedule/internal/repository"
 	"pgtk-schedule/pkg/database"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -43,6 +44,9 @@ func TestStudentNotifySettings(t *testing.T) {
 		assert.True(t, notifySettings.Morning)
 		assert.True(t, notifySettings.Evening)
 		assert.True(t, notifySettings.Week)
+		assert.Equal(t, 5*60, notifySettings.MorningTime)
+		assert.True(t, notifySettings.MorningDays.Has(time.Monday))
+		assert.False(t, notifySettings.MorningDays.Has(time.Sunday))
 	})
 
 	t.Run("find student by id", func(t *testing.T) {
@@ -52,6 +56,8 @@ func TestStudentNotifySettings(t *testing.T) {
 		assert.Equal(t, models.Student{
 			ID:       1,
 			Nickname: &nickname,
+			Role:     models.RoleStudent,
+			Active:   true,
 		}, student)
 	})
 
@@ -107,6 +113,45 @@ func TestStudentNotifySettings(t *testing.T) {
 		assert.Equal(t, "updated", *student.Nickname)
 	})
 
+	t.Run("update onboarding step", func(t *testing.T) {
+		err := studentRepo.UpdateOnboardingStep(t.Context(), 1, models.OnboardingNotify)
+		require.NoError(t, err)
+
+		student, err := studentRepo.FindByID(t.Context(), 1)
+		require.NoError(t, err)
+		require.NotNil(t, student.OnboardingStep)
+		assert.Equal(t, models.OnboardingNotify, *student.OnboardingStep)
+		assert.True(t, student.NeedsOnboarding())
+
+		err = studentRepo.UpdateOnboardingStep(t.Context(), 1, "")
+		require.NoError(t, err)
+
+		student, err = studentRepo.FindByID(t.Context(), 1)
+		require.NoError(t, err)
+		assert.Nil(t, student.OnboardingStep)
+		assert.False(t, student.NeedsOnboarding())
+
+		err = studentRepo.UpdateOnboardingStep(t.Context(), 100, "")
+		assert.ErrorIs(t, err, models.ErrStudentNotFound)
+	})
+
+	t.Run("update teacher", func(t *testing.T) {
+		err := studentRepo.UpdateTeacher(t.Context(), 2, "Иванов Иван Иванович")
+		require.NoError(t, err)
+
+		student, err := studentRepo.FindByID(t.Context(), 2)
+		require.NoError(t, err)
+		assert.True(t, student.IsTeacher())
+		assert.Equal(t, "Иванов Иван Иванович", *student.Teacher)
+
+		err = studentRepo.UpdateStream(t.Context(), 2, "stream")
+		require.NoError(t, err)
+
+		student, err = studentRepo.FindByID(t.Context(), 2)
+		require.NoError(t, err)
+		assert.False(t, student.IsTeacher())
+	})
+
 	t.Run("notify settings toggle morning", func(t *testing.T) {
 		err := notifySettingsRepo.ToggleMorning(t.Context(), 1)
 		require.NoError(t, err)
@@ -154,4 +199,189 @@ func TestStudentNotifySettings(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, true, notifySettings.Week)
 	})
+
+	t.Run("notify settings shift time", func(t *testing.T) {
+		err := notifySettingsRepo.ShiftTime(t.Context(), 1, models.NotifyMorning, -310)
+		require.NoError(t, err)
+
+		notifySettings, err := notifySettingsRepo.FindByStudentID(t.Context(), 1)
+		require.NoError(t, err)
+		assert.Equal(t, 24*60-10, notifySettings.MorningTime)
+
+		err = notifySettingsRepo.ShiftTime(t.Context(), 1, models.NotifyMorning, 310)
+		require.NoError(t, err)
+
+		err = notifySettingsRepo.ShiftTime(t.Context(), 1, models.NotifyKind("unknown"), 10)
+		assert.ErrorIs(t, err, models.ErrNotifyKindUnknown)
+	})
+
+	t.Run("notify settings toggle day", func(t *testing.T) {
+		err := notifySettingsRepo.ToggleDay(t.Context(), 1, models.NotifyEvening, time.Sunday)
+		require.NoError(t, err)
+
+		notifySettings, err := notifySettingsRepo.FindByStudentID(t.Context(), 1)
+		require.NoError(t, err)
+		assert.True(t, notifySettings.EveningDays.Has(time.Sunday))
+		assert.True(t, notifySettings.EveningDays.Has(time.Friday))
+	})
+
+	sunday := time.Date(2025, time.March, 2, 18, 0, 0, 0, time.UTC)
+	monday := time.Date(2025, time.March, 3, 5, 0, 0, 0, time.UTC)
+
+	t.Run("find all due", func(t *testing.T) {
+		students, _, err := studentRepo.FindAllDue(t.Context(), models.NotifyEvening, 18*60, sunday, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, students, 1)
+		assert.Equal(t, int64(1), students[0].ID)
+
+		students, _, err = studentRepo.FindAllDue(t.Context(), models.NotifyMorning, 5*60, monday, 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, students, 2)
+
+		students, _, err = studentRepo.FindAllDue(t.Context(), models.NotifyMorning, 5*60+1, monday, 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, students, 0)
+	})
+
+	t.Run("pause notifications", func(t *testing.T) {
+		until := time.Date(2025, time.March, 5, 0, 0, 0, 0, time.UTC)
+		err := notifySettingsRepo.UpdatePausedUntil(t.Context(), 2, &until)
+		require.NoError(t, err)
+
+		notifySettings, err := notifySettingsRepo.FindByStudentID(t.Context(), 2)
+		require.NoError(t, err)
+		require.NotNil(t, notifySettings.PausedUntil)
+		assert.True(t, until.Equal(*notifySettings.PausedUntil))
+
+		students, _, err := studentRepo.FindAllDue(t.Context(), models.NotifyMorning, 5*60, monday, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, students, 1)
+		assert.Equal(t, int64(1), students[0].ID)
+
+		ids, err := notifySettingsRepo.ResumeExpired(t.Context(), monday)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+
+		ids, err = notifySettingsRepo.ResumeExpired(t.Context(), until)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{2}, ids)
+
+		notifySettings, err = notifySettingsRepo.FindByStudentID(t.Context(), 2)
+		require.NoError(t, err)
+		assert.Nil(t, notifySettings.PausedUntil)
+
+		// Before the resume hour a pause ends with the first notification of the student
+		require.NoError(t, notifySettingsRepo.UpdatePausedUntil(t.Context(), 2, &until))
+
+		ids, err = notifySettingsRepo.ResumeDue(t.Context(), until, 4*60)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+
+		ids, err = notifySettingsRepo.ResumeDue(t.Context(), until, 5*60)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{2}, ids)
+	})
+
+	t.Run("inactive students are skipped", func(t *testing.T) {
+		require.NoError(t, studentRepo.Deactivate(t.Context(), 2))
+
+		student, err := studentRepo.FindByID(t.Context(), 2)
+		require.NoError(t, err)
+		assert.False(t, student.Active)
+
+		students, _, err := studentRepo.FindAll(t.Context(), 0, 10)
+		require.NoError(t, err)
+		require.Len(t, students, 1)
+		assert.Equal(t, int64(1), students[0].ID)
+
+		students, _, err = studentRepo.FindAllDue(t.Context(), models.NotifyMorning, 5*60, monday, 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, students, 1)
+
+		require.NoError(t, studentRepo.Activate(t.Context(), 2))
+
+		students, _, err = studentRepo.FindAll(t.Context(), 0, 10)
+		require.NoError(t, err)
+		assert.Len(t, students, 2)
+
+		err = studentRepo.Activate(t.Context(), -1)
+		assert.ErrorIs(t, err, models.ErrStudentNotFound)
+	})
+
+	t.Run("students with missing streams", func(t *testing.T) {
+		current := []models.Stream{{ID: "updated", Substreams: []string{"updated"}}}
+		ids, err := studentRepo.MarkMissingStreams(t.Context(), current, 1)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+
+		// Teachers are not marked
+		renamed := []models.Stream{{ID: "renamed", Substreams: []string{"updated"}}}
+		ids, err = studentRepo.MarkMissingStreams(t.Context(), renamed, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{1}, ids)
+
+		ids, err = studentRepo.MarkMissingStreams(t.Context(), renamed, 1)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+
+		student, err := studentRepo.FindByID(t.Context(), 1)
+		require.NoError(t, err)
+		assert.True(t, student.StreamMissing)
+
+		students, _, err := studentRepo.FindAllDue(t.Context(), models.NotifyMorning, 5*60, monday, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, students, 1)
+		assert.Equal(t, int64(2), students[0].ID)
+
+		students, _, err = studentRepo.FindAllSkipped(t.Context(), models.NotifyMorning, 5*60, monday, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, students, 1)
+		assert.True(t, students[0].StreamMissing)
+
+		ids, err = studentRepo.MarkMissingStreams(t.Context(), current, 1)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+
+		student, err = studentRepo.FindByID(t.Context(), 1)
+		require.NoError(t, err)
+		assert.False(t, student.StreamMissing)
+
+		_, err = studentRepo.MarkMissingStreams(t.Context(), renamed, 1)
+		require.NoError(t, err)
+		require.NoError(t, studentRepo.UpdateStream(t.Context(), 1, "renamed"))
+
+		student, err = studentRepo.FindByID(t.Context(), 1)
+		require.NoError(t, err)
+		assert.False(t, student.StreamMissing)
+	})
+
+	t.Run("students are marked after absences in a row", func(t *testing.T) {
+		// Substreams that failed to load say nothing about the substream of the student
+		failed := []models.Stream{{ID: "renamed", SubstreamsUnknown: true}}
+		ids, err := studentRepo.MarkMissingStreams(t.Context(), failed, 2)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+
+		gone := []models.Stream{{ID: "other"}}
+		ids, err = studentRepo.MarkMissingStreams(t.Context(), gone, 2)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+
+		student, err := studentRepo.FindByID(t.Context(), 1)
+		require.NoError(t, err)
+		assert.False(t, student.StreamMissing)
+
+		// A refresh with the group found starts counting again
+		ids, err = studentRepo.MarkMissingStreams(t.Context(), failed, 2)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+
+		ids, err = studentRepo.MarkMissingStreams(t.Context(), gone, 2)
+		require.NoError(t, err)
+		assert.Empty(t, ids)
+
+		ids, err = studentRepo.MarkMissingStreams(t.Context(), gone, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{1}, ids)
+	})
 }
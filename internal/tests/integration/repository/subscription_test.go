@@ -0,0 +1,110 @@
+//go:build integration
+
+package repository
+
+import (
+	"os"
+	"pgtk-schedule/internal/models"
+	"pgtk-schedule/internal/repository"
+	"pgtk-schedule/pkg/database"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscription(t *testing.T) {
+	dbConn := os.Getenv("DB_CONN")
+	if dbConn == "" {
+		t.Skip("DB_CONN not found")
+	}
+
+	pool, err := database.NewPgx(dbConn)
+	require.NoError(t, err)
+
+	_, err = pool.Exec(t.Context(), "DELETE FROM students")
+	require.NoError(t, err)
+
+	studentRepo := repository.NewStudent(pool)
+	subscriptionRepo := repository.NewSubscription(pool)
+
+	require.NoError(t, studentRepo.Create(t.Context(), 1, "test"))
+	require.NoError(t, studentRepo.Create(t.Context(), 2, "test2"))
+
+	t.Run("create is idempotent", func(t *testing.T) {
+		require.NoError(t, subscriptionRepo.Create(t.Context(), 1, "10", "A", "ИСП-21 (A)"))
+		require.NoError(t, subscriptionRepo.Create(t.Context(), 1, "10", "A", "ИСП-21 (A)"))
+		require.NoError(t, subscriptionRepo.Create(t.Context(), 1, "11", "", "ИСП-22"))
+
+		subscriptions, err := subscriptionRepo.FindByStudentID(t.Context(), 1)
+		require.NoError(t, err)
+		require.Len(t, subscriptions, 2)
+		assert.Equal(t, "10", subscriptions[0].Stream)
+		assert.Equal(t, "A", subscriptions[0].Substream)
+		assert.True(t, subscriptions[0].Notify)
+	})
+
+	t.Run("toggle notify", func(t *testing.T) {
+		subscriptions, err := subscriptionRepo.FindByStudentID(t.Context(), 1)
+		require.NoError(t, err)
+
+		require.NoError(t, subscriptionRepo.ToggleNotify(t.Context(), 1, subscriptions[0].ID))
+
+		subscription, err := subscriptionRepo.FindByID(t.Context(), 1, subscriptions[0].ID)
+		require.NoError(t, err)
+		assert.False(t, subscription.Notify)
+	})
+
+	t.Run("other student cannot access subscription", func(t *testing.T) {
+		subscriptions, err := subscriptionRepo.FindByStudentID(t.Context(), 1)
+		require.NoError(t, err)
+
+		_, err = subscriptionRepo.FindByID(t.Context(), 2, subscriptions[0].ID)
+		assert.ErrorIs(t, err, models.ErrSubscriptionNotFound)
+
+		err = subscriptionRepo.Delete(t.Context(), 2, subscriptions[0].ID)
+		assert.ErrorIs(t, err, models.ErrSubscriptionNotFound)
+	})
+
+	t.Run("update group", func(t *testing.T) {
+		require.NoError(t, studentRepo.UpdateGroup(t.Context(), 1, "11", ""))
+
+		student, err := studentRepo.FindByID(t.Context(), 1)
+		require.NoError(t, err)
+		require.NotNil(t, student.Stream)
+		require.NotNil(t, student.Substream)
+		assert.Equal(t, "11", *student.Stream)
+		assert.Equal(t, "", *student.Substream)
+	})
+
+	t.Run("replace stops notifying the previous group", func(t *testing.T) {
+		require.NoError(t, subscriptionRepo.Replace(t.Context(), 2, "", "", "20", "A", "ИСП-31 (A)"))
+		require.NoError(t, subscriptionRepo.Create(t.Context(), 2, "22", "", "ИСП-33"))
+		require.NoError(t, subscriptionRepo.Replace(t.Context(), 2, "20", "A", "21", "", "ИСП-32"))
+
+		subscriptions, err := subscriptionRepo.FindByStudentID(t.Context(), 2)
+		require.NoError(t, err)
+		require.Len(t, subscriptions, 2)
+		assert.Equal(t, "22", subscriptions[0].Stream)
+		assert.Equal(t, "21", subscriptions[1].Stream)
+		assert.True(t, subscriptions[1].Notify)
+
+		// Replacing the group with itself keeps the subscription
+		require.NoError(t, subscriptionRepo.Replace(t.Context(), 2, "21", "", "21", "", "ИСП-32"))
+
+		subscriptions, err = subscriptionRepo.FindByStudentID(t.Context(), 2)
+		require.NoError(t, err)
+		assert.Len(t, subscriptions, 2)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		subscriptions, err := subscriptionRepo.FindByStudentID(t.Context(), 1)
+		require.NoError(t, err)
+
+		require.NoError(t, subscriptionRepo.Delete(t.Context(), 1, subscriptions[0].ID))
+
+		subscriptions, err = subscriptionRepo.FindByStudentID(t.Context(), 1)
+		require.NoError(t, err)
+		assert.Len(t, subscriptions, 1)
+	})
+}
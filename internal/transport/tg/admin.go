@@ -1,8 +1,14 @@
 package tg
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"pgtk-schedule/internal/models"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"gopkg.in/telebot.v4"
@@ -12,32 +18,357 @@ type adminStudentService interface {
 	ForEach(func(models.Student) error)
 }
 
+type adminOutboxService interface {
+	Enqueue(ctx context.Context, runId string, chatId int64, kind, text string) (int64, error)
+	LastRuns(ctx context.Context) ([]models.RunReport, error)
+}
+
+// inviteTime matches notification defaults of /invite, e.g. "утро=7:00".
+var inviteTime = regexp.MustCompile(`^(утро|вечер)=(\d{1,2}):(\d{2})$`)
+
+const (
+	actionRolloverToggle = "rolloverToggle"
+	actionRolloverApply  = "rolloverApply"
+	actionRolloverCancel = "rolloverCancel"
+)
+
+const rolloverHint = "\nНажмите на строку, чтобы не переносить её. Групповые чаты переносятся вместе со студентами."
+
+var ErrRolloverOutdated = errors.New("Предложение переноса устарело, отправьте /rollover заново")
+
+type adminStreamService interface {
+	Propose(ctx context.Context) ([]models.StreamMapping, error)
+	Apply(ctx context.Context, mappings []models.StreamMapping) (int64, error)
+}
+
+// rolloverDraft is a rollover shown to the admin. Exactly the shown mappings are applied,
+// rows excluded by the admin are skipped.
+type rolloverDraft struct {
+	Mappings []models.StreamMapping
+	Excluded map[int]bool
+}
+
 type admin struct {
 	bot            *telebot.Bot
 	studentService adminStudentService
+	outboxService  adminOutboxService
+	streamService  adminStreamService
+	portal         portal
 	adminId        int64
+
+	mu          sync.Mutex
+	rollovers   map[int]*rolloverDraft
+	rolloverSeq int
 }
 
-func NewAdmin(bot *telebot.Bot, studentService adminStudentService, adminId int64) *admin {
+func NewAdmin(bot *telebot.Bot, studentService adminStudentService, outboxService adminOutboxService, streamService adminStreamService, portal portal, adminId int64) *admin {
 	return &admin{
 		bot:            bot,
 		studentService: studentService,
+		outboxService:  outboxService,
+		streamService:  streamService,
+		portal:         portal,
 		adminId:        adminId,
+		rollovers:      make(map[int]*rolloverDraft),
 	}
 }
 
 func (a *admin) Send() telebot.HandlerFunc {
 	return func(ctx telebot.Context) error {
 		msg := strings.Join(ctx.Args(), " ")
+		runId := models.OutboxBroadcast + "-" + time.Now().Format("2006-01-02T15:04:05")
 
+		var queued int
 		a.studentService.ForEach(func(student models.Student) error {
-			defer time.Sleep(300 * time.Millisecond)
-			_, err := a.bot.Send(&telebot.User{ID: student.ID}, msg)
+			_, err := a.outboxService.Enqueue(context.Background(), runId, student.ID, models.OutboxBroadcast, msg)
+			if err == nil {
+				queued++
+			}
 			return err
 		})
 
-		return nil
+		return ctx.Reply(fmt.Sprintf("Рассылка %s поставлена в очередь: %d сообщений", runId, queued))
+	}
+}
+
+// Report summarises the latest run of every job.
+func (a *admin) Report() telebot.HandlerFunc {
+	return func(ctx telebot.Context) error {
+		reports, err := a.outboxService.LastRuns(context.Background())
+		if err != nil {
+			return err
+		}
+
+		if len(reports) == 0 {
+			return ctx.Reply("Запусков ещё не было")
+		}
+
+		return ctx.Reply(reportsToString(reports))
+	}
+}
+
+// Invite builds a deep link that sets the group and optionally notification time,
+// e.g. "/invite ИСП-21:1 подгруппа утро=7:00 вечер=19:30".
+func (a *admin) Invite() telebot.HandlerFunc {
+	return func(ctx telebot.Context) error {
+		words := strings.Fields(ctx.Message().Payload)
+
+		var link startLink
+		for len(words) > 0 {
+			match := inviteTime.FindStringSubmatch(words[len(words)-1])
+			if match == nil {
+				break
+			}
+
+			hours, _ := strconv.Atoi(match[2])
+			minutes, _ := strconv.Atoi(match[3])
+			if hours > 23 || minutes > 59 {
+				return ctx.Reply(fmt.Sprintf("Время %s:%s указано неверно", match[2], match[3]))
+			}
+
+			minute := hours*60 + minutes
+			if match[1] == "утро" {
+				link.MorningTime = &minute
+			} else {
+				link.EveningTime = &minute
+			}
+			words = words[:len(words)-1]
+		}
+
+		if len(words) == 0 {
+			return ctx.Reply("Укажите группу, подгруппу через двоеточие и при необходимости время уведомлений. Например:\n/invite ИСП-21:1 подгруппа утро=7:00 вечер=19:30")
+		}
+
+		streams := a.portal.Streams()
+		group, err := parseGroup(streams, strings.Join(words, " "))
+		if err != nil {
+			return ctx.Reply(err.Error())
+		}
+
+		for _, stream := range streams {
+			if stream.ID == group.Stream {
+				link.Stream = stream
+				break
+			}
+		}
+		link.Substream = group.Substream
+
+		payload, err := encodeStartLink(link)
+		if err != nil {
+			return err
+		}
+
+		return ctx.Reply(fmt.Sprintf("Ссылка для группы %s:\n%s", group.Title, startLinkURL(a.bot.Me.Username, payload)))
+	}
+}
+
+// Rollover proposes moving students from streams missing on the portal to new ones,
+// the admin excludes wrong rows and applies the rest.
+func (a *admin) Rollover() telebot.HandlerFunc {
+	a.bot.Handle("\f"+actionRolloverToggle, func(ctx telebot.Context) error {
+		if ctx.Sender().ID != a.adminId {
+			return nil
+		}
+
+		args := ctx.Args()
+		if len(args) != 2 {
+			return ErrRolloverOutdated
+		}
+
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+
+		index, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+
+		a.mu.Lock()
+		draft, ok := a.rollovers[id]
+		if !ok {
+			a.mu.Unlock()
+			_, err := a.bot.Edit(ctx.Callback().Message, ErrRolloverOutdated.Error())
+			return err
+		}
+
+		draft.Excluded[index] = !draft.Excluded[index]
+		text, markup := rolloverToString(draft)+rolloverHint, a.rolloverMarkup(id, draft)
+		a.mu.Unlock()
+
+		_, err = a.bot.Edit(ctx.Callback().Message, text, markup)
+		return err
+	})
+
+	a.bot.Handle("\f"+actionRolloverApply, func(ctx telebot.Context) error {
+		if ctx.Sender().ID != a.adminId {
+			return nil
+		}
+
+		draft, ok := a.takeRollover(ctx.Callback().Data)
+		if !ok {
+			_, err := a.bot.Edit(ctx.Callback().Message, ErrRolloverOutdated.Error())
+			return err
+		}
+
+		mappings := make([]models.StreamMapping, 0, len(draft.Mappings))
+		for i, mapping := range draft.Mappings {
+			if draft.Excluded[i] {
+				mapping.New = nil
+			}
+			mappings = append(mappings, mapping)
+		}
+
+		moved, err := a.streamService.Apply(context.Background(), mappings)
+		if err != nil {
+			return err
+		}
+
+		_, err = a.bot.Edit(ctx.Callback().Message, fmt.Sprintf("Перенос выполнен, студентов перенесено: %d. Остальным бот предложит выбрать группу заново.", moved))
+		return err
+	})
+
+	a.bot.Handle("\f"+actionRolloverCancel, func(ctx telebot.Context) error {
+		if ctx.Sender().ID != a.adminId {
+			return nil
+		}
+
+		a.takeRollover(ctx.Callback().Data)
+
+		_, err := a.bot.Edit(ctx.Callback().Message, "Перенос отменён")
+		return err
+	})
+
+	return func(ctx telebot.Context) error {
+		mappings, err := a.streamService.Propose(context.Background())
+		if err != nil {
+			return err
+		}
+
+		if len(mappings) == 0 {
+			return ctx.Reply("Все группы студентов есть на портале, переносить нечего")
+		}
+
+		draft := &rolloverDraft{Mappings: mappings, Excluded: make(map[int]bool)}
+		if rolloverMatched(draft) == 0 {
+			return ctx.Reply(rolloverToString(draft) + "\nПодходящих групп не найдено, студентам будет предложено выбрать группу заново.")
+		}
+
+		a.mu.Lock()
+		a.rolloverSeq++
+		id := a.rolloverSeq
+		a.rollovers[id] = draft
+		a.mu.Unlock()
+
+		return ctx.Reply(rolloverToString(draft)+rolloverHint, a.rolloverMarkup(id, draft))
+	}
+}
+
+func (a *admin) takeRollover(data string) (*rolloverDraft, bool) {
+	id, err := strconv.Atoi(data)
+	if err != nil {
+		return nil, false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	draft, ok := a.rollovers[id]
+	delete(a.rollovers, id)
+
+	return draft, ok
+}
+
+// rolloverMarkup has a button for every matched row to exclude it from the rollover or return it back.
+func (a *admin) rolloverMarkup(id int, draft *rolloverDraft) *telebot.ReplyMarkup {
+	markup := a.bot.NewMarkup()
+	draftId := strconv.Itoa(id)
+
+	rows := make([]telebot.Row, 0, len(draft.Mappings)+1)
+	for i, mapping := range draft.Mappings {
+		if mapping.New == nil {
+			continue
+		}
+
+		text := "✅ " + mapping.Old.Name + " → " + mapping.New.Name
+		if draft.Excluded[i] {
+			text = "❌ " + mapping.Old.Name + " → " + mapping.New.Name
+		}
+		rows = append(rows, markup.Row(markup.Data(text, actionRolloverToggle, draftId, strconv.Itoa(i))))
+	}
+
+	apply := markup.Data("Перенести отмеченные", actionRolloverApply, draftId)
+	if rolloverMatched(draft) == 0 {
+		apply = markup.Data("Нечего переносить", actionNoop)
+	}
+
+	rows = append(rows, markup.Row(apply, markup.Data("Отмена", actionRolloverCancel, draftId)))
+	markup.Inline(rows...)
+
+	return markup
+}
+
+// rolloverMatched returns the number of rows that will be moved.
+func rolloverMatched(draft *rolloverDraft) int {
+	var matched int
+	for i, mapping := range draft.Mappings {
+		if mapping.New != nil && !draft.Excluded[i] {
+			matched++
+		}
 	}
+
+	return matched
+}
+
+func rolloverToString(draft *rolloverDraft) string {
+	var sb strings.Builder
+	sb.WriteString("<b>Перенос студентов на новые группы</b>\n\n")
+
+	for i, mapping := range draft.Mappings {
+		switch {
+		case mapping.New == nil:
+			fmt.Fprintf(&sb, "%s → не найдена (%d студ.)\n", mapping.Old.Name, mapping.Old.Students)
+		case draft.Excluded[i]:
+			fmt.Fprintf(&sb, "%s → не переносится (%d студ.)\n", mapping.Old.Name, mapping.Old.Students)
+		default:
+			fmt.Fprintf(&sb, "%s → %s (%d студ.)\n", mapping.Old.Name, mapping.New.Name, mapping.Old.Students)
+		}
+	}
+
+	return sb.String()
+}
+
+var jobNames = map[string]string{
+	string(models.NotifyMorning): "Утренние уведомления",
+	string(models.NotifyEvening): "Вечерние уведомления",
+	string(models.NotifyWeek):    "Недельные уведомления",
+	models.OutboxReminder:        "Напоминания о парах",
+	models.OutboxResume:          "Возобновление после паузы",
+	models.OutboxBroadcast:       "Рассылка",
+	models.OutboxChange:          "Изменения в расписании",
+	models.OutboxEdit:            "Обновление сообщений",
+	models.OutboxFirstCancel:     "Отмена первой пары",
+	models.OutboxStreamGone:      "Группа пропала с портала",
+}
+
+func reportsToString(reports []models.RunReport) string {
+	var sb strings.Builder
+	sb.WriteString("<b>Последние запуски</b>\n")
+
+	for _, report := range reports {
+		name, ok := jobNames[report.Kind]
+		if !ok {
+			name = report.Kind
+		}
+
+		counts := report.Counts
+		fmt.Fprintf(&sb, "\n<b>%s</b> (%s, с %s)\n", name, report.RunID, report.StartedAt.Format("02.01 15:04"))
+		fmt.Fprintf(&sb, "Отправлено: %d\nВ очереди: %d\nПропущено по настройкам: %d\nПустое расписание: %d\nОшибок: %d\n",
+			counts[models.DeliverySent], counts[models.DeliveryQueued], counts[models.DeliverySkipped], counts[models.DeliveryEmpty], counts[models.DeliveryFailed])
+	}
+
+	return sb.String()
 }
 
 func (a *admin) ValidateAdmin() telebot.MiddlewareFunc {
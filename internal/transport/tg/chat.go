@@ -0,0 +1,407 @@
+package tg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pgtk-schedule/internal/models"
+	"strconv"
+	"time"
+
+	"gopkg.in/telebot.v4"
+)
+
+const (
+	actionChatStream    = "chatStream"
+	actionChatSubstream = "chatSubstream"
+	actionChatShift     = "chatShift"
+	actionChatTogglePin = "chatTogglePin"
+
+	KeyChat = "chat"
+)
+
+var ErrNotChatAdmin = errors.New("Группу чата может изменить только администратор")
+
+type chatService interface {
+	Save(ctx context.Context, id int64, title string) error
+	FindByID(ctx context.Context, id int64) (models.Chat, error)
+	UpdateStream(ctx context.Context, id int64, stream, substream string) error
+	Deactivate(ctx context.Context, id int64) error
+	Migrate(ctx context.Context, from, to int64) error
+	ShiftTime(ctx context.Context, id int64, kind models.NotifyKind, delta int) error
+	TogglePin(ctx context.Context, id int64) error
+	ForEachDue(kind models.NotifyKind, at time.Time, fn func(chat models.Chat) error)
+}
+
+type chatScheduleService interface {
+	DateLessons(stream, substream string, date time.Time) ([]models.Lesson, error)
+	LessonsToString(lessons []models.Lesson) string
+}
+
+type chatOutboxService interface {
+	Enqueue(ctx context.Context, runId string, chatId int64, kind, text string) (int64, error)
+	EnqueuePinned(ctx context.Context, runId string, chatId int64, kind, text string) (int64, error)
+	Record(ctx context.Context, runId string, studentId int64, kind string, status models.DeliveryStatus, cause error) error
+}
+
+type chatMessageService interface {
+	Track(ctx context.Context, message models.ScheduleMessage, week bool) error
+}
+
+type chat struct {
+	bot             *telebot.Bot
+	service         chatService
+	scheduleService chatScheduleService
+	outboxService   chatOutboxService
+	messageService  chatMessageService
+	portal          portal
+	picker          *picker
+}
+
+func NewChat(bot *telebot.Bot, service chatService, scheduleService chatScheduleService, outboxService chatOutboxService, messageService chatMessageService, portal portal, picker *picker) *chat {
+	return &chat{
+		bot:             bot,
+		service:         service,
+		scheduleService: scheduleService,
+		outboxService:   outboxService,
+		messageService:  messageService,
+		portal:          portal,
+		picker:          picker,
+	}
+}
+
+// InGroup handles updates from group chats with group, other updates are passed to the next handler.
+func InGroup(group telebot.HandlerFunc) telebot.MiddlewareFunc {
+	return func(next telebot.HandlerFunc) telebot.HandlerFunc {
+		return func(ctx telebot.Context) error {
+			if isGroup(ctx.Chat()) {
+				return group(ctx)
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+func isGroup(chat *telebot.Chat) bool {
+	return chat != nil && (chat.Type == telebot.ChatGroup || chat.Type == telebot.ChatSuperGroup)
+}
+
+// ChatMember tracks whether the bot is a member of a group chat.
+func (c *chat) ChatMember() telebot.HandlerFunc {
+	return func(ctx telebot.Context) error {
+		update := ctx.ChatMember()
+		if update == nil || update.Chat == nil || update.NewChatMember == nil {
+			return nil
+		}
+
+		switch update.NewChatMember.Role {
+		case telebot.Kicked, telebot.Left:
+			return c.service.Deactivate(context.Background(), update.Chat.ID)
+		case telebot.Member, telebot.Administrator:
+			return c.service.Save(context.Background(), update.Chat.ID, update.Chat.Title)
+		}
+
+		return nil
+	}
+}
+
+// Migrate keeps the stream of a group chat after it is upgraded to a supergroup.
+func (c *chat) Migrate() telebot.HandlerFunc {
+	return func(ctx telebot.Context) error {
+		return c.service.Migrate(context.Background(), ctx.Chat().ID, ctx.Message().MigrateTo)
+	}
+}
+
+// Bound sets the stream of the group chat for schedule handlers.
+func (c *chat) Bound() telebot.MiddlewareFunc {
+	return func(next telebot.HandlerFunc) telebot.HandlerFunc {
+		return func(ctx telebot.Context) error {
+			chat, err := c.service.FindByID(context.Background(), ctx.Chat().ID)
+			if err != nil && !errors.Is(err, models.ErrChatNotFound) {
+				return err
+			}
+
+			if chat.Stream == nil {
+				return ctx.Reply("Группа для этого чата не указана. Администратор чата может указать её командой /setstream")
+			}
+
+			ctx.Set(KeyChat, chat)
+			ctx.Set(KeyStream, *chat.Stream)
+			if chat.Substream != nil {
+				ctx.Set(KeySubstream, *chat.Substream)
+			} else {
+				ctx.Set(KeySubstream, "")
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// SetStream binds the group chat to a stream. Only administrators of the chat may do it.
+func (c *chat) SetStream() telebot.HandlerFunc {
+	c.bot.Handle("\f"+actionChatStream, func(ctx telebot.Context) error {
+		if err := c.validateAdmin(ctx); err != nil {
+			return c.respondError(ctx, err)
+		}
+
+		stream, ok := c.findStream(ctx.Callback().Data)
+		if !ok {
+			return models.ErrStreamIsUnknown
+		}
+
+		if len(stream.Substreams) > 1 {
+			markup := c.bot.NewMarkup()
+
+			btns := make([]telebot.Row, 0, len(stream.Substreams)+1)
+			btns = append(btns, markup.Row(markup.Data("Только общие пары", actionChatSubstream, stream.ID, "")))
+			for _, substream := range stream.Substreams {
+				btns = append(btns, markup.Row(markup.Data(substream, actionChatSubstream, stream.ID, substream)))
+			}
+			markup.Inline(btns...)
+
+			_, err := c.bot.Edit(ctx.Callback().Message, "Выберите подгруппу:", markup)
+			return err
+		}
+
+		substream := ""
+		if len(stream.Substreams) == 1 {
+			substream = stream.Substreams[0]
+		}
+
+		return c.bind(ctx, stream, substream)
+	})
+
+	c.bot.Handle("\f"+actionChatSubstream, func(ctx telebot.Context) error {
+		if err := c.validateAdmin(ctx); err != nil {
+			return c.respondError(ctx, err)
+		}
+
+		args := ctx.Args()
+		if len(args) != 2 {
+			return ErrSubstreamIsInvalid
+		}
+
+		stream, ok := c.findStream(args[0])
+		if !ok {
+			return models.ErrStreamIsUnknown
+		}
+
+		return c.bind(ctx, stream, args[1])
+	})
+
+	return func(ctx telebot.Context) error {
+		if err := c.validateAdmin(ctx); err != nil {
+			if errors.Is(err, ErrNotChatAdmin) {
+				return ctx.Reply(err.Error())
+			}
+			return err
+		}
+
+		if err := c.service.Save(context.Background(), ctx.Chat().ID, ctx.Chat().Title); err != nil {
+			return err
+		}
+
+		return c.picker.Show(ctx, actionChatStream, "Выберите группу для этого чата:", ctx.Message().Payload)
+	}
+}
+
+func (c *chat) bind(ctx telebot.Context, stream models.Stream, substream string) error {
+	if err := c.service.UpdateStream(context.Background(), ctx.Chat().ID, stream.ID, substream); err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("Чат привязан к группе %s!", stream.Name)
+	if substream != "" {
+		text = fmt.Sprintf("Чат привязан к группе %s, подгруппа %s!", stream.Name, substream)
+	}
+
+	_, err := c.bot.Edit(ctx.Callback().Message, text+" Утром и вечером сюда будут приходить пары, а команды /today, /tomorrow и /week покажут расписание группы.")
+	return err
+}
+
+// Settings changes the time of notifications in the group chat and whether the morning schedule is pinned.
+// Only administrators of the chat may do it.
+func (c *chat) Settings() telebot.HandlerFunc {
+	c.bot.Handle("\f"+actionChatShift, func(ctx telebot.Context) error {
+		if err := c.validateAdmin(ctx); err != nil {
+			return c.respondError(ctx, err)
+		}
+
+		args := ctx.Args()
+		if len(args) != 2 {
+			return models.ErrNotifyKindUnknown
+		}
+
+		delta, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+
+		if err := c.service.ShiftTime(context.Background(), ctx.Chat().ID, models.NotifyKind(args[0]), delta); err != nil {
+			return err
+		}
+
+		return c.editSettings(ctx)
+	})
+
+	c.bot.Handle("\f"+actionChatTogglePin, func(ctx telebot.Context) error {
+		if err := c.validateAdmin(ctx); err != nil {
+			return c.respondError(ctx, err)
+		}
+
+		if err := c.service.TogglePin(context.Background(), ctx.Chat().ID); err != nil {
+			return err
+		}
+
+		return c.editSettings(ctx)
+	})
+
+	return func(ctx telebot.Context) error {
+		if err := c.validateAdmin(ctx); err != nil {
+			if errors.Is(err, ErrNotChatAdmin) {
+				return ctx.Reply("Настройки чата может изменить только администратор")
+			}
+			return err
+		}
+
+		chat, err := c.service.FindByID(context.Background(), ctx.Chat().ID)
+		if err != nil && !errors.Is(err, models.ErrChatNotFound) {
+			return err
+		}
+
+		if chat.Stream == nil {
+			return ctx.Reply("Сначала укажите группу для этого чата командой /setstream")
+		}
+
+		return ctx.Reply("Настройки чата:", c.buildSettingsMarkup(chat))
+	}
+}
+
+func (c *chat) editSettings(ctx telebot.Context) error {
+	chat, err := c.service.FindByID(context.Background(), ctx.Chat().ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.bot.Edit(ctx.Callback().Message, "Настройки чата:", c.buildSettingsMarkup(chat))
+	return err
+}
+
+func (c *chat) buildSettingsMarkup(chat models.Chat) *telebot.ReplyMarkup {
+	markup := c.bot.NewMarkup()
+
+	rows := make([]telebot.Row, 0, 7)
+	for _, kind := range [...]models.NotifyKind{models.NotifyMorning, models.NotifyEvening} {
+		title := "🌅 Утром в " + formatMinute(chat.Schedule(kind))
+		if kind == models.NotifyEvening {
+			title = "🌙 Вечером в " + formatMinute(chat.Schedule(kind))
+		}
+
+		rows = append(rows, markup.Row(markup.Data(title, actionNoop)))
+		rows = append(rows, buildShiftRows(markup, actionChatShift, kind)...)
+	}
+
+	rows = append(rows, markup.Row(markup.Data(toggleText("Закреплять утреннее расписание", chat.Pin), actionChatTogglePin)))
+
+	markup.Inline(rows...)
+	return markup
+}
+
+func (c *chat) findStream(id string) (models.Stream, bool) {
+	for _, stream := range c.portal.Streams() {
+		if stream.ID == id {
+			return stream, true
+		}
+	}
+
+	return models.Stream{}, false
+}
+
+func (c *chat) respondError(ctx telebot.Context, err error) error {
+	if errors.Is(err, ErrNotChatAdmin) {
+		return ctx.Respond(&telebot.CallbackResponse{Text: err.Error()})
+	}
+
+	return err
+}
+
+func (c *chat) validateAdmin(ctx telebot.Context) error {
+	// Anonymous administrators write on behalf of the chat
+	if msg := ctx.Message(); msg != nil && msg.SenderChat != nil && msg.SenderChat.ID == ctx.Chat().ID {
+		return nil
+	}
+
+	member, err := c.bot.ChatMemberOf(ctx.Chat(), ctx.Sender())
+	if err != nil {
+		return err
+	}
+
+	if member.Role != telebot.Administrator && member.Role != telebot.Creator {
+		return ErrNotChatAdmin
+	}
+
+	return nil
+}
+
+// Dispatch queues morning and evening lessons to group chats scheduled at the minute of at.
+// Days are counted from at, so a slot after midnight gets the new day.
+func (c *chat) Dispatch(at time.Time) {
+	jobs := [...]struct {
+		Kind   models.NotifyKind
+		Header string
+		Date   time.Time
+	}{
+		{Kind: models.NotifyMorning, Header: "<b>Пары на сегодня. Расписание может измениться в любой момент!</b>\n\n", Date: at},
+		{Kind: models.NotifyEvening, Header: "<b>Пары на завтра. Расписание может измениться в любой момент!</b>\n\n", Date: at.AddDate(0, 0, 1)},
+	}
+
+	for _, job := range jobs {
+		runId := models.RunID(string(job.Kind), at)
+		lessonsFn := func(stream, substream string) ([]models.Lesson, error) {
+			return c.scheduleService.DateLessons(stream, substream, job.Date)
+		}
+
+		c.service.ForEachDue(job.Kind, at, func(chat models.Chat) error {
+			return c.send(runId, chat, job.Kind, job.Header, lessonsFn)
+		})
+	}
+}
+
+func (c *chat) send(runId string, chat models.Chat, kind models.NotifyKind, header string, lessonsFn func(stream, substream string) ([]models.Lesson, error)) error {
+	substream := ""
+	if chat.Substream != nil {
+		substream = *chat.Substream
+	}
+
+	lessons, err := lessonsFn(*chat.Stream, substream)
+	if err != nil {
+		status := models.DeliveryFailed
+		if errors.Is(err, models.ErrLessonsAreEmpty) {
+			status, err = models.DeliveryEmpty, nil
+		}
+
+		return c.outboxService.Record(context.Background(), runId, chat.ID, string(kind), status, err)
+	}
+
+	// The morning schedule stays pinned for the day and is edited in place when lessons change
+	enqueue := c.outboxService.Enqueue
+	if kind == models.NotifyMorning && chat.Pin {
+		enqueue = c.outboxService.EnqueuePinned
+	}
+
+	outboxId, err := enqueue(context.Background(), runId, chat.ID, string(kind), header+c.scheduleService.LessonsToString(lessons))
+	if err != nil {
+		return err
+	}
+
+	return c.messageService.Track(context.Background(), models.ScheduleMessage{
+		ChatID:    chat.ID,
+		OutboxID:  &outboxId,
+		Stream:    *chat.Stream,
+		Substream: substream,
+		Header:    header,
+		Lessons:   lessons,
+	}, false)
+}
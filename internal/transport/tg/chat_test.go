@@ -0,0 +1,41 @@
+package tg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v4"
+)
+
+func TestInGroup(t *testing.T) {
+	bot, err := telebot.NewBot(telebot.Settings{Offline: true})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		chatType telebot.ChatType
+		expected string
+	}{
+		{name: "private chat", chatType: telebot.ChatPrivate, expected: "private"},
+		{name: "group", chatType: telebot.ChatGroup, expected: "group"},
+		{name: "supergroup", chatType: telebot.ChatSuperGroup, expected: "group"},
+		{name: "channel", chatType: telebot.ChatChannel, expected: "private"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handled string
+			handler := func(name string) telebot.HandlerFunc {
+				return func(ctx telebot.Context) error {
+					handled = name
+					return nil
+				}
+			}
+
+			ctx := bot.NewContext(telebot.Update{Message: &telebot.Message{Chat: &telebot.Chat{ID: -1, Type: tt.chatType}}})
+			require.NoError(t, InGroup(handler("group"))(handler("private"))(ctx))
+			assert.Equal(t, tt.expected, handled)
+		})
+	}
+}
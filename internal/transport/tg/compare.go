@@ -0,0 +1,197 @@
+package tg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pgtk-schedule/internal/models"
+	"strings"
+
+	"gopkg.in/telebot.v4"
+)
+
+// maxMessageLength is a Telegram limit for message text.
+const maxMessageLength = 4096
+
+type compareService interface {
+	Week(groups []models.Group) ([]models.DayComparison, error)
+	DayToString(groups []models.Group, day models.DayComparison) string
+}
+
+type compareSubscriptionService interface {
+	FindByStudentID(ctx context.Context, studentId int64) ([]models.Subscription, error)
+	Title(subscription models.Subscription) string
+}
+
+type compare struct {
+	service             compareService
+	subscriptionService compareSubscriptionService
+	portal              portal
+}
+
+func NewCompare(service compareService, subscriptionService compareSubscriptionService, portal portal) *compare {
+	return &compare{
+		service:             service,
+		subscriptionService: subscriptionService,
+		portal:              portal,
+	}
+}
+
+// Groups compares groups from the command payload, e.g. "/compare ИСП-21:1 подгруппа; ИСП-22".
+// Without payload subscriptions of the student are compared.
+func (c *compare) Groups() telebot.HandlerFunc {
+	return func(ctx telebot.Context) error {
+		var groups []models.Group
+		payload := strings.TrimSpace(ctx.Message().Payload)
+		if payload != "" {
+			parsed, err := c.parse(payload)
+			if err != nil {
+				return ctx.Reply(err.Error())
+			}
+			groups = parsed
+		} else {
+			subscriptions, err := c.subscriptionService.FindByStudentID(context.Background(), ctx.Sender().ID)
+			if err != nil {
+				return err
+			}
+
+			for _, subscription := range subscriptions {
+				groups = append(groups, models.Group{
+					Stream:    subscription.Stream,
+					Substream: subscription.Substream,
+					Title:     c.subscriptionService.Title(subscription),
+				})
+			}
+		}
+
+		days, err := c.service.Week(groups)
+		if err != nil {
+			if errors.Is(err, models.ErrNotEnoughGroups) {
+				return ctx.Reply("Укажите минимум две группы через точку с запятой, подгруппу — через двоеточие. Например:\n/compare ИСП-21:1; ИСП-22\n\nБез параметров сравниваются группы из /groups.")
+			}
+			if errors.Is(err, models.ErrLessonsAreEmpty) {
+				return ctx.Reply("Расписание не найдено! Попробуйте ещё раз через пару минут, если считаете, что это ошибка.")
+			}
+			return err
+		}
+
+		// Days are kept in one message when they fit, a longer day is split by lessons
+		var msgs []string
+		msg := ""
+		for _, day := range days {
+			text := c.service.DayToString(groups, day) + "\n"
+			if msg != "" && len(msg)+len(text) > maxMessageLength {
+				msgs = append(msgs, msg)
+				msg = ""
+			}
+			msg += text
+		}
+		msgs = append(msgs, msg)
+
+		for _, msg := range msgs {
+			for _, part := range splitMessage(msg, maxMessageLength) {
+				if err := ctx.Send(part); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
+// splitMessage splits text by lines into parts within limit. A <pre> block cut by a split is closed
+// and reopened in the next part, so every part stays valid HTML.
+func splitMessage(text string, limit int) []string {
+	var parts []string
+	var sb strings.Builder
+	inPre := false
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		closing := ""
+		if inPre {
+			closing = "</pre>"
+		}
+
+		if sb.Len() > 0 && sb.Len()+len(line)+len(closing) > limit {
+			parts = append(parts, sb.String()+closing)
+			sb.Reset()
+			if inPre {
+				sb.WriteString("<pre>")
+			}
+		}
+		sb.WriteString(line)
+
+		if open, end := strings.LastIndex(line, "<pre>"), strings.LastIndex(line, "</pre>"); open != -1 || end != -1 {
+			inPre = open > end
+		}
+	}
+
+	if sb.Len() > 0 {
+		parts = append(parts, sb.String())
+	}
+
+	return parts
+}
+
+func (c *compare) parse(payload string) ([]models.Group, error) {
+	streams := c.portal.Streams()
+
+	parts := strings.Split(payload, ";")
+	groups := make([]models.Group, 0, len(parts))
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+
+		group, err := parseGroup(streams, part)
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// parseGroup finds the group by its name and substream separated by a colon, e.g. "ИСП-21:1 подгруппа".
+func parseGroup(streams []models.Stream, text string) (models.Group, error) {
+	name, substream, _ := strings.Cut(text, ":")
+	name = strings.TrimSpace(name)
+	substream = strings.TrimSpace(substream)
+
+	var found models.Stream
+	for _, stream := range streams {
+		if strings.EqualFold(stream.Name, name) {
+			found = stream
+			break
+		}
+	}
+
+	if found.ID == "" {
+		return models.Group{}, fmt.Errorf("Группа %s не найдена", name)
+	}
+
+	group := models.Group{Stream: found.ID, Title: found.Name}
+	if len(found.Substreams) > 0 {
+		if substream == "" {
+			return models.Group{}, fmt.Errorf("Укажите подгруппу для %s: %s", found.Name, strings.Join(found.Substreams, ", "))
+		}
+
+		for _, s := range found.Substreams {
+			if strings.EqualFold(s, substream) {
+				group.Substream = s
+				break
+			}
+		}
+
+		if group.Substream == "" {
+			return models.Group{}, fmt.Errorf("Подгруппа %s не найдена в группе %s", substream, found.Name)
+		}
+
+		group.Title += " (" + group.Substream + ")"
+	}
+
+	return group, nil
+}
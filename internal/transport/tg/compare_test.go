@@ -0,0 +1,37 @@
+package tg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		limit    int
+		expected []string
+	}{
+		{name: "fits", text: "day\nlesson\n", limit: 100, expected: []string{"day\nlesson\n"}},
+		{name: "split by lines", text: "first\nsecond\n", limit: 8, expected: []string{"first\n", "second\n"}},
+		{
+			name:     "pre block is reopened",
+			text:     "day\n<pre>08:00 a\n10:00 b\n12:00 c</pre>\n",
+			limit:    31,
+			expected: []string{"day\n<pre>08:00 a\n10:00 b\n</pre>", "<pre>12:00 c</pre>\n"},
+		},
+		{name: "empty", text: "", limit: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := splitMessage(tt.text, tt.limit)
+			assert.Equal(t, tt.expected, parts)
+			for _, part := range parts {
+				assert.NotEmpty(t, strings.TrimSpace(part))
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package tg
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"pgtk-schedule/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/telebot.v4"
+)
+
+// maxInlineResults limits inline results, Telegram accepts up to 50.
+const maxInlineResults = 20
+
+var inlineDays = map[string]int{
+	"сегодня": 0,
+	"завтра":  1,
+}
+
+var inlineWeekdays = map[string]time.Weekday{
+	"пн": time.Monday, "понедельник": time.Monday,
+	"вт": time.Tuesday, "вторник": time.Tuesday,
+	"ср": time.Wednesday, "среда": time.Wednesday, "среду": time.Wednesday,
+	"чт": time.Thursday, "четверг": time.Thursday,
+	"пт": time.Friday, "пятница": time.Friday, "пятницу": time.Friday,
+	"сб": time.Saturday, "суббота": time.Saturday, "субботу": time.Saturday,
+	"вс": time.Sunday, "воскресенье": time.Sunday,
+}
+
+type inlineScheduleService interface {
+	DateLessons(stream, substream string, date time.Time) ([]models.Lesson, error)
+	CurrentWeekLessons(stream, substream string) ([]models.Lesson, error)
+	LessonsToString(lessons []models.Lesson) string
+}
+
+// inlineQuery is a parsed inline query, e.g. "ИСП-21 завтра".
+type inlineQuery struct {
+	Stream string
+	// Date is zero when the whole week is requested.
+	Date  time.Time
+	Title string
+}
+
+type inline struct {
+	scheduleService inlineScheduleService
+	portal          portal
+	loc             *time.Location
+}
+
+func NewInline(scheduleService inlineScheduleService, portal portal, loc *time.Location) *inline {
+	return &inline{
+		scheduleService: scheduleService,
+		portal:          portal,
+		loc:             loc,
+	}
+}
+
+// Query answers inline queries like "@bot ИСП-21 завтра" with schedules of matching streams,
+// so students can share a schedule in any chat.
+func (i *inline) Query() telebot.HandlerFunc {
+	return func(ctx telebot.Context) error {
+		query := parseInlineQuery(ctx.Query().Text, time.Now().In(i.loc))
+		if query.Stream == "" {
+			return ctx.Answer(&telebot.QueryResponse{CacheTime: 60})
+		}
+
+		return ctx.Answer(&telebot.QueryResponse{Results: i.results(query), CacheTime: 60})
+	}
+}
+
+// results returns schedules of streams matching the query. A stream whose schedule fails to load is skipped,
+// so it does not break the answer for others.
+func (i *inline) results(query inlineQuery) telebot.Results {
+	results := make(telebot.Results, 0, maxInlineResults)
+	for _, stream := range matchStreams(i.portal.Streams(), query.Stream) {
+		substreams := stream.Substreams
+		if len(substreams) == 0 {
+			substreams = []string{""}
+		}
+
+		for _, substream := range substreams {
+			if len(results) == maxInlineResults {
+				return results
+			}
+
+			result, err := i.result(stream, substream, query)
+			if err != nil {
+				log.Println(err.Error(), stream.ID, substream)
+				continue
+			}
+
+			result.ID = strconv.Itoa(len(results))
+			results = append(results, result)
+		}
+	}
+
+	return results
+}
+
+func (i *inline) result(stream models.Stream, substream string, query inlineQuery) (*telebot.ArticleResult, error) {
+	title := stream.Name
+	if substream != "" {
+		title += " (" + substream + ")"
+	}
+	title += ", " + query.Title
+
+	var lessons []models.Lesson
+	var err error
+	if query.Date.IsZero() {
+		lessons, err = i.scheduleService.CurrentWeekLessons(stream.ID, substream)
+	} else {
+		lessons, err = i.scheduleService.DateLessons(stream.ID, substream, query.Date)
+	}
+	if err != nil && !errors.Is(err, models.ErrLessonsAreEmpty) {
+		return nil, err
+	}
+
+	text := "Пар нет"
+	description := "Пар нет"
+	if len(lessons) > 0 {
+		text = i.scheduleService.LessonsToString(lessons)
+		description = fmt.Sprintf("Пар: %d, первая в %s", len(lessons), lessons[0].DateStart.Format("15:04"))
+	}
+
+	result := &telebot.ArticleResult{Title: title, Description: description}
+	result.Content = &telebot.InputTextMessageContent{Text: truncateMessage(fmt.Sprintf("<b>%s</b>\n\n%s", title, text), maxMessageLength)}
+
+	return result, nil
+}
+
+// truncateMessage cuts text by lines to fit limit, so a full week of lessons can be shared inline.
+func truncateMessage(text string, limit int) string {
+	const more = "\n…\nПолное расписание — в боте по команде /week"
+	if len(text) <= limit {
+		return text
+	}
+
+	cut := max(strings.LastIndex(text[:limit-len(more)], "\n"), 0)
+	return text[:cut] + more
+}
+
+// parseInlineQuery splits the query into a stream name and an optional day at the end.
+// Without a day lessons of today are requested.
+func parseInlineQuery(text string, now time.Time) inlineQuery {
+	words := strings.Fields(text)
+	// Noon keeps AddDate away from day boundaries, DateLessons compares dates in the college timezone
+	today := time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, now.Location())
+	query := inlineQuery{Date: today, Title: "сегодня"}
+
+	if len(words) > 1 {
+		day := strings.ToLower(words[len(words)-1])
+		found := true
+
+		if offset, ok := inlineDays[day]; ok {
+			query.Date, query.Title = today.AddDate(0, 0, offset), day
+		} else if weekday, ok := inlineWeekdays[day]; ok {
+			// Days of the current week starting on Monday
+			offset := (int(weekday)+6)%7 - (int(today.Weekday())+6)%7
+			query.Date, query.Title = today.AddDate(0, 0, offset), day
+		} else if day == "неделя" || day == "неделю" {
+			query.Date, query.Title = time.Time{}, "неделя"
+		} else {
+			found = false
+		}
+
+		if found {
+			words = words[:len(words)-1]
+		}
+	}
+
+	query.Stream = strings.Join(words, " ")
+	return query
+}
+
+// matchStreams returns streams whose name contains name ignoring case and dashes.
+// Exact matches go first.
+func matchStreams(streams []models.Stream, name string) []models.Stream {
+	normalize := func(s string) string {
+		return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(s))
+	}
+
+	name = normalize(name)
+
+	matched := make([]models.Stream, 0)
+	for _, stream := range streams {
+		current := normalize(stream.Name)
+		if current == name {
+			matched = append([]models.Stream{stream}, matched...)
+		} else if strings.Contains(current, name) {
+			matched = append(matched, stream)
+		}
+	}
+
+	return matched
+}
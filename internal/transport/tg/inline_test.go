@@ -0,0 +1,126 @@
+package tg
+
+import (
+	"pgtk-schedule/internal/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v4"
+)
+
+func TestParseInlineQuery(t *testing.T) {
+	loc := time.FixedZone("UTC+5", 5*60*60)
+	// Wednesday
+	now := time.Date(2025, time.May, 21, 9, 30, 0, 0, loc)
+	day := func(d int) time.Time {
+		return time.Date(2025, time.May, d, 12, 0, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name     string
+		text     string
+		expected inlineQuery
+	}{
+		{name: "empty", text: "  ", expected: inlineQuery{Date: day(21), Title: "сегодня"}},
+		{name: "stream only", text: "ИСП-21", expected: inlineQuery{Stream: "ИСП-21", Date: day(21), Title: "сегодня"}},
+		{name: "day only is a stream", text: "завтра", expected: inlineQuery{Stream: "завтра", Date: day(21), Title: "сегодня"}},
+		{name: "tomorrow", text: "ИСП-21 Завтра", expected: inlineQuery{Stream: "ИСП-21", Date: day(22), Title: "завтра"}},
+		{name: "earlier weekday", text: "ИСП 21 пн", expected: inlineQuery{Stream: "ИСП 21", Date: day(19), Title: "пн"}},
+		{name: "sunday", text: "ИСП-21 воскресенье", expected: inlineQuery{Stream: "ИСП-21", Date: day(25), Title: "воскресенье"}},
+		{name: "week", text: "ИСП-21 неделю", expected: inlineQuery{Stream: "ИСП-21", Title: "неделя"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, parseInlineQuery(tt.text, now))
+		})
+	}
+}
+
+func TestMatchStreams(t *testing.T) {
+	streams := []models.Stream{
+		{ID: "1", Name: "ИСП-211"},
+		{ID: "2", Name: "ИСП-21"},
+		{ID: "3", Name: "ПКС-21"},
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{name: "exact match first", query: "исп21", expected: []string{"2", "1"}},
+		{name: "substring", query: "21", expected: []string{"1", "2", "3"}},
+		{name: "no matches", query: "ТМ", expected: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := make([]string, 0)
+			for _, stream := range matchStreams(streams, tt.query) {
+				ids = append(ids, stream.ID)
+			}
+			assert.Equal(t, tt.expected, ids)
+		})
+	}
+}
+
+func TestTruncateMessage(t *testing.T) {
+	short := "<b>ИСП-21</b>\n\nПары"
+	assert.Equal(t, short, truncateMessage(short, maxMessageLength))
+
+	long := strings.Repeat("<b>1)</b> Программирование\n", 300)
+	truncated := truncateMessage(long, maxMessageLength)
+	assert.LessOrEqual(t, len(truncated), maxMessageLength)
+	assert.True(t, strings.HasPrefix(truncated, "<b>1)</b> Программирование\n"))
+	assert.True(t, strings.HasSuffix(truncated, "/week"))
+	// Lines are not cut in the middle
+	assert.Contains(t, truncated, "Программирование\n…")
+}
+
+// fakeInlineSchedule returns lessons by stream, streams missing from the map are unknown.
+type fakeInlineSchedule map[string][]models.Lesson
+
+func (f fakeInlineSchedule) DateLessons(stream, substream string, date time.Time) ([]models.Lesson, error) {
+	return f.CurrentWeekLessons(stream, substream)
+}
+
+func (f fakeInlineSchedule) CurrentWeekLessons(stream, substream string) ([]models.Lesson, error) {
+	lessons, ok := f[stream]
+	if !ok {
+		return nil, models.ErrStreamIsUnknown
+	}
+	if len(lessons) == 0 {
+		return nil, models.ErrLessonsAreEmpty
+	}
+	return lessons, nil
+}
+
+func (f fakeInlineSchedule) LessonsToString(lessons []models.Lesson) string {
+	return lessons[0].Name
+}
+
+func TestInlineResults(t *testing.T) {
+	streams := fakePortal{
+		{ID: "1", Name: "ИСП-21"},
+		{ID: "2", Name: "ИСП-22"},
+		{ID: "3", Name: "ИСП-23"},
+	}
+	schedule := fakeInlineSchedule{
+		"1": {{Name: "Go", DateStart: time.Date(2025, time.February, 3, 8, 30, 0, 0, time.UTC)}},
+		"3": {},
+	}
+
+	i := NewInline(schedule, streams, time.UTC)
+	results := i.results(inlineQuery{Stream: "исп", Date: time.Date(2025, time.February, 3, 12, 0, 0, 0, time.UTC), Title: "сегодня"})
+
+	// The unknown stream is skipped, the rest are answered
+	require.Len(t, results, 2)
+	assert.Equal(t, "ИСП-21, сегодня", results[0].(*telebot.ArticleResult).Title)
+	assert.Equal(t, "ИСП-23, сегодня", results[1].(*telebot.ArticleResult).Title)
+	assert.Equal(t, "Пар нет", results[1].(*telebot.ArticleResult).Description)
+	assert.Equal(t, "1", results[1].ResultID())
+}
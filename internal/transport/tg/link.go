@@ -0,0 +1,132 @@
+package tg
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"pgtk-schedule/internal/models"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrStartLinkInvalid = errors.New("Ссылка устарела или указана неверно")
+
+// startLinkStream limits stream IDs to characters allowed in /start payloads.
+var startLinkStream = regexp.MustCompile(`^[0-9A-Za-z]+$`)
+
+// startLink configures a student from a /start deep link.
+type startLink struct {
+	Stream    models.Stream
+	Substream string
+	// MorningTime and EveningTime are minutes since midnight, nil keeps the current time.
+	MorningTime *int
+	EveningTime *int
+}
+
+// encodeStartLink builds a /start payload like "g123_s1es6l2j_m420_e1140". Substreams are encoded by a hash
+// of the name, because names are not allowed in the payload and their order may change on the portal.
+func encodeStartLink(link startLink) (string, error) {
+	if !startLinkStream.MatchString(link.Stream.ID) {
+		return "", ErrStartLinkInvalid
+	}
+
+	var sb strings.Builder
+	sb.WriteString("g" + link.Stream.ID)
+
+	if link.Substream != "" {
+		if !slices.Contains(link.Stream.Substreams, link.Substream) {
+			return "", ErrSubstreamIsInvalid
+		}
+		sb.WriteString("_s" + substreamHash(link.Substream))
+	}
+
+	if link.MorningTime != nil {
+		fmt.Fprintf(&sb, "_m%d", *link.MorningTime)
+	}
+
+	if link.EveningTime != nil {
+		fmt.Fprintf(&sb, "_e%d", *link.EveningTime)
+	}
+
+	return sb.String(), nil
+}
+
+// decodeStartLink parses a payload built by encodeStartLink.
+func decodeStartLink(streams []models.Stream, payload string) (startLink, error) {
+	parts := strings.Split(payload, "_")
+	if !strings.HasPrefix(parts[0], "g") {
+		return startLink{}, ErrStartLinkInvalid
+	}
+
+	var link startLink
+	id := strings.TrimPrefix(parts[0], "g")
+	for _, stream := range streams {
+		if stream.ID == id {
+			link.Stream = stream
+			break
+		}
+	}
+
+	if link.Stream.ID == "" {
+		return startLink{}, ErrStartLinkInvalid
+	}
+
+	for _, part := range parts[1:] {
+		if len(part) < 2 {
+			return startLink{}, ErrStartLinkInvalid
+		}
+
+		if part[0] == 's' {
+			index := slices.IndexFunc(link.Stream.Substreams, func(substream string) bool {
+				return substreamHash(substream) == part[1:]
+			})
+			if index == -1 {
+				return startLink{}, ErrStartLinkInvalid
+			}
+
+			link.Substream = link.Stream.Substreams[index]
+			continue
+		}
+
+		value, err := strconv.Atoi(part[1:])
+		if err != nil {
+			return startLink{}, ErrStartLinkInvalid
+		}
+
+		switch part[0] {
+		case 'm', 'e':
+			if value < 0 || value >= 24*60 {
+				return startLink{}, ErrStartLinkInvalid
+			}
+
+			if part[0] == 'm' {
+				link.MorningTime = &value
+			} else {
+				link.EveningTime = &value
+			}
+		default:
+			return startLink{}, ErrStartLinkInvalid
+		}
+	}
+
+	// A stream with a single substream is set the same way as by /setstream
+	if link.Substream == "" && len(link.Stream.Substreams) == 1 {
+		link.Substream = link.Stream.Substreams[0]
+	}
+
+	return link, nil
+}
+
+// substreamHash returns a short code of the substream name that is allowed in /start payloads.
+func substreamHash(substream string) string {
+	h := fnv.New32a()
+	h.Write([]byte(substream))
+	return strconv.FormatUint(uint64(h.Sum32()), 36)
+}
+
+// startLinkURL returns a link that opens the bot and sends /start with the payload.
+func startLinkURL(username, payload string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s", username, payload)
+}
@@ -0,0 +1,73 @@
+package tg
+
+import (
+	"pgtk-schedule/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartLink(t *testing.T) {
+	streams := []models.Stream{
+		{ID: "101", Name: "ИСП-21", Substreams: []string{"1 подгруппа", "2 подгруппа"}},
+		{ID: "102", Name: "ПКС-21", Substreams: []string{"1 подгруппа"}},
+		{ID: "103", Name: "ТМ-21"},
+	}
+	minute := func(m int) *int {
+		return &m
+	}
+
+	tests := []struct {
+		name    string
+		link    startLink
+		payload string
+	}{
+		{name: "stream", link: startLink{Stream: streams[2]}, payload: "g103"},
+		{name: "substream", link: startLink{Stream: streams[0], Substream: "2 подгруппа"}, payload: "g101_s6tj33s"},
+		{name: "single substream", link: startLink{Stream: streams[1], Substream: "1 подгруппа"}, payload: "g102_s1es6l2j"},
+		{
+			name:    "notification time",
+			link:    startLink{Stream: streams[0], Substream: "1 подгруппа", MorningTime: minute(420), EveningTime: minute(1170)},
+			payload: "g101_s1es6l2j_m420_e1170",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := encodeStartLink(tt.link)
+			require.NoError(t, err)
+			assert.Equal(t, tt.payload, payload)
+
+			link, err := decodeStartLink(streams, payload)
+			require.NoError(t, err)
+			assert.Equal(t, tt.link, link)
+		})
+	}
+
+	t.Run("single substream is set without index", func(t *testing.T) {
+		link, err := decodeStartLink(streams, "g102")
+		require.NoError(t, err)
+		assert.Equal(t, "1 подгруппа", link.Substream)
+	})
+
+	t.Run("reordered substreams keep the substream", func(t *testing.T) {
+		reordered := []models.Stream{{ID: "101", Name: "ИСП-21", Substreams: []string{"2 подгруппа", "1 подгруппа"}}}
+
+		link, err := decodeStartLink(reordered, "g101_s6tj33s")
+		require.NoError(t, err)
+		assert.Equal(t, "2 подгруппа", link.Substream)
+	})
+
+	t.Run("invalid payloads", func(t *testing.T) {
+		for _, payload := range []string{"", "101", "g999", "g101_s2", "g101_s1", "g101_s", "g101_m1440", "g101_x1", "g101_m-5"} {
+			_, err := decodeStartLink(streams, payload)
+			assert.ErrorIs(t, err, ErrStartLinkInvalid, payload)
+		}
+	})
+
+	t.Run("stream id not allowed in payload", func(t *testing.T) {
+		_, err := encodeStartLink(startLink{Stream: models.Stream{ID: "a b"}})
+		assert.ErrorIs(t, err, ErrStartLinkInvalid)
+	})
+}
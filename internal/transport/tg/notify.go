@@ -3,26 +3,86 @@ package tg
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
 	"pgtk-schedule/internal/models"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/telebot.v4"
 )
 
 const (
-	actionToggleMorning = "toggleMorning"
-	actionToggleEvening = "toggleEvening"
-	actionToggleWeek    = "toggleWeek"
+	actionToggleMorning  = "toggleMorning"
+	actionToggleEvening  = "toggleEvening"
+	actionToggleWeek     = "toggleWeek"
+	actionNotifyPicker   = "notifyPicker"
+	actionNotifyShift    = "notifyShift"
+	actionNotifyDay      = "notifyDay"
+	actionNotifyBack     = "notifyBack"
+	actionToggleReminder = "toggleReminder"
+	actionReminderLead   = "reminderLead"
+	actionPauseCalendar  = "pauseCalendar"
+	actionPause          = "pause"
+	actionResume         = "resume"
+	actionNoop           = "noop"
 )
 
+const (
+	// pauseResumeHour is the hour students are told about resumed notifications, so nobody is woken at midnight.
+	// Students with an earlier notification are told right before it.
+	pauseResumeHour = 7
+	// pauseMonths limits how far ahead notifications may be paused.
+	pauseMonths = 6
+)
+
+var (
+	notifyKindNames = map[models.NotifyKind]string{
+		models.NotifyMorning: "утренних",
+		models.NotifyEvening: "вечерних",
+		models.NotifyWeek:    "недельных",
+	}
+
+	reminderLeads = [...]int{5, 10, 15, 20, 30, 45, 60}
+
+	shortWeekdays = [...]struct {
+		Weekday time.Weekday
+		Name    string
+	}{
+		{Weekday: time.Monday, Name: "Пн"},
+		{Weekday: time.Tuesday, Name: "Вт"},
+		{Weekday: time.Wednesday, Name: "Ср"},
+		{Weekday: time.Thursday, Name: "Чт"},
+		{Weekday: time.Friday, Name: "Пт"},
+		{Weekday: time.Saturday, Name: "Сб"},
+		{Weekday: time.Sunday, Name: "Вс"},
+	}
+
+	monthNames = [...]string{"Январь", "Февраль", "Март", "Апрель", "Май", "Июнь", "Июль", "Август", "Сентябрь", "Октябрь", "Ноябрь", "Декабрь"}
+
+	pausePresets = [...]struct {
+		Text   string
+		Days   int
+		Months int
+	}{
+		{Text: "1 неделя", Days: 7},
+		{Text: "2 недели", Days: 14},
+		{Text: "1 месяц", Months: 1},
+	}
+)
+
+const streamGoneMessage = "⚠️ Ваша группа больше не найдена на портале, скорее всего начался новый учебный год. Уведомления приостановлены, выберите группу заново командой /setstream"
+
 type studentServiceForNotify interface {
-	ForEach(func(student models.Student) error)
+	ForEachDue(kind models.NotifyKind, at time.Time, fn func(student models.Student) error)
+	ForEachSkipped(kind models.NotifyKind, at time.Time, fn func(student models.Student) error)
+	MarkMissingStreams(ctx context.Context, streams []models.Stream) ([]int64, error)
 }
 
 type scheduleServiceForNotify interface {
 	CurrentWeekLessons(stream, substream string) ([]models.Lesson, error)
-	TodayLessons(stream, substream string) ([]models.Lesson, error)
-	TomorrowLessons(stream, substream string) ([]models.Lesson, error)
+	DateLessons(stream, substream string, date time.Time) ([]models.Lesson, error)
 	LessonsToString(lessons []models.Lesson) string
 }
 
@@ -31,6 +91,49 @@ type notifySettingsService interface {
 	ToggleMorning(ctx context.Context, studentId int64) error
 	ToggleEvening(ctx context.Context, studentId int64) error
 	ToggleWeek(ctx context.Context, studentId int64) error
+	ShiftTime(ctx context.Context, studentId int64, kind models.NotifyKind, delta int) error
+	ToggleDay(ctx context.Context, studentId int64, kind models.NotifyKind, weekday time.Weekday) error
+	ToggleReminder(ctx context.Context, studentId int64) error
+	UpdateReminderLead(ctx context.Context, studentId int64, lead int) error
+	Pause(ctx context.Context, studentId int64, until time.Time) error
+	Resume(ctx context.Context, studentId int64) error
+	ResumeExpired(ctx context.Context, today time.Time) ([]int64, error)
+	ResumeDue(ctx context.Context, at time.Time) ([]int64, error)
+}
+
+type reminderServiceForNotify interface {
+	RebuildStudent(ctx context.Context, studentId int64, now time.Time) error
+	Due(at time.Time) []models.Reminder
+}
+
+type outboxServiceForNotify interface {
+	Enqueue(ctx context.Context, runId string, chatId int64, kind, text string) (int64, error)
+	EnqueueOnce(ctx context.Context, runId string, chatId int64, kind, key, text string) (bool, error)
+	EnqueueEdit(ctx context.Context, runId string, chatId int64, messageId int64, text string) (int64, error)
+	Record(ctx context.Context, runId string, studentId int64, kind string, status models.DeliveryStatus, cause error) error
+}
+
+type changeServiceForNotify interface {
+	Remember(ctx context.Context, studentId int64, stream, substream string, lessons []models.Lesson) error
+	Detect(ctx context.Context, now time.Time) ([]models.ScheduleChange, error)
+	RememberFirst(ctx context.Context, studentId int64, stream, substream string, lessons []models.Lesson) error
+	DetectFirstCancel(ctx context.Context, now time.Time) ([]models.FirstLessonCancel, error)
+}
+
+type scheduleMessageServiceForNotify interface {
+	Track(ctx context.Context, message models.ScheduleMessage, week bool) error
+	Outdated(ctx context.Context, now time.Time) ([]models.ScheduleMessage, error)
+}
+
+type subscriptionServiceForNotify interface {
+	Notified(ctx context.Context, student models.Student) ([]models.Subscription, error)
+	Title(subscription models.Subscription) string
+}
+
+type teacherServiceForNotify interface {
+	WeekLessons(teacher string) ([]models.Lesson, error)
+	DateLessons(teacher string, date time.Time) ([]models.Lesson, error)
+	LessonsToString(lessons []models.Lesson) string
 }
 
 type notify struct {
@@ -38,14 +141,28 @@ type notify struct {
 	studentService        studentServiceForNotify
 	scheduleService       scheduleServiceForNotify
 	notifySettingsSerivce notifySettingsService
+	subscriptionService   subscriptionServiceForNotify
+	teacherService        teacherServiceForNotify
+	reminderService       reminderServiceForNotify
+	outboxService         outboxServiceForNotify
+	changeService         changeServiceForNotify
+	messageService        scheduleMessageServiceForNotify
+	loc                   *time.Location
 }
 
-func NewNotify(bot *telebot.Bot, studentService studentServiceForNotify, scheduleService scheduleService, notifySettingsService notifySettingsService) *notify {
+func NewNotify(bot *telebot.Bot, studentService studentServiceForNotify, scheduleService scheduleServiceForNotify, notifySettingsService notifySettingsService, subscriptionService subscriptionServiceForNotify, teacherService teacherServiceForNotify, reminderService reminderServiceForNotify, outboxService outboxServiceForNotify, changeService changeServiceForNotify, messageService scheduleMessageServiceForNotify, loc *time.Location) *notify {
 	return &notify{
 		bot:                   bot,
 		studentService:        studentService,
 		scheduleService:       scheduleService,
 		notifySettingsSerivce: notifySettingsService,
+		subscriptionService:   subscriptionService,
+		teacherService:        teacherService,
+		reminderService:       reminderService,
+		outboxService:         outboxService,
+		changeService:         changeService,
+		messageService:        messageService,
+		loc:                   loc,
 	}
 }
 
@@ -101,6 +218,129 @@ func (n *notify) Change() telebot.HandlerFunc {
 		return err
 	})
 
+	n.bot.Handle("\f"+actionNotifyPicker, func(ctx telebot.Context) error {
+		return n.editPicker(ctx, models.NotifyKind(ctx.Callback().Data))
+	})
+
+	n.bot.Handle("\f"+actionNotifyShift, func(ctx telebot.Context) error {
+		args := ctx.Args()
+		if len(args) != 2 {
+			return models.ErrNotifyKindUnknown
+		}
+
+		delta, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+
+		kind := models.NotifyKind(args[0])
+		if err := n.notifySettingsSerivce.ShiftTime(context.Background(), ctx.Sender().ID, kind, delta); err != nil {
+			return err
+		}
+
+		return n.editPicker(ctx, kind)
+	})
+
+	n.bot.Handle("\f"+actionNotifyDay, func(ctx telebot.Context) error {
+		args := ctx.Args()
+		if len(args) != 2 {
+			return models.ErrNotifyKindUnknown
+		}
+
+		weekday, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+
+		kind := models.NotifyKind(args[0])
+		if err := n.notifySettingsSerivce.ToggleDay(context.Background(), ctx.Sender().ID, kind, time.Weekday(weekday)); err != nil {
+			return err
+		}
+
+		return n.editPicker(ctx, kind)
+	})
+
+	n.bot.Handle("\f"+actionToggleReminder, func(ctx telebot.Context) error {
+		err := n.notifySettingsSerivce.ToggleReminder(context.Background(), ctx.Sender().ID)
+		if err != nil {
+			return err
+		}
+
+		return n.editSettings(ctx)
+	})
+
+	n.bot.Handle("\f"+actionReminderLead, func(ctx telebot.Context) error {
+		settings, err := n.notifySettingsSerivce.FindByStudentID(context.Background(), ctx.Sender().ID)
+		if err != nil {
+			return err
+		}
+
+		lead := reminderLeads[0]
+		for _, l := range reminderLeads {
+			if l > settings.ReminderLead {
+				lead = l
+				break
+			}
+		}
+
+		if err := n.notifySettingsSerivce.UpdateReminderLead(context.Background(), ctx.Sender().ID, lead); err != nil {
+			return err
+		}
+
+		return n.editSettings(ctx)
+	})
+
+	n.bot.Handle("\f"+actionPauseCalendar, func(ctx telebot.Context) error {
+		month, err := time.ParseInLocation("2006-01", ctx.Callback().Data, n.loc)
+		if err != nil {
+			return err
+		}
+
+		_, err = n.bot.Edit(ctx.Callback().Message, "Выбери дату, с которой уведомления снова начнут приходить:", n.buildCalendarMarkup(month))
+		return err
+	})
+
+	n.bot.Handle("\f"+actionPause, func(ctx telebot.Context) error {
+		until, err := time.ParseInLocation(time.DateOnly, ctx.Callback().Data, n.loc)
+		if err != nil {
+			return err
+		}
+
+		if !until.After(time.Now().In(n.loc)) {
+			return ctx.Respond(&telebot.CallbackResponse{Text: "Выбери дату в будущем"})
+		}
+
+		if err := n.notifySettingsSerivce.Pause(context.Background(), ctx.Sender().ID, until); err != nil {
+			return err
+		}
+
+		return n.editSettings(ctx)
+	})
+
+	n.bot.Handle("\f"+actionResume, func(ctx telebot.Context) error {
+		if err := n.notifySettingsSerivce.Resume(context.Background(), ctx.Sender().ID); err != nil {
+			return err
+		}
+
+		return n.editSettings(ctx)
+	})
+
+	n.bot.Handle("\f"+actionNoop, func(ctx telebot.Context) error {
+		return ctx.Respond()
+	})
+
+	n.bot.Handle("\f"+actionNotifyBack, func(ctx telebot.Context) error {
+		settings, err := n.notifySettingsSerivce.FindByStudentID(context.Background(), ctx.Sender().ID)
+		if err != nil {
+			return err
+		}
+
+		markup := n.buildMarkup(settings)
+
+		_, err = n.bot.Edit(ctx.Callback().Message, "Изменение настроек уведомлений:", markup)
+		return err
+	})
+
 	return func(ctx telebot.Context) error {
 		settings, err := n.notifySettingsSerivce.FindByStudentID(context.Background(), ctx.Sender().ID)
 		if err != nil {
@@ -113,15 +353,183 @@ func (n *notify) Change() telebot.HandlerFunc {
 	}
 }
 
+// editSettings replans reminders of the student and shows updated settings.
+func (n *notify) editSettings(ctx telebot.Context) error {
+	err := n.reminderService.RebuildStudent(context.Background(), ctx.Sender().ID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	settings, err := n.notifySettingsSerivce.FindByStudentID(context.Background(), ctx.Sender().ID)
+	if err != nil {
+		return err
+	}
+
+	markup := n.buildMarkup(settings)
+
+	_, err = n.bot.Edit(ctx.Callback().Message, "Изменение настроек уведомлений:", markup)
+	return err
+}
+
+func (n *notify) editPicker(ctx telebot.Context, kind models.NotifyKind) error {
+	name, ok := notifyKindNames[kind]
+	if !ok {
+		return models.ErrNotifyKindUnknown
+	}
+
+	settings, err := n.notifySettingsSerivce.FindByStudentID(context.Background(), ctx.Sender().ID)
+	if err != nil {
+		return err
+	}
+
+	minute, days := settings.Schedule(kind)
+	msg := fmt.Sprintf("Время %s уведомлений: <b>%s</b>\nДни: <b>%s</b>", name, formatMinute(minute), formatWeekdays(days))
+
+	_, err = n.bot.Edit(ctx.Callback().Message, msg, n.buildPickerMarkup(kind, days))
+	return err
+}
+
+func (n *notify) buildPickerMarkup(kind models.NotifyKind, days models.Weekdays) *telebot.ReplyMarkup {
+	markup := n.bot.NewMarkup()
+
+	weekdays := make(telebot.Row, 0, len(shortWeekdays))
+	for _, wd := range shortWeekdays {
+		text := wd.Name
+		if days.Has(wd.Weekday) {
+			text = "✅ " + text
+		}
+
+		weekdays = append(weekdays, markup.Data(text, actionNotifyDay, string(kind), strconv.Itoa(int(wd.Weekday))))
+	}
+
+	rows := buildShiftRows(markup, actionNotifyShift, kind)
+	rows = append(rows, weekdays[:4], weekdays[4:], markup.Row(markup.Data("⬅️ Назад", actionNotifyBack)))
+	markup.Inline(rows...)
+
+	return markup
+}
+
+// buildShiftRows returns buttons that move the time of the notification kind. The callback of the action
+// gets the kind and the delta in minutes as arguments.
+func buildShiftRows(markup *telebot.ReplyMarkup, action string, kind models.NotifyKind) []telebot.Row {
+	shift := func(text string, delta int) telebot.Btn {
+		return markup.Data(text, action, string(kind), strconv.Itoa(delta))
+	}
+
+	return []telebot.Row{
+		markup.Row(shift("−1 ч", -60), shift("+1 ч", 60)),
+		markup.Row(shift("−15 мин", -15), shift("−5 мин", -5), shift("+5 мин", 5), shift("+15 мин", 15)),
+	}
+}
+
+// toggleText marks the text of a toggle button with its state.
+func toggleText(text string, state bool) string {
+	if state {
+		return "✅ " + text
+	}
+
+	return "❌ " + text
+}
+
+// buildCalendarMarkup renders days of the month. Only dates from tomorrow to pauseMonths ahead are selectable.
+func (n *notify) buildCalendarMarkup(month time.Time) *telebot.ReplyMarkup {
+	markup := n.bot.NewMarkup()
+	noop := markup.Data(" ", actionNoop)
+
+	now := time.Now().In(n.loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, n.loc)
+	first := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, n.loc)
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, n.loc)
+	last := current.AddDate(0, pauseMonths, 0)
+
+	rows := make([]telebot.Row, 0, 10)
+
+	presets := make(telebot.Row, 0, len(pausePresets))
+	for _, preset := range pausePresets {
+		date := today.AddDate(0, preset.Months, preset.Days)
+		presets = append(presets, markup.Data(preset.Text, actionPause, date.Format(time.DateOnly)))
+	}
+	rows = append(rows, presets)
+
+	prev, next := noop, noop
+	if first.After(current) {
+		prev = markup.Data("◀️", actionPauseCalendar, first.AddDate(0, -1, 0).Format("2006-01"))
+	}
+	if first.Before(last) {
+		next = markup.Data("▶️", actionPauseCalendar, first.AddDate(0, 1, 0).Format("2006-01"))
+	}
+	title := markup.Data(fmt.Sprintf("%s %d", monthNames[first.Month()-1], first.Year()), actionNoop)
+	rows = append(rows, markup.Row(prev, title, next))
+
+	header := make(telebot.Row, 0, len(shortWeekdays))
+	for _, wd := range shortWeekdays {
+		header = append(header, markup.Data(wd.Name, actionNoop))
+	}
+	rows = append(rows, header)
+
+	// Monday is the first column
+	offset := (int(first.Weekday()) + 6) % 7
+	week := make(telebot.Row, 0, 7)
+	for range offset {
+		week = append(week, noop)
+	}
+
+	for day := first; day.Month() == first.Month(); day = day.AddDate(0, 0, 1) {
+		if day.After(today) && day.Before(last.AddDate(0, 1, 0)) {
+			week = append(week, markup.Data(strconv.Itoa(day.Day()), actionPause, day.Format(time.DateOnly)))
+		} else {
+			week = append(week, noop)
+		}
+
+		if len(week) == 7 {
+			rows = append(rows, week)
+			week = make(telebot.Row, 0, 7)
+		}
+	}
+
+	if len(week) > 0 {
+		for len(week) < 7 {
+			week = append(week, noop)
+		}
+		rows = append(rows, week)
+	}
+
+	rows = append(rows, markup.Row(markup.Data("⬅️ Назад", actionNotifyBack)))
+
+	markup.Inline(rows...)
+
+	return markup
+}
+
+func formatMinute(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
+
+func formatWeekdays(days models.Weekdays) string {
+	names := make([]string, 0, len(shortWeekdays))
+	for _, wd := range shortWeekdays {
+		if days.Has(wd.Weekday) {
+			names = append(names, wd.Name)
+		}
+	}
+
+	if len(names) == 0 {
+		return "не выбраны"
+	}
+
+	return strings.Join(names, ", ")
+}
+
 func (n *notify) buildMarkup(settings models.NotifySettings) *telebot.ReplyMarkup {
 	state := [...]struct {
 		Text   string
 		Action string
 		State  bool
+		Kind   models.NotifyKind
 	}{
-		{Text: "утренние уведомления", Action: actionToggleMorning, State: settings.Morning},
-		{Text: "вечерние уведомления", Action: actionToggleEvening, State: settings.Evening},
-		{Text: "недельные уведомления", Action: actionToggleWeek, State: settings.Week},
+		{Text: "утренние уведомления", Action: actionToggleMorning, State: settings.Morning, Kind: models.NotifyMorning},
+		{Text: "вечерние уведомления", Action: actionToggleEvening, State: settings.Evening, Kind: models.NotifyEvening},
+		{Text: "недельные уведомления", Action: actionToggleWeek, State: settings.Week, Kind: models.NotifyWeek},
 	}
 
 	markup := n.bot.NewMarkup()
@@ -138,7 +546,24 @@ func (n *notify) buildMarkup(settings models.NotifySettings) *telebot.ReplyMarku
 		text += s.Text
 
 		b := markup.Data(text, s.Action)
-		btns = append(btns, markup.Row(b))
+		minute, _ := settings.Schedule(s.Kind)
+		picker := markup.Data("🕒 "+formatMinute(minute), actionNotifyPicker, string(s.Kind))
+		btns = append(btns, markup.Row(b, picker))
+	}
+
+	reminderText := "Включить напоминания перед парами"
+	if settings.Reminder {
+		reminderText = "Выключить напоминания перед парами"
+	}
+	btns = append(btns, markup.Row(
+		markup.Data(reminderText, actionToggleReminder),
+		markup.Data(fmt.Sprintf("⏱ за %d мин", settings.ReminderLead), actionReminderLead),
+	))
+
+	if settings.PausedUntil != nil {
+		btns = append(btns, markup.Row(markup.Data("▶️ Возобновить (пауза до "+settings.PausedUntil.Format("02.01.2006")+")", actionResume)))
+	} else {
+		btns = append(btns, markup.Row(markup.Data("⏸ Приостановить до даты", actionPauseCalendar, time.Now().In(n.loc).Format("2006-01"))))
 	}
 
 	markup.Inline(btns...)
@@ -146,129 +571,315 @@ func (n *notify) buildMarkup(settings models.NotifySettings) *telebot.ReplyMarku
 	return markup
 }
 
-func (n *notify) Morning() {
-	n.studentService.ForEach(func(student models.Student) error {
-		defer time.Sleep(300 * time.Millisecond)
-		if err := n.validate(student); err != nil {
-			return err
+// Dispatch queues notifications scheduled at the minute of at.
+// Outcomes are recorded in the delivery log under the daily run of every kind.
+func (n *notify) Dispatch(at time.Time) {
+	n.resume(at)
+
+	jobs := [...]struct {
+		Kind models.NotifyKind
+		Send func(runId string, at time.Time, student models.Student) error
+	}{
+		{Kind: models.NotifyMorning, Send: n.morning},
+		{Kind: models.NotifyEvening, Send: n.evening},
+		{Kind: models.NotifyWeek, Send: n.week},
+	}
+
+	for _, job := range jobs {
+		runId := models.RunID(string(job.Kind), at)
+
+		n.studentService.ForEachDue(job.Kind, at, func(student models.Student) error {
+			return job.Send(runId, at, student)
+		})
+
+		n.studentService.ForEachSkipped(job.Kind, at, func(student models.Student) error {
+			return n.outboxService.Record(context.Background(), runId, student.ID, string(job.Kind), models.DeliverySkipped, nil)
+		})
+	}
+
+	runId := models.RunID(models.OutboxReminder, at)
+	for _, reminder := range n.reminderService.Due(at) {
+		if err := n.remind(runId, reminder); err != nil {
+			log.Println(err.Error(), reminder.StudentID)
 		}
+	}
+}
 
-		settings, err := n.notifySettingsSerivce.FindByStudentID(context.Background(), student.ID)
-		if err != nil {
-			return err
+// Alert queues urgent messages about room and time changes of today and tomorrow lessons
+// that were already sent to students.
+func (n *notify) Alert(now time.Time) error {
+	changes, err := n.changeService.Detect(context.Background(), now)
+	if err != nil {
+		return err
+	}
+
+	runId := models.RunID(models.OutboxChange, now)
+	for _, change := range changes {
+		msg := n.changeToString(change, now)
+		if _, err := n.outboxService.Enqueue(context.Background(), runId, change.StudentID, models.OutboxChange, msg); err != nil {
+			log.Println(err.Error(), change.StudentID)
 		}
+	}
+
+	return nil
+}
+
+func (n *notify) changeToString(change models.ScheduleChange, now time.Time) string {
+	day := "завтра"
+	if change.Date.Equal(models.Date(now)) {
+		day = "сегодня"
+	}
 
-		if !settings.Morning {
-			return nil
+	title := n.subscriptionService.Title(models.Subscription{Stream: change.Stream, Substream: change.Substream})
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "⚠️ <b>Изменения в расписании на %s</b>\n👥 %s\n", day, title)
+
+	for _, c := range change.Changes {
+		fmt.Fprintf(&sb, "\n<b>%s</b> (%s)\n", c.After.Name, c.After.Type)
+
+		if c.Before.Cabinet != c.After.Cabinet {
+			fmt.Fprintf(&sb, "Кабинет: %s → <b>%s</b>\n", c.Before.Cabinet, c.After.Cabinet)
 		}
 
-		substream := ""
-		if student.Substream != nil {
-			substream = *student.Substream
+		if !c.Before.DateStart.Equal(c.After.DateStart) || !c.Before.DateEnd.Equal(c.After.DateEnd) {
+			fmt.Fprintf(&sb, "Время: %s-%s → <b>%s-%s</b>\n", c.Before.DateStart.Format("15:04"), c.Before.DateEnd.Format("15:04"), c.After.DateStart.Format("15:04"), c.After.DateEnd.Format("15:04"))
 		}
+	}
 
-		_, err = n.scheduleService.CurrentWeekLessons(*student.Stream, substream)
-		if err != nil {
-			if errors.Is(err, models.ErrLessonsAreEmpty) {
-				return nil
-			}
+	return sb.String()
+}
 
-			return err
-		}
+// AlertFirstCancel queues messages to students whose first lesson from the morning notification
+// is cancelled, so they can come later.
+func (n *notify) AlertFirstCancel(now time.Time) error {
+	cancels, err := n.changeService.DetectFirstCancel(context.Background(), now)
+	if err != nil {
+		return err
+	}
 
-		lessons, err := n.scheduleService.TodayLessons(*student.Stream, substream)
-		msg := "<b>Присылаю пары на сегодня. Расписание может измениться в любой момент, не забывай обновлять его!</b>\n\n" + n.scheduleService.LessonsToString(lessons)
-		if err != nil {
-			if errors.Is(err, models.ErrLessonsAreEmpty) {
-				return nil
-			}
+	runId := models.RunID(models.OutboxFirstCancel, now)
+	for _, cancel := range cancels {
+		title := n.subscriptionService.Title(models.Subscription{Stream: cancel.Stream, Substream: cancel.Substream})
 
-			return err
+		msg := fmt.Sprintf("😴 <b>Первая пара отменена</b>\n👥 %s\n\n%s (%s) в %s отменена.\n", title, cancel.Before.Name, cancel.Before.Type, cancel.Before.DateStart.Format("15:04"))
+		if cancel.After != nil {
+			msg += fmt.Sprintf("Занятия сегодня начинаются в <b>%s</b>: %s (%s), кабинет %s", cancel.After.DateStart.Format("15:04"), cancel.After.Name, cancel.After.Type, cancel.After.Cabinet)
+		} else {
+			msg += "Сегодня пар больше нет"
 		}
 
-		_, err = n.bot.Send(&telebot.User{ID: student.ID}, msg)
-		return err
-	})
+		if _, err := n.outboxService.Enqueue(context.Background(), runId, cancel.StudentID, models.OutboxFirstCancel, msg); err != nil {
+			log.Println(err.Error(), cancel.StudentID)
+		}
+	}
+
+	return nil
 }
 
-func (n *notify) Evening() {
-	n.studentService.ForEach(func(student models.Student) error {
-		defer time.Sleep(300 * time.Millisecond)
-		if err := n.validate(student); err != nil {
-			return err
-		}
+// AlertMissingStreams asks students whose group has disappeared from the portal to select it again.
+// Every student is asked once, notifications are not sent until the group is selected.
+func (n *notify) AlertMissingStreams(now time.Time, streams []models.Stream) error {
+	ids, err := n.studentService.MarkMissingStreams(context.Background(), streams)
+	if err != nil {
+		return err
+	}
 
-		settings, err := n.notifySettingsSerivce.FindByStudentID(context.Background(), student.ID)
-		if err != nil {
-			return err
+	runId := models.RunID(models.OutboxStreamGone, now)
+	for _, id := range ids {
+		if _, err := n.outboxService.Enqueue(context.Background(), runId, id, models.OutboxStreamGone, streamGoneMessage); err != nil {
+			log.Println(err.Error(), id)
 		}
-		if !settings.Evening {
-			return nil
+	}
+
+	return nil
+}
+
+// Refresh queues edits of sent schedule messages whose lessons changed since sending.
+func (n *notify) Refresh(now time.Time) error {
+	messages, err := n.messageService.Outdated(context.Background(), now)
+	if err != nil {
+		return err
+	}
+
+	runId := models.RunID(models.OutboxEdit, now)
+	for _, message := range messages {
+		msg := message.Header + n.scheduleService.LessonsToString(message.Lessons) + "🔄 <i>обновлено в " + now.Format("15:04") + "</i>"
+		if _, err := n.outboxService.EnqueueEdit(context.Background(), runId, message.ChatID, *message.MessageID, msg); err != nil {
+			log.Println(err.Error(), message.ChatID)
 		}
+	}
 
-		substream := ""
-		if student.Substream != nil {
-			substream = *student.Substream
+	return nil
+}
+
+// resume ends expired pauses and tells students notifications are back.
+// resume resumes expired pauses and tells students about it. Before pauseResumeHour only students
+// with a notification due now or earlier are resumed, so they are told before their first notification.
+func (n *notify) resume(at time.Time) {
+	resumeFn := n.notifySettingsSerivce.ResumeExpired
+	if at.Hour() < pauseResumeHour {
+		resumeFn = n.notifySettingsSerivce.ResumeDue
+	}
+
+	ids, err := resumeFn(context.Background(), at)
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	runId := models.RunID(models.OutboxResume, at)
+	for _, id := range ids {
+		if err := n.reminderService.RebuildStudent(context.Background(), id, at); err != nil {
+			log.Println(err.Error(), id)
 		}
 
-		lessons, err := n.scheduleService.TomorrowLessons(*student.Stream, substream)
-		if err != nil {
-			if errors.Is(err, models.ErrLessonsAreEmpty) {
-				return nil
-			}
-			return err
+		if _, err := n.outboxService.Enqueue(context.Background(), runId, id, models.OutboxResume, "▶️ Пауза закончилась, уведомления снова включены. Настроить их можно командой /notifysettings"); err != nil {
+			log.Println(err.Error(), id)
 		}
+	}
+}
+
+func (n *notify) remind(runId string, reminder models.Reminder) error {
+	lesson := reminder.Lesson
+	msg := fmt.Sprintf("⏰ <b>Через %d минут: %s, каб. %s</b>\n%s (%s), %s-%s", reminder.Lead, lesson.Name, lesson.Cabinet, lesson.Type, lesson.Teacher, lesson.DateStart.Format("15:04"), lesson.DateEnd.Format("15:04"))
+	if reminder.Title != "" {
+		msg += "\n👥 " + reminder.Title
+	}
+
+	// The key is kept in the log, so a reminder that is due again after a restart is not sent twice
+	key := fmt.Sprintf("%s/%d", lesson.ID, lesson.DateStart.Unix())
+	_, err := n.outboxService.EnqueueOnce(context.Background(), runId, reminder.StudentID, models.OutboxReminder, key, msg)
+	return err
+}
 
-		msg := "<b>Присылаю пары на завтра. Расписание может измениться в любой момент, не забывай обновлять его!</b>\n\n" + n.scheduleService.LessonsToString(lessons)
+// morning queues lessons of the day of the dispatch slot at, so a slot after midnight gets the new day.
+func (n *notify) morning(runId string, at time.Time, student models.Student) error {
+	if err := n.validate(runId, models.NotifyMorning, student); err != nil {
+		return err
+	}
 
-		_, err = n.bot.Send(&telebot.User{ID: student.ID}, msg)
+	header := "<b>Присылаю пары на сегодня. Расписание может измениться в любой момент, не забывай обновлять его!</b>\n\n"
+	return n.sendDate(runId, student, models.NotifyMorning, header, at)
+}
+
+// evening queues lessons of the day after the dispatch slot at.
+func (n *notify) evening(runId string, at time.Time, student models.Student) error {
+	if err := n.validate(runId, models.NotifyEvening, student); err != nil {
 		return err
+	}
+
+	header := "<b>Присылаю пары на завтра. Расписание может измениться в любой момент, не забывай обновлять его!</b>\n\n"
+	return n.sendDate(runId, student, models.NotifyEvening, header, at.AddDate(0, 0, 1))
+}
+
+func (n *notify) sendDate(runId string, student models.Student, kind models.NotifyKind, header string, date time.Time) error {
+	if student.IsTeacher() {
+		return n.sendTeacher(runId, student, kind, header, func(teacher string) ([]models.Lesson, error) {
+			return n.teacherService.DateLessons(teacher, date)
+		})
+	}
+
+	return n.send(runId, student, kind, header, func(stream, substream string) ([]models.Lesson, error) {
+		return n.scheduleService.DateLessons(stream, substream, date)
 	})
 }
 
-func (n *notify) Week() {
-	n.studentService.ForEach(func(student models.Student) error {
-		defer time.Sleep(300 * time.Millisecond)
-		if err := n.validate(student); err != nil {
-			return err
-		}
+func (n *notify) week(runId string, at time.Time, student models.Student) error {
+	if err := n.validate(runId, models.NotifyWeek, student); err != nil {
+		return err
+	}
 
-		settings, err := n.notifySettingsSerivce.FindByStudentID(context.Background(), student.ID)
+	header := "<b>Пары на следующую неделю. Расписание может измениться в любой момент, не забывай обновлять его!</b>\n\n"
+	if student.IsTeacher() {
+		return n.sendTeacher(runId, student, models.NotifyWeek, header, n.teacherService.WeekLessons)
+	}
+
+	return n.send(runId, student, models.NotifyWeek, header, n.scheduleService.CurrentWeekLessons)
+}
+
+// send queues lessons of every subscription with enabled notifications.
+// Subscriptions without lessons are logged as empty.
+func (n *notify) send(runId string, student models.Student, kind models.NotifyKind, header string, lessonsFn func(stream, substream string) ([]models.Lesson, error)) error {
+	subscriptions, err := n.subscriptionService.Notified(context.Background(), student)
+	if err != nil {
+		return n.record(runId, student, kind, models.DeliveryFailed, err)
+	}
+
+	var errs []error
+	for _, subscription := range subscriptions {
+		lessons, err := lessonsFn(subscription.Stream, subscription.Substream)
 		if err != nil {
-			return err
-		}
-		if !settings.Week {
-			return nil
+			if errors.Is(err, models.ErrLessonsAreEmpty) {
+				errs = append(errs, n.record(runId, student, kind, models.DeliveryEmpty, nil))
+			} else {
+				errs = append(errs, n.record(runId, student, kind, models.DeliveryFailed, err))
+			}
+			continue
 		}
 
-		substream := ""
-		if student.Substream != nil {
-			substream = *student.Substream
+		msgHeader := header
+		if len(subscriptions) > 1 {
+			msgHeader += "👥 <b>" + n.subscriptionService.Title(subscription) + "</b>\n\n"
 		}
+		msg := msgHeader + n.scheduleService.LessonsToString(lessons)
 
-		lessons, err := n.scheduleService.CurrentWeekLessons(*student.Stream, substream)
+		outboxId, err := n.outboxService.Enqueue(context.Background(), runId, student.ID, string(kind), msg)
 		if err != nil {
-			if errors.Is(err, models.ErrLessonsAreEmpty) {
-				return nil
-			}
-			return err
+			errs = append(errs, err)
+			continue
 		}
 
-		msg := "<b>Пары на следующую неделю. Расписание может измениться в любой момент, не забывай обновлять его!</b>\n\n" + n.scheduleService.LessonsToString(lessons)
+		errs = append(errs, n.changeService.Remember(context.Background(), student.ID, subscription.Stream, subscription.Substream, lessons))
+		if kind == models.NotifyMorning {
+			errs = append(errs, n.changeService.RememberFirst(context.Background(), student.ID, subscription.Stream, subscription.Substream, lessons))
+		}
+		errs = append(errs, n.messageService.Track(context.Background(), models.ScheduleMessage{
+			ChatID:    student.ID,
+			OutboxID:  &outboxId,
+			Stream:    subscription.Stream,
+			Substream: subscription.Substream,
+			Header:    msgHeader,
+			Lessons:   lessons,
+		}, kind == models.NotifyWeek))
+	}
 
-		_, err = n.bot.Send(&telebot.User{ID: student.ID}, msg)
-		return err
-	})
+	return errors.Join(errs...)
 }
 
-func (*notify) validate(student models.Student) error {
-	if student.ID == 0 {
-		return models.ErrStudentNotFound
+// sendTeacher queues lessons of all groups the teacher has.
+func (n *notify) sendTeacher(runId string, student models.Student, kind models.NotifyKind, header string, lessonsFn func(teacher string) ([]models.Lesson, error)) error {
+	lessons, err := lessonsFn(*student.Teacher)
+	if err != nil {
+		if errors.Is(err, models.ErrLessonsAreEmpty) {
+			return n.record(runId, student, kind, models.DeliveryEmpty, nil)
+		}
+		return n.record(runId, student, kind, models.DeliveryFailed, err)
 	}
 
-	if student.Stream == nil {
-		return models.ErrStudentStreamMissed
+	_, err = n.outboxService.Enqueue(context.Background(), runId, student.ID, string(kind), header+n.teacherService.LessonsToString(lessons))
+	return err
+}
+
+// record logs the outcome for the student. The cause is returned along with logging errors.
+func (n *notify) record(runId string, student models.Student, kind models.NotifyKind, status models.DeliveryStatus, cause error) error {
+	err := n.outboxService.Record(context.Background(), runId, student.ID, string(kind), status, cause)
+	return errors.Join(cause, err)
+}
+
+// validate checks the student can get notifications. Invalid students are logged as skipped.
+func (n *notify) validate(runId string, kind models.NotifyKind, student models.Student) error {
+	var err error
+	switch {
+	case student.ID == 0:
+		err = models.ErrStudentNotFound
+	case student.IsTeacher():
+		return nil
+	case student.Stream == nil:
+		err = models.ErrStudentStreamMissed
+	default:
+		return nil
 	}
 
-	return nil
+	return n.record(runId, student, kind, models.DeliverySkipped, err)
 }
@@ -0,0 +1,300 @@
+package tg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pgtk-schedule/internal/models"
+	"strconv"
+
+	"gopkg.in/telebot.v4"
+)
+
+const (
+	actionOnboardingStream    = "onboardingStream"
+	actionOnboardingSubstream = "onboardingSubstream"
+	actionOnboardingToggle    = "onboardingToggle"
+	actionOnboardingShift     = "onboardingShift"
+	actionOnboardingDone      = "onboardingDone"
+)
+
+type onboardingStudentService interface {
+	FindByID(ctx context.Context, id int64) (models.Student, error)
+	UpdateOnboardingStep(ctx context.Context, id int64, step string) error
+}
+
+type onboardingSubscriptionService interface {
+	Replace(ctx context.Context, studentId int64, stream, substream string) error
+}
+
+type onboardingNotifySettingsService interface {
+	FindByStudentID(ctx context.Context, studentId int64) (models.NotifySettings, error)
+	ToggleMorning(ctx context.Context, studentId int64) error
+	ToggleEvening(ctx context.Context, studentId int64) error
+	ToggleWeek(ctx context.Context, studentId int64) error
+	ShiftTime(ctx context.Context, studentId int64, kind models.NotifyKind, delta int) error
+}
+
+type onboardingScheduleService interface {
+	TodayLessons(stream, substream string) ([]models.Lesson, error)
+	TomorrowLessons(stream, substream string) ([]models.Lesson, error)
+	LessonsToString(lessons []models.Lesson) string
+}
+
+// onboarding guides a new user through choosing the group and notifications after /start.
+// The step is saved, so /start resumes the onboarding where the user has left it.
+type onboarding struct {
+	bot                   *telebot.Bot
+	studentService        onboardingStudentService
+	subscriptionService   onboardingSubscriptionService
+	notifySettingsService onboardingNotifySettingsService
+	scheduleService       onboardingScheduleService
+	portal                portal
+	picker                *picker
+	menu                  *telebot.ReplyMarkup
+}
+
+func NewOnboarding(bot *telebot.Bot, studentService onboardingStudentService, subscriptionService onboardingSubscriptionService, notifySettingsService onboardingNotifySettingsService, scheduleService onboardingScheduleService, portal portal, picker *picker, menu *telebot.ReplyMarkup) *onboarding {
+	return &onboarding{
+		bot:                   bot,
+		studentService:        studentService,
+		subscriptionService:   subscriptionService,
+		notifySettingsService: notifySettingsService,
+		scheduleService:       scheduleService,
+		portal:                portal,
+		picker:                picker,
+		menu:                  menu,
+	}
+}
+
+// Start resumes the onboarding of new users, set up users are greeted with greet.
+func (o *onboarding) Start(greet telebot.HandlerFunc) telebot.HandlerFunc {
+	o.bot.Handle("\f"+actionOnboardingStream, func(ctx telebot.Context) error {
+		o.picker.Forget(ctx.Chat().ID)
+
+		var found models.Stream
+		for _, stream := range o.portal.Streams() {
+			if stream.ID == ctx.Callback().Data {
+				found = stream
+				break
+			}
+		}
+
+		if found.ID == "" {
+			return models.ErrStreamIsUnknown
+		}
+
+		if len(found.Substreams) > 1 {
+			markup := o.bot.NewMarkup()
+
+			btns := make([]telebot.Row, 0, len(found.Substreams))
+			for _, substream := range found.Substreams {
+				btns = append(btns, markup.Row(markup.Data(substream, actionOnboardingSubstream, found.ID, substream)))
+			}
+			markup.Inline(btns...)
+
+			_, err := o.bot.Edit(ctx.Callback().Message, fmt.Sprintf("<b>Шаг 1 из 3.</b> Группа %s. Выберите подгруппу:", found.Name), markup)
+			return err
+		}
+
+		substream := ""
+		if len(found.Substreams) == 1 {
+			substream = found.Substreams[0]
+		}
+
+		return o.follow(ctx, found.ID, substream)
+	})
+
+	o.bot.Handle("\f"+actionOnboardingSubstream, func(ctx telebot.Context) error {
+		args := ctx.Args()
+		if len(args) != 2 {
+			return ErrSubstreamIsInvalid
+		}
+
+		return o.follow(ctx, args[0], args[1])
+	})
+
+	toggles := map[models.NotifyKind]func(ctx context.Context, studentId int64) error{
+		models.NotifyMorning: o.notifySettingsService.ToggleMorning,
+		models.NotifyEvening: o.notifySettingsService.ToggleEvening,
+		models.NotifyWeek:    o.notifySettingsService.ToggleWeek,
+	}
+
+	o.bot.Handle("\f"+actionOnboardingToggle, func(ctx telebot.Context) error {
+		toggle, ok := toggles[models.NotifyKind(ctx.Callback().Data)]
+		if !ok {
+			return models.ErrNotifyKindUnknown
+		}
+
+		if err := toggle(context.Background(), ctx.Sender().ID); err != nil {
+			return err
+		}
+
+		return o.editNotify(ctx)
+	})
+
+	o.bot.Handle("\f"+actionOnboardingShift, func(ctx telebot.Context) error {
+		args := ctx.Args()
+		if len(args) != 2 {
+			return models.ErrNotifyKindUnknown
+		}
+
+		delta, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+
+		if err := o.notifySettingsService.ShiftTime(context.Background(), ctx.Sender().ID, models.NotifyKind(args[0]), delta); err != nil {
+			return err
+		}
+
+		return o.editNotify(ctx)
+	})
+
+	o.bot.Handle("\f"+actionOnboardingDone, func(ctx telebot.Context) error {
+		return o.finish(ctx)
+	})
+
+	return func(ctx telebot.Context) error {
+		// Buttons act on the user who taps them, so the onboarding is shown in private chats only
+		if isGroup(ctx.Chat()) {
+			return greet(ctx)
+		}
+
+		student, ok := ctx.Get(KeyStudent).(models.Student)
+		if !ok || !student.NeedsOnboarding() {
+			return greet(ctx)
+		}
+
+		step := models.OnboardingStream
+		if student.OnboardingStep != nil {
+			step = *student.OnboardingStep
+		}
+
+		if step == models.OnboardingNotify {
+			text, markup, err := o.renderNotify(student.ID)
+			if err != nil {
+				return err
+			}
+
+			return ctx.Reply(text, markup)
+		}
+
+		if err := o.studentService.UpdateOnboardingStep(context.Background(), student.ID, models.OnboardingStream); err != nil {
+			return err
+		}
+
+		return o.picker.Show(ctx, actionOnboardingStream, "👋 Привет! Настроим бота за три шага.\n\n<b>Шаг 1 из 3.</b> Выберите свою группу. Если вы преподаватель, используйте команду /iamteacher", "")
+	}
+}
+
+func (o *onboarding) follow(ctx telebot.Context, stream, substream string) error {
+	id := ctx.Sender().ID
+
+	if err := o.subscriptionService.Replace(context.Background(), id, stream, substream); err != nil {
+		return err
+	}
+
+	if err := o.studentService.UpdateOnboardingStep(context.Background(), id, models.OnboardingNotify); err != nil {
+		return err
+	}
+
+	return o.editNotify(ctx)
+}
+
+func (o *onboarding) editNotify(ctx telebot.Context) error {
+	text, markup, err := o.renderNotify(ctx.Sender().ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = o.bot.Edit(ctx.Callback().Message, text, markup)
+	return err
+}
+
+func (o *onboarding) renderNotify(studentId int64) (string, *telebot.ReplyMarkup, error) {
+	settings, err := o.notifySettingsService.FindByStudentID(context.Background(), studentId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	markup := o.bot.NewMarkup()
+
+	toggle := func(text string, state bool, kind models.NotifyKind) telebot.Row {
+		return markup.Row(markup.Data(toggleText(text, state), actionOnboardingToggle, string(kind)))
+	}
+
+	rows := []telebot.Row{toggle("Утром в "+formatMinute(settings.MorningTime)+" — пары на сегодня", settings.Morning, models.NotifyMorning)}
+	if settings.Morning {
+		rows = append(rows, buildShiftRows(markup, actionOnboardingShift, models.NotifyMorning)...)
+	}
+
+	rows = append(rows, toggle("Вечером в "+formatMinute(settings.EveningTime)+" — пары на завтра", settings.Evening, models.NotifyEvening))
+	if settings.Evening {
+		rows = append(rows, buildShiftRows(markup, actionOnboardingShift, models.NotifyEvening)...)
+	}
+
+	rows = append(rows,
+		toggle("Пары на следующую неделю", settings.Week, models.NotifyWeek),
+		markup.Row(markup.Data("Далее ➡️", actionOnboardingDone)),
+	)
+	markup.Inline(rows...)
+
+	text := "<b>Шаг 2 из 3.</b> Когда присылать расписание? Дни и другие настройки можно изменить позже командой /notifysettings"
+	return text, markup, nil
+}
+
+// finish ends the onboarding with a sample of the schedule and the main keyboard.
+func (o *onboarding) finish(ctx telebot.Context) error {
+	id := ctx.Sender().ID
+	if err := o.studentService.UpdateOnboardingStep(context.Background(), id, ""); err != nil {
+		return err
+	}
+
+	student, err := o.studentService.FindByID(context.Background(), id)
+	if err != nil {
+		return err
+	}
+
+	if student.Stream == nil {
+		return models.ErrStudentStreamMissed
+	}
+
+	substream := ""
+	if student.Substream != nil {
+		substream = *student.Substream
+	}
+
+	text := "<b>Шаг 3 из 3. Готово!</b> "
+	sample, err := o.sample(*student.Stream, substream)
+	if err != nil {
+		return err
+	}
+
+	if _, err := o.bot.Edit(ctx.Callback().Message, text+sample); err != nil {
+		return err
+	}
+
+	return ctx.Send("Расписание можно получить кнопками ниже, группы — настроить командой /groups", o.menu)
+}
+
+// sample renders lessons of today or, when there are none, of tomorrow.
+func (o *onboarding) sample(stream, substream string) (string, error) {
+	lessons, err := o.scheduleService.TodayLessons(stream, substream)
+	if err == nil {
+		return "Так выглядит расписание на сегодня:\n\n" + o.scheduleService.LessonsToString(lessons), nil
+	}
+	if !errors.Is(err, models.ErrLessonsAreEmpty) {
+		return "", err
+	}
+
+	lessons, err = o.scheduleService.TomorrowLessons(stream, substream)
+	if err == nil {
+		return "Сегодня пар нет, так выглядит расписание на завтра:\n\n" + o.scheduleService.LessonsToString(lessons), nil
+	}
+	if !errors.Is(err, models.ErrLessonsAreEmpty) {
+		return "", err
+	}
+
+	return "Сегодня и завтра пар нет. Расписание придёт в выбранное время, а также по командам /today, /tomorrow и /week", nil
+}
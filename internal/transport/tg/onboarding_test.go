@@ -0,0 +1,91 @@
+package tg
+
+import (
+	"errors"
+	"pgtk-schedule/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeOnboardingSchedule struct {
+	today    error
+	tomorrow error
+}
+
+func (f fakeOnboardingSchedule) TodayLessons(stream, substream string) ([]models.Lesson, error) {
+	if f.today != nil {
+		return nil, f.today
+	}
+	return []models.Lesson{{Name: "today"}}, nil
+}
+
+func (f fakeOnboardingSchedule) TomorrowLessons(stream, substream string) ([]models.Lesson, error) {
+	if f.tomorrow != nil {
+		return nil, f.tomorrow
+	}
+	return []models.Lesson{{Name: "tomorrow"}}, nil
+}
+
+func (f fakeOnboardingSchedule) LessonsToString(lessons []models.Lesson) string {
+	return lessons[0].Name
+}
+
+func TestOnboardingSample(t *testing.T) {
+	failed := errors.New("portal is down")
+
+	tests := []struct {
+		name     string
+		schedule fakeOnboardingSchedule
+		expected string
+		err      error
+	}{
+		{name: "today", schedule: fakeOnboardingSchedule{}, expected: "Так выглядит расписание на сегодня:\n\ntoday"},
+		{name: "tomorrow", schedule: fakeOnboardingSchedule{today: models.ErrLessonsAreEmpty}, expected: "Сегодня пар нет, так выглядит расписание на завтра:\n\ntomorrow"},
+		{name: "no lessons", schedule: fakeOnboardingSchedule{today: models.ErrLessonsAreEmpty, tomorrow: models.ErrLessonsAreEmpty}, expected: "Сегодня и завтра пар нет. Расписание придёт в выбранное время, а также по командам /today, /tomorrow и /week"},
+		{name: "error", schedule: fakeOnboardingSchedule{today: failed}, err: failed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &onboarding{scheduleService: tt.schedule}
+
+			sample, err := o.sample("stream", "")
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, sample)
+		})
+	}
+}
+
+func TestStudentNeedsOnboarding(t *testing.T) {
+	stream := "stream"
+	teacher := "Иванов Иван Иванович"
+	step := models.OnboardingNotify
+	streamStep := models.OnboardingStream
+
+	tests := []struct {
+		name     string
+		student  models.Student
+		expected bool
+	}{
+		{name: "new student", student: models.Student{}, expected: true},
+		{name: "unfinished step", student: models.Student{Stream: &stream, OnboardingStep: &step}, expected: true},
+		{name: "set up student", student: models.Student{Stream: &stream}, expected: false},
+		{name: "teacher", student: models.Student{Role: models.RoleTeacher, Teacher: &teacher}, expected: false},
+		{name: "group step left for /setstream", student: models.Student{Stream: &stream, OnboardingStep: &streamStep}, expected: false},
+		{name: "group step left for /iamteacher", student: models.Student{Role: models.RoleTeacher, Teacher: &teacher, OnboardingStep: &streamStep}, expected: false},
+		{name: "group step without a group", student: models.Student{OnboardingStep: &streamStep}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.student.NeedsOnboarding())
+		})
+	}
+}
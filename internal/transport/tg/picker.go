@@ -0,0 +1,343 @@
+package tg
+
+import (
+	"fmt"
+	"pgtk-schedule/internal/models"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"gopkg.in/telebot.v4"
+)
+
+const (
+	actionPickerPage     = "pickerPage"
+	actionPickerCategory = "pickerCategory"
+
+	pickerCategoriesPerPage = 12
+	pickerStreamsPerPage    = 10
+	// pickerSearchTTL is how long typed text is treated as a search after the picker is shown.
+	pickerSearchTTL = 10 * time.Minute
+
+	pickerHint = "<i>Можно отправить часть названия группы, например «исп 21»</i>"
+)
+
+// pickerSearch is a picker waiting for a typed query in a private chat.
+type pickerSearch struct {
+	Action string
+	Text   string
+	Until  time.Time
+}
+
+// streamCategory groups streams by specialty and course, e.g. "ИСП-2" for "ИСП-21".
+type streamCategory struct {
+	Key     string
+	Streams []models.Stream
+}
+
+// picker shows streams grouped by categories with pages. A picked stream is sent to the callback
+// of the action with the stream ID as data, so callers keep handling the choice themselves.
+type picker struct {
+	bot     *telebot.Bot
+	portal  portal
+	mu      sync.Mutex
+	pending map[int64]pickerSearch
+}
+
+func NewPicker(bot *telebot.Bot, portal portal) *picker {
+	p := &picker{
+		bot:     bot,
+		portal:  portal,
+		pending: make(map[int64]pickerSearch),
+	}
+
+	bot.Handle("\f"+actionPickerPage, func(ctx telebot.Context) error {
+		args := ctx.Args()
+		if len(args) != 2 {
+			return models.ErrStreamIsUnknown
+		}
+
+		page, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+
+		_, err = p.bot.EditReplyMarkup(ctx.Callback().Message, p.categoriesMarkup(args[0], page))
+		return err
+	})
+
+	bot.Handle("\f"+actionPickerCategory, func(ctx telebot.Context) error {
+		args := ctx.Args()
+		if len(args) != 3 {
+			return models.ErrStreamIsUnknown
+		}
+
+		page, err := strconv.Atoi(args[2])
+		if err != nil {
+			return err
+		}
+
+		_, err = p.bot.EditReplyMarkup(ctx.Callback().Message, p.categoryMarkup(args[0], args[1], page))
+		return err
+	})
+
+	return p
+}
+
+// Show replies with the picker. A non-empty query, e.g. "исп 21", shows only matching streams.
+func (p *picker) Show(ctx telebot.Context, action, text, query string) error {
+	p.remember(ctx, action, text)
+
+	if query = strings.TrimSpace(query); query != "" {
+		return p.search(ctx, action, text, query)
+	}
+
+	return ctx.Reply(withPickerHint(ctx, text), p.categoriesMarkup(action, 0))
+}
+
+// Edit replaces the callback message with the picker.
+func (p *picker) Edit(ctx telebot.Context, action, text string) error {
+	p.remember(ctx, action, text)
+
+	_, err := p.bot.Edit(ctx.Callback().Message, withPickerHint(ctx, text), p.categoriesMarkup(action, 0))
+	return err
+}
+
+// withPickerHint adds the search hint in private chats, typed text is not searched in groups.
+func withPickerHint(ctx telebot.Context, text string) string {
+	if chat := ctx.Chat(); chat == nil || chat.Type != telebot.ChatPrivate {
+		return text
+	}
+
+	return text + "\n\n" + pickerHint
+}
+
+// Search filters streams by text typed in a private chat shortly after the picker was shown.
+// Other text messages are ignored.
+func (p *picker) Search() telebot.HandlerFunc {
+	return func(ctx telebot.Context) error {
+		if ctx.Chat() == nil || ctx.Chat().Type != telebot.ChatPrivate {
+			return nil
+		}
+
+		p.mu.Lock()
+		search, ok := p.pending[ctx.Chat().ID]
+		p.mu.Unlock()
+
+		if !ok || time.Now().After(search.Until) {
+			return nil
+		}
+
+		return p.search(ctx, search.Action, search.Text, ctx.Text())
+	}
+}
+
+// Forget stops treating text in the chat as a search, e.g. after a stream is picked.
+func (p *picker) Forget(chatId int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.pending, chatId)
+}
+
+func (p *picker) search(ctx telebot.Context, action, text, query string) error {
+	streams := matchStreams(p.portal.Streams(), query)
+	if len(streams) == 0 {
+		return ctx.Reply("Группы не найдены, попробуйте написать название иначе", p.categoriesMarkup(action, 0))
+	}
+
+	if len(streams) > pickerStreamsPerPage {
+		text = "Найдено слишком много групп, уточните запрос или выберите из первых:"
+		streams = streams[:pickerStreamsPerPage]
+	}
+
+	markup := p.bot.NewMarkup()
+	rows := p.streamRows(markup, action, streams)
+	rows = append(rows, markup.Row(markup.Data("⬅️ Все группы", actionPickerPage, action, "0")))
+	markup.Inline(rows...)
+
+	return ctx.Reply(text, markup)
+}
+
+func (p *picker) remember(ctx telebot.Context, action, text string) {
+	chat := ctx.Chat()
+	if chat == nil || chat.Type != telebot.ChatPrivate {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pending[chat.ID] = pickerSearch{Action: action, Text: text, Until: time.Now().Add(pickerSearchTTL)}
+
+	for id, search := range p.pending {
+		if time.Now().After(search.Until) {
+			delete(p.pending, id)
+		}
+	}
+}
+
+// categoriesMarkup lists categories of streams. Few streams are listed without categories.
+func (p *picker) categoriesMarkup(action string, page int) *telebot.ReplyMarkup {
+	streams := p.portal.Streams()
+	markup := p.bot.NewMarkup()
+
+	if len(streams) <= pickerStreamsPerPage {
+		markup.Inline(p.streamRows(markup, action, sortedStreams(streams))...)
+		return markup
+	}
+
+	categories := categorizeStreams(streams)
+	pages := (len(categories) + pickerCategoriesPerPage - 1) / pickerCategoriesPerPage
+	page = min(max(page, 0), pages-1)
+
+	categories = categories[page*pickerCategoriesPerPage : min((page+1)*pickerCategoriesPerPage, len(categories))]
+
+	rows := make([]telebot.Row, 0, pickerCategoriesPerPage/3+1)
+	row := make(telebot.Row, 0, 3)
+	for _, category := range categories {
+		// A category of a single stream is picked at once
+		btn := markup.Data(fmt.Sprintf("%s… (%d)", category.Key, len(category.Streams)), actionPickerCategory, action, category.Key, "0")
+		if len(category.Streams) == 1 {
+			btn = markup.Data(category.Streams[0].Name, action, category.Streams[0].ID)
+		}
+
+		row = append(row, btn)
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = make(telebot.Row, 0, 3)
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	if nav := p.navigation(markup, page, pages, func(page int) telebot.Btn {
+		return markup.Data("", actionPickerPage, action, strconv.Itoa(page))
+	}); nav != nil {
+		rows = append(rows, nav)
+	}
+
+	markup.Inline(rows...)
+	return markup
+}
+
+// categoryMarkup lists streams of the category.
+func (p *picker) categoryMarkup(action, key string, page int) *telebot.ReplyMarkup {
+	var streams []models.Stream
+	for _, category := range categorizeStreams(p.portal.Streams()) {
+		if category.Key == key {
+			streams = category.Streams
+			break
+		}
+	}
+
+	markup := p.bot.NewMarkup()
+
+	pages := max((len(streams)+pickerStreamsPerPage-1)/pickerStreamsPerPage, 1)
+	page = min(max(page, 0), pages-1)
+	streams = streams[page*pickerStreamsPerPage : min((page+1)*pickerStreamsPerPage, len(streams))]
+
+	rows := p.streamRows(markup, action, streams)
+	if nav := p.navigation(markup, page, pages, func(page int) telebot.Btn {
+		return markup.Data("", actionPickerCategory, action, key, strconv.Itoa(page))
+	}); nav != nil {
+		rows = append(rows, nav)
+	}
+	rows = append(rows, markup.Row(markup.Data("⬅️ Все группы", actionPickerPage, action, "0")))
+
+	markup.Inline(rows...)
+	return markup
+}
+
+func (p *picker) streamRows(markup *telebot.ReplyMarkup, action string, streams []models.Stream) []telebot.Row {
+	rows := make([]telebot.Row, 0, (len(streams)+1)/2)
+	for i := 0; i < len(streams); i += 2 {
+		row := telebot.Row{markup.Data(streams[i].Name, action, streams[i].ID)}
+		if i+1 < len(streams) {
+			row = append(row, markup.Data(streams[i+1].Name, action, streams[i+1].ID))
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// navigation returns previous and next page buttons, nil for a single page.
+func (p *picker) navigation(markup *telebot.ReplyMarkup, page, pages int, pageBtn func(page int) telebot.Btn) telebot.Row {
+	if pages <= 1 {
+		return nil
+	}
+
+	noop := markup.Data(" ", actionNoop)
+
+	prev, next := noop, noop
+	if page > 0 {
+		prev = pageBtn(page - 1)
+		prev.Text = "◀️"
+	}
+	if page < pages-1 {
+		next = pageBtn(page + 1)
+		next.Text = "▶️"
+	}
+
+	return markup.Row(prev, markup.Data(fmt.Sprintf("%d/%d", page+1, pages), actionNoop), next)
+}
+
+// categorizeStreams groups streams by category sorted by key, streams of a category are sorted by name.
+func categorizeStreams(streams []models.Stream) []streamCategory {
+	categories := make([]streamCategory, 0)
+	for _, stream := range sortedStreams(streams) {
+		key := streamCategoryKey(stream.Name)
+
+		i := slices.IndexFunc(categories, func(c streamCategory) bool { return c.Key == key })
+		if i == -1 {
+			categories = append(categories, streamCategory{Key: key})
+			i = len(categories) - 1
+		}
+		categories[i].Streams = append(categories[i].Streams, stream)
+	}
+
+	slices.SortFunc(categories, func(a, b streamCategory) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return categories
+}
+
+func sortedStreams(streams []models.Stream) []models.Stream {
+	sorted := slices.Clone(streams)
+	slices.SortFunc(sorted, func(a, b models.Stream) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return sorted
+}
+
+// streamCategoryKey returns letters of the specialty and the first digit of the course from a stream name,
+// e.g. "ИСП-2" for "ИСП-21".
+// Keys are cut to keep callback data within the Telegram limit.
+func streamCategoryKey(name string) string {
+	const maxLetters = 10
+
+	var letters strings.Builder
+	var count int
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r) && count < maxLetters:
+			letters.WriteRune(unicode.ToUpper(r))
+			count++
+		case unicode.IsDigit(r) && letters.Len() > 0:
+			return letters.String() + "-" + string(r)
+		}
+	}
+
+	if letters.Len() == 0 {
+		return string([]rune(name)[:min(len([]rune(name)), maxLetters)])
+	}
+
+	return letters.String()
+}
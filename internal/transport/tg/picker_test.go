@@ -0,0 +1,120 @@
+package tg
+
+import (
+	"fmt"
+	"pgtk-schedule/internal/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v4"
+)
+
+type fakePortal []models.Stream
+
+func (f fakePortal) Streams() []models.Stream {
+	return f
+}
+
+func TestStreamCategoryKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "ИСП-21", expected: "ИСП-2"},
+		{name: "исп 35к", expected: "ИСП-3"},
+		{name: "1ТМ-11", expected: "ТМ-1"},
+		{name: "Экстернат", expected: "ЭКСТЕРНАТ"},
+		{name: "101", expected: "101"},
+		{name: "Подготовительное отделение", expected: "ПОДГОТОВИТ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, streamCategoryKey(tt.name))
+		})
+	}
+}
+
+func TestCategorizeStreams(t *testing.T) {
+	categories := categorizeStreams([]models.Stream{
+		{ID: "1", Name: "ТМ-11"},
+		{ID: "2", Name: "ИСП-22"},
+		{ID: "3", Name: "ИСП-31"},
+		{ID: "4", Name: "ИСП-21"},
+	})
+
+	keys := make([]string, 0, len(categories))
+	for _, category := range categories {
+		keys = append(keys, category.Key)
+	}
+	assert.Equal(t, []string{"ИСП-2", "ИСП-3", "ТМ-1"}, keys)
+	assert.Equal(t, []models.Stream{{ID: "4", Name: "ИСП-21"}, {ID: "2", Name: "ИСП-22"}}, categories[0].Streams)
+}
+
+func TestPickerMarkup(t *testing.T) {
+	bot, err := telebot.NewBot(telebot.Settings{Offline: true})
+	require.NoError(t, err)
+
+	var streams fakePortal
+	for specialty := range 15 {
+		for group := range 12 {
+			streams = append(streams, models.Stream{ID: fmt.Sprintf("%d-%d", specialty, group), Name: fmt.Sprintf("С%c-1%d", 'А'+specialty, group)})
+		}
+	}
+	p := NewPicker(bot, streams)
+
+	t.Run("categories are paginated", func(t *testing.T) {
+		markup := p.categoriesMarkup(actionSetStream, 1)
+		rows := markup.InlineKeyboard
+		require.Len(t, rows, 2)
+		assert.Len(t, rows[0], 3)
+		assert.Equal(t, "СМ-1… (12)", rows[0][0].Text)
+
+		nav := rows[len(rows)-1]
+		assert.Equal(t, "◀️", nav[0].Text)
+		assert.Equal(t, actionPickerPage, nav[0].Unique)
+		assert.Equal(t, actionSetStream+"|0", nav[0].Data)
+		assert.Equal(t, "2/2", nav[1].Text)
+	})
+
+	t.Run("streams of a category are paginated", func(t *testing.T) {
+		markup := p.categoryMarkup(actionSetStream, "СА-1", 0)
+		rows := markup.InlineKeyboard
+		require.Len(t, rows, 7)
+		assert.Equal(t, "СА-10", rows[0][0].Text)
+		assert.Equal(t, actionSetStream, rows[0][0].Unique)
+		assert.Equal(t, "0-0", rows[0][0].Data)
+		assert.Equal(t, "▶️", rows[5][2].Text)
+		assert.Equal(t, "⬅️ Все группы", rows[6][0].Text)
+	})
+
+	t.Run("few streams are listed at once", func(t *testing.T) {
+		p := NewPicker(bot, streams[:3])
+		rows := p.categoriesMarkup(actionSetStream, 0).InlineKeyboard
+		require.Len(t, rows, 2)
+		assert.Equal(t, "СА-11", rows[0][1].Text)
+	})
+}
+
+func TestWithPickerHint(t *testing.T) {
+	bot, err := telebot.NewBot(telebot.Settings{Offline: true})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		chat     telebot.ChatType
+		expected string
+	}{
+		{name: "private chat", chat: telebot.ChatPrivate, expected: "Выберите группу:\n\n" + pickerHint},
+		{name: "group", chat: telebot.ChatGroup, expected: "Выберите группу:"},
+		{name: "supergroup", chat: telebot.ChatSuperGroup, expected: "Выберите группу:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := bot.NewContext(telebot.Update{Message: &telebot.Message{Chat: &telebot.Chat{ID: 1, Type: tt.chat}}})
+			assert.Equal(t, tt.expected, withPickerHint(ctx, "Выберите группу:"))
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package tg
 
 import (
+	"context"
 	"errors"
+	"log"
 	"pgtk-schedule/internal/models"
 
 	"gopkg.in/telebot.v4"
@@ -22,18 +24,43 @@ type scheduleService interface {
 	LessonsToString(lessons []models.Lesson) string
 }
 
+type scheduleTeacherService interface {
+	WeekLessons(teacher string) ([]models.Lesson, error)
+	TodayLessons(teacher string) ([]models.Lesson, error)
+	TomorrowLessons(teacher string) ([]models.Lesson, error)
+	LessonsToString(lessons []models.Lesson) string
+}
+
+type scheduleChangeService interface {
+	Remember(ctx context.Context, studentId int64, stream, substream string, lessons []models.Lesson) error
+}
+
+type scheduleMessageService interface {
+	Track(ctx context.Context, message models.ScheduleMessage, week bool) error
+}
+
 type schedule struct {
-	service scheduleService
+	service        scheduleService
+	teacherService scheduleTeacherService
+	changeService  scheduleChangeService
+	messageService scheduleMessageService
 }
 
-func NewSchedule(service scheduleService) *schedule {
+func NewSchedule(service scheduleService, teacherService scheduleTeacherService, changeService scheduleChangeService, messageService scheduleMessageService) *schedule {
 	return &schedule{
-		service: service,
+		service:        service,
+		teacherService: teacherService,
+		changeService:  changeService,
+		messageService: messageService,
 	}
 }
 
 func (s *schedule) CurrentWeekLessons() telebot.HandlerFunc {
 	return func(ctx telebot.Context) error {
+		if teacher, ok := ctx.Get(KeyTeacher).(string); ok && teacher != "" {
+			return s.teacherLessons(ctx, teacher, s.teacherService.WeekLessons)
+		}
+
 		streamCtx := ctx.Get(KeyStream)
 		substreamCtx := ctx.Get(KeySubstream)
 
@@ -55,12 +82,16 @@ func (s *schedule) CurrentWeekLessons() telebot.HandlerFunc {
 			return err
 		}
 
-		return ctx.Send(s.service.LessonsToString(lessons))
+		return s.send(ctx, stream, substream, lessons, true)
 	}
 }
 
 func (s *schedule) TodayLessons() telebot.HandlerFunc {
 	return func(ctx telebot.Context) error {
+		if teacher, ok := ctx.Get(KeyTeacher).(string); ok && teacher != "" {
+			return s.teacherLessons(ctx, teacher, s.teacherService.TodayLessons)
+		}
+
 		streamCtx := ctx.Get(KeyStream)
 		substreamCtx := ctx.Get(KeySubstream)
 
@@ -82,12 +113,16 @@ func (s *schedule) TodayLessons() telebot.HandlerFunc {
 			return err
 		}
 
-		return ctx.Send(s.service.LessonsToString(lessons))
+		return s.send(ctx, stream, substream, lessons, false)
 	}
 }
 
 func (s *schedule) TomorrowLessons() telebot.HandlerFunc {
 	return func(ctx telebot.Context) error {
+		if teacher, ok := ctx.Get(KeyTeacher).(string); ok && teacher != "" {
+			return s.teacherLessons(ctx, teacher, s.teacherService.TomorrowLessons)
+		}
+
 		streamCtx := ctx.Get(KeyStream)
 		substreamCtx := ctx.Get(KeySubstream)
 
@@ -109,6 +144,48 @@ func (s *schedule) TomorrowLessons() telebot.HandlerFunc {
 			return err
 		}
 
-		return ctx.Send(s.service.LessonsToString(lessons))
+		return s.send(ctx, stream, substream, lessons, false)
 	}
 }
+
+// send replies with lessons and remembers them, so the student is alerted about later changes
+// and the reply is edited when the lessons change.
+func (s *schedule) send(ctx telebot.Context, stream, substream string, lessons []models.Lesson, week bool) error {
+	sent, err := ctx.Bot().Send(ctx.Recipient(), s.service.LessonsToString(lessons))
+	if err != nil {
+		return err
+	}
+
+	// Change alerts are sent to students privately, so lessons shown in group chats are not remembered
+	if !isGroup(ctx.Chat()) {
+		if err := s.changeService.Remember(context.Background(), ctx.Sender().ID, stream, substream, lessons); err != nil {
+			log.Println(err.Error(), ctx.Sender().ID)
+		}
+	}
+
+	messageId := int64(sent.ID)
+	err = s.messageService.Track(context.Background(), models.ScheduleMessage{
+		ChatID:    sent.Chat.ID,
+		MessageID: &messageId,
+		Stream:    stream,
+		Substream: substream,
+		Lessons:   lessons,
+	}, week)
+	if err != nil {
+		log.Println(err.Error(), ctx.Sender().ID)
+	}
+
+	return nil
+}
+
+func (s *schedule) teacherLessons(ctx telebot.Context, teacher string, lessonsFn func(teacher string) ([]models.Lesson, error)) error {
+	lessons, err := lessonsFn(teacher)
+	if err != nil {
+		if errors.Is(err, models.ErrLessonsAreEmpty) {
+			return ctx.Reply("Расписание не найдено! Попробуйте ещё раз через пару минут, если считаете, что это ошибка.")
+		}
+		return err
+	}
+
+	return ctx.Send(s.teacherService.LessonsToString(lessons))
+}
@@ -0,0 +1,238 @@
+package tg
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"pgtk-schedule/internal/models"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"gopkg.in/telebot.v4"
+)
+
+const (
+	senderBatch = 100
+	// senderIdle is how often the queue is polled when it is empty.
+	senderIdle = time.Second
+)
+
+type outboxServiceForSender interface {
+	Claim(ctx context.Context, limit int) ([]models.OutboxMessage, error)
+	Renew(ctx context.Context, message models.OutboxMessage) (bool, error)
+	Sent(ctx context.Context, message models.OutboxMessage, messageId int) error
+	Retry(ctx context.Context, message models.OutboxMessage, err error, after time.Duration) error
+	Fail(ctx context.Context, message models.OutboxMessage, err error) error
+}
+
+type senderStudentService interface {
+	Deactivate(ctx context.Context, id int64) error
+}
+
+type senderChatService interface {
+	Pinned(ctx context.Context, id int64, messageId int64) (*int64, error)
+}
+
+type senderLeader interface {
+	IsLeader(ctx context.Context) (bool, error)
+}
+
+type senderLimiter interface {
+	Wait(ctx context.Context, chatId int64) error
+	Pause(d time.Duration)
+}
+
+type sender struct {
+	bot            *telebot.Bot
+	outboxService  outboxServiceForSender
+	studentService senderStudentService
+	chatService    senderChatService
+	leader         senderLeader
+	limiter        senderLimiter
+	workers        int
+}
+
+// telegramErrorCode matches API errors with unknown descriptions, e.g. "telegram: Bad Request: chat not found (400)".
+var telegramErrorCode = regexp.MustCompile(`(?s)^telegram: .* \((\d{3})\)$`)
+
+func NewSender(bot *telebot.Bot, outboxService outboxServiceForSender, studentService senderStudentService, chatService senderChatService, leader senderLeader, limiter senderLimiter, workers int) *sender {
+	return &sender{
+		bot:            bot,
+		outboxService:  outboxService,
+		studentService: studentService,
+		chatService:    chatService,
+		leader:         leader,
+		limiter:        limiter,
+		workers:        workers,
+	}
+}
+
+// Run delivers queued messages until ctx is done.
+// Messages are sent concurrently, but messages to one chat keep their order.
+// Only the leader instance sends, so instances do not multiply the Telegram limit.
+func (s *sender) Run(ctx context.Context) {
+	queues := make([]chan models.OutboxMessage, s.workers)
+	var wg sync.WaitGroup
+
+	for i := range queues {
+		queues[i] = make(chan models.OutboxMessage, senderBatch)
+		go func(queue <-chan models.OutboxMessage) {
+			for message := range queue {
+				s.deliver(ctx, message)
+				wg.Done()
+			}
+		}(queues[i])
+	}
+
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+	}()
+
+	for {
+		isLeader, err := s.leader.IsLeader(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Println(err.Error())
+		}
+
+		if !isLeader {
+			if !sleep(ctx, senderIdle) {
+				return
+			}
+			continue
+		}
+
+		messages, err := s.outboxService.Claim(ctx, senderBatch)
+		if err != nil && ctx.Err() == nil {
+			log.Println(err.Error())
+		}
+
+		if len(messages) == 0 {
+			if !sleep(ctx, senderIdle) {
+				return
+			}
+			continue
+		}
+
+		wg.Add(len(messages))
+		for _, message := range messages {
+			queues[uint64(message.ChatID)%uint64(len(queues))] <- message
+		}
+		wg.Wait()
+	}
+}
+
+// deliver sends the message once the limiter allows it and records the result.
+func (s *sender) deliver(ctx context.Context, message models.OutboxMessage) {
+	if err := s.limiter.Wait(ctx, message.ChatID); err != nil {
+		// The lease expires and the message is claimed again after restart
+		return
+	}
+
+	// The wait may outlast the lease, then the message may be claimed again and must not be sent twice
+	leased, err := s.outboxService.Renew(ctx, message)
+	if err != nil || !leased {
+		if err != nil {
+			log.Println(err.Error(), message.ID)
+		}
+		return
+	}
+
+	sent, err := s.send(message)
+	if err == nil {
+		if err := s.outboxService.Sent(ctx, message, sent.ID); err != nil {
+			log.Println(err.Error(), message.ID)
+		}
+
+		if message.Pin {
+			s.pin(ctx, sent)
+		}
+		return
+	}
+
+	var floodErr telebot.FloodError
+
+	switch {
+	case errors.As(err, &floodErr):
+		pause := time.Duration(floodErr.RetryAfter) * time.Second
+		s.limiter.Pause(pause)
+		err = s.outboxService.Retry(ctx, message, err, pause)
+	case errors.Is(err, telebot.ErrBlockedByUser) || errors.Is(err, telebot.ErrUserIsDeactivated):
+		if err := s.studentService.Deactivate(ctx, message.ChatID); err != nil {
+			log.Println(err.Error(), message.ChatID)
+		}
+		err = s.outboxService.Fail(ctx, message, err)
+	case message.EditMessageID != nil && (errors.Is(err, telebot.ErrSameMessageContent) || errors.Is(err, telebot.ErrMessageNotModified)):
+		// The message already has the text
+		err = s.outboxService.Sent(ctx, message, int(*message.EditMessageID))
+	case permanentError(err):
+		// Deleted chat or malformed message: retrying will not help
+		err = s.outboxService.Fail(ctx, message, err)
+	default:
+		err = s.outboxService.Retry(ctx, message, err, 0)
+	}
+
+	if err != nil {
+		log.Println(err.Error(), message.ID)
+	}
+}
+
+// permanentError reports whether Telegram has rejected the request with a client error other than 429.
+// telebot returns *telebot.Error only for known descriptions, other API errors are plain "telegram: ... (code)".
+func permanentError(err error) bool {
+	code := 0
+
+	var apiErr *telebot.Error
+	if errors.As(err, &apiErr) {
+		code = apiErr.Code
+	} else if match := telegramErrorCode.FindStringSubmatch(err.Error()); match != nil {
+		code, _ = strconv.Atoi(match[1])
+	}
+
+	return code >= http.StatusBadRequest && code < http.StatusInternalServerError && code != http.StatusTooManyRequests
+}
+
+// send sends the message or edits the already sent one.
+func (s *sender) send(message models.OutboxMessage) (*telebot.Message, error) {
+	if message.EditMessageID == nil {
+		return s.bot.Send(telebot.ChatID(message.ChatID), message.Text)
+	}
+
+	edited := &telebot.StoredMessage{MessageID: strconv.FormatInt(*message.EditMessageID, 10), ChatID: message.ChatID}
+	return s.bot.Edit(edited, message.Text)
+}
+
+// pin pins the sent message instead of the previously pinned one.
+// The bot may have no right to pin messages, so errors are only logged.
+func (s *sender) pin(ctx context.Context, sent *telebot.Message) {
+	if err := s.bot.Pin(sent, telebot.Silent); err != nil {
+		log.Println(err.Error(), sent.Chat.ID)
+		return
+	}
+
+	previous, err := s.chatService.Pinned(ctx, sent.Chat.ID, int64(sent.ID))
+	if err != nil {
+		log.Println(err.Error(), sent.Chat.ID)
+		return
+	}
+
+	if previous != nil && *previous != int64(sent.ID) {
+		if err := s.bot.Unpin(sent.Chat, int(*previous)); err != nil {
+			log.Println(err.Error(), sent.Chat.ID)
+		}
+	}
+}
+
+// sleep waits for d and reports whether ctx is still alive.
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
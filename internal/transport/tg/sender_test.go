@@ -0,0 +1,343 @@
+package tg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"pgtk-schedule/internal/models"
+	"pgtk-schedule/pkg/ratelimit"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/telebot.v4"
+)
+
+// fakeTelegram is a bot API answering sendMessage and editMessageText after latency.
+// Chats listed in errors get the error response instead.
+type fakeTelegram struct {
+	latency time.Duration
+	errors  map[int64]string
+
+	received map[int64][]string
+	edited   []string
+	pinned   []string
+	unpinned []string
+	mu       sync.Mutex
+}
+
+func (f *fakeTelegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ChatID    string `json:"chat_id"`
+		Text      string `json:"text"`
+		MessageID string `json:"message_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	chatId, _ := strconv.ParseInt(req.ChatID, 10, 64)
+	time.Sleep(f.latency)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if resp, ok := f.errors[chatId]; ok {
+		fmt.Fprint(w, resp)
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/pinChatMessage"):
+		f.pinned = append(f.pinned, req.MessageID)
+		fmt.Fprint(w, `{"ok":true,"result":true}`)
+		return
+	case strings.HasSuffix(r.URL.Path, "/unpinChatMessage"):
+		f.unpinned = append(f.unpinned, req.MessageID)
+		fmt.Fprint(w, `{"ok":true,"result":true}`)
+		return
+	case strings.HasSuffix(r.URL.Path, "/editMessageText"):
+		f.edited = append(f.edited, req.Text)
+	default:
+		f.received[chatId] = append(f.received[chatId], req.Text)
+	}
+	fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d,"chat":{"id":%d},"date":0}}`, len(f.received[chatId]), chatId)
+}
+
+type fakeOutboxService struct {
+	messages []models.OutboxMessage
+	total    int
+	// lost messages have been claimed again by another sender
+	lost map[int64]bool
+
+	sent    map[int64]int
+	retried map[int64]time.Duration
+	failed  map[int64]error
+	skipped int
+	done    chan struct{}
+	mu      sync.Mutex
+}
+
+func newFakeOutboxService(messages []models.OutboxMessage) *fakeOutboxService {
+	return &fakeOutboxService{
+		messages: messages,
+		total:    len(messages),
+		sent:     make(map[int64]int),
+		retried:  make(map[int64]time.Duration),
+		failed:   make(map[int64]error),
+		done:     make(chan struct{}),
+	}
+}
+
+func (f *fakeOutboxService) Claim(ctx context.Context, limit int) ([]models.OutboxMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := min(limit, len(f.messages))
+	claimed := f.messages[:n]
+	f.messages = f.messages[n:]
+
+	return claimed, nil
+}
+
+func (f *fakeOutboxService) Renew(ctx context.Context, message models.OutboxMessage) (bool, error) {
+	if !f.lost[message.ID] {
+		return true, nil
+	}
+
+	return false, f.record(func() { f.skipped++ })
+}
+
+func (f *fakeOutboxService) Sent(ctx context.Context, message models.OutboxMessage, messageId int) error {
+	return f.record(func() { f.sent[message.ID] = messageId })
+}
+
+func (f *fakeOutboxService) Retry(ctx context.Context, message models.OutboxMessage, err error, after time.Duration) error {
+	return f.record(func() { f.retried[message.ID] = after })
+}
+
+func (f *fakeOutboxService) Fail(ctx context.Context, message models.OutboxMessage, err error) error {
+	return f.record(func() { f.failed[message.ID] = err })
+}
+
+func (f *fakeOutboxService) record(fn func()) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fn()
+	if len(f.sent)+len(f.retried)+len(f.failed)+f.skipped == f.total {
+		close(f.done)
+	}
+
+	return nil
+}
+
+type fakeSenderChats struct {
+	pinned map[int64]int64
+	mu     sync.Mutex
+}
+
+func (f *fakeSenderChats) Pinned(ctx context.Context, id int64, messageId int64) (*int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous, ok := f.pinned[id]
+	f.pinned[id] = messageId
+	if !ok {
+		return nil, nil
+	}
+
+	return &previous, nil
+}
+
+type fakeSenderStudents struct {
+	deactivated []int64
+	mu          sync.Mutex
+}
+
+func (f *fakeSenderStudents) Deactivate(ctx context.Context, id int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.deactivated = append(f.deactivated, id)
+	return nil
+}
+
+type fakeSenderLeader bool
+
+func (l fakeSenderLeader) IsLeader(ctx context.Context) (bool, error) {
+	return bool(l), nil
+}
+
+func newFakeBot(t testing.TB, api *fakeTelegram) *telebot.Bot {
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	bot, err := telebot.NewBot(telebot.Settings{URL: srv.URL, Token: "test", Offline: true})
+	require.NoError(t, err)
+
+	return bot
+}
+
+// runSender delivers messages and returns how long it took.
+func runSender(t testing.TB, bot *telebot.Bot, outbox *fakeOutboxService, students *fakeSenderStudents, limiter *ratelimit.Limiter, workers int) time.Duration {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Now()
+	go NewSender(bot, outbox, students, &fakeSenderChats{pinned: make(map[int64]int64)}, fakeSenderLeader(true), limiter, workers).Run(ctx)
+
+	select {
+	case <-outbox.done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("messages are not delivered")
+	}
+
+	return time.Since(start)
+}
+
+func TestSender(t *testing.T) {
+	t.Run("concurrent delivery keeps chat order", func(t *testing.T) {
+		api := &fakeTelegram{latency: 20 * time.Millisecond, received: make(map[int64][]string)}
+		bot := newFakeBot(t, api)
+
+		var messages []models.OutboxMessage
+		for i := range 40 {
+			messages = append(messages, models.OutboxMessage{ID: int64(i), ChatID: int64(i % 10), Text: strconv.Itoa(i)})
+		}
+
+		outbox := newFakeOutboxService(messages)
+		elapsed := runSender(t, bot, outbox, &fakeSenderStudents{}, ratelimit.New(1000, 1000, 1000), 8)
+
+		assert.Len(t, outbox.sent, 40)
+		assert.Less(t, elapsed, 40*api.latency, "sequential delivery would take at least %s", 40*api.latency)
+		assert.Equal(t, []string{"3", "13", "23", "33"}, api.received[3])
+	})
+
+	t.Run("errors are classified", func(t *testing.T) {
+		api := &fakeTelegram{
+			errors: map[int64]string{
+				400: `{"ok":false,"error_code":400,"description":"Bad Request: message is too long"}`,
+				401: `{"ok":false,"error_code":400,"description":"Bad Request: unknown problem of the request"}`,
+				403: `{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`,
+				429: `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`,
+				430: `{"ok":false,"error_code":429,"description":"Too Many Requests"}`,
+				500: `{"ok":false,"error_code":500,"description":"Internal Server Error"}`,
+			},
+			received: make(map[int64][]string),
+		}
+		bot := newFakeBot(t, api)
+
+		var messages []models.OutboxMessage
+		for _, chatId := range []int64{400, 401, 403, 429, 430, 500} {
+			messages = append(messages, models.OutboxMessage{ID: chatId, ChatID: chatId, Text: "error"})
+		}
+
+		outbox := newFakeOutboxService(messages)
+		students := &fakeSenderStudents{}
+		runSender(t, bot, outbox, students, ratelimit.New(1000, 1000, 1000), 1)
+
+		assert.Contains(t, outbox.failed, int64(400))
+		// Unknown client errors are plain errors of telebot
+		assert.Contains(t, outbox.failed, int64(401))
+		assert.Contains(t, outbox.failed, int64(403))
+		assert.Equal(t, []int64{403}, students.deactivated)
+		assert.Equal(t, time.Second, outbox.retried[429])
+		assert.Contains(t, outbox.retried, int64(430))
+		assert.Equal(t, time.Duration(0), outbox.retried[500])
+	})
+
+	t.Run("sent messages are edited", func(t *testing.T) {
+		api := &fakeTelegram{
+			errors: map[int64]string{
+				304: `{"ok":false,"error_code":400,"description":"Bad Request: message is not modified: specified new message content and reply markup are exactly the same as a current content and reply markup of the message"}`,
+			},
+			received: make(map[int64][]string),
+		}
+		bot := newFakeBot(t, api)
+
+		messageId := int64(7)
+		outbox := newFakeOutboxService([]models.OutboxMessage{
+			{ID: 1, ChatID: 1, Text: "updated", EditMessageID: &messageId},
+			{ID: 304, ChatID: 304, Text: "same", EditMessageID: &messageId},
+		})
+		runSender(t, bot, outbox, &fakeSenderStudents{}, ratelimit.New(1000, 1000, 1000), 1)
+
+		assert.Equal(t, []string{"updated"}, api.edited)
+		assert.Empty(t, api.received)
+		assert.Equal(t, 7, outbox.sent[304])
+	})
+
+	t.Run("messages claimed again are not sent", func(t *testing.T) {
+		api := &fakeTelegram{received: make(map[int64][]string)}
+		bot := newFakeBot(t, api)
+
+		outbox := newFakeOutboxService([]models.OutboxMessage{
+			{ID: 1, ChatID: 1, Text: "leased"},
+			{ID: 2, ChatID: 2, Text: "lost"},
+		})
+		outbox.lost = map[int64]bool{2: true}
+		runSender(t, bot, outbox, &fakeSenderStudents{}, ratelimit.New(1000, 1000, 1000), 1)
+
+		assert.Equal(t, map[int64][]string{1: {"leased"}}, api.received)
+		assert.Equal(t, 1, outbox.skipped)
+	})
+
+	t.Run("followers do not send", func(t *testing.T) {
+		api := &fakeTelegram{received: make(map[int64][]string)}
+		bot := newFakeBot(t, api)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		outbox := newFakeOutboxService([]models.OutboxMessage{{ID: 1, ChatID: 1, Text: "text"}})
+		NewSender(bot, outbox, &fakeSenderStudents{}, &fakeSenderChats{pinned: make(map[int64]int64)}, fakeSenderLeader(false), ratelimit.New(1000, 1000, 1000), 1).Run(ctx)
+
+		assert.Len(t, outbox.messages, 1)
+		assert.Empty(t, api.received)
+	})
+
+	t.Run("pinned message replaces the previous one", func(t *testing.T) {
+		api := &fakeTelegram{received: make(map[int64][]string)}
+		bot := newFakeBot(t, api)
+
+		outbox := newFakeOutboxService([]models.OutboxMessage{
+			{ID: 1, ChatID: -100, Text: "monday", Pin: true},
+			{ID: 2, ChatID: -100, Text: "evening"},
+			{ID: 3, ChatID: -100, Text: "tuesday", Pin: true},
+		})
+		runSender(t, bot, outbox, &fakeSenderStudents{}, ratelimit.New(1000, 1000, 1000), 1)
+
+		// The last pin is requested after the message is recorded as sent
+		assert.Eventually(t, func() bool {
+			api.mu.Lock()
+			defer api.mu.Unlock()
+			return len(api.unpinned) == 1
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, []string{"1", "3"}, api.pinned)
+		assert.Equal(t, []string{"1"}, api.unpinned)
+	})
+}
+
+// BenchmarkSender shows throughput of the sender with Telegram limits against an API answering in 50ms.
+func BenchmarkSender(b *testing.B) {
+	api := &fakeTelegram{latency: 50 * time.Millisecond, received: make(map[int64][]string)}
+	bot := newFakeBot(b, api)
+
+	messages := make([]models.OutboxMessage, b.N)
+	for i := range messages {
+		messages[i] = models.OutboxMessage{ID: int64(i), ChatID: int64(i), Text: "schedule"}
+	}
+
+	b.ResetTimer()
+	elapsed := runSender(b, bot, newFakeOutboxService(messages), &fakeSenderStudents{}, ratelimit.New(25, 25, 1), 8)
+
+	b.ReportMetric(float64(b.N)/elapsed.Seconds(), "msg/s")
+}
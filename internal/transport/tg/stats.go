@@ -0,0 +1,57 @@
+package tg
+
+import (
+	"errors"
+	"pgtk-schedule/internal/models"
+
+	"gopkg.in/telebot.v4"
+)
+
+type statsService interface {
+	Term(stream, substream string) (models.Stats, error)
+	StatsToString(stats models.Stats) string
+}
+
+type stats struct {
+	service statsService
+}
+
+func NewStats(service statsService) *stats {
+	return &stats{
+		service: service,
+	}
+}
+
+func (s *stats) Term() telebot.HandlerFunc {
+	return func(ctx telebot.Context) error {
+		streamCtx := ctx.Get(KeyStream)
+		substreamCtx := ctx.Get(KeySubstream)
+
+		stream, ok := streamCtx.(string)
+		if !ok || stream == "" {
+			return ErrStreamIsInvalid
+		}
+
+		substream, ok := substreamCtx.(string)
+		if !ok {
+			return ErrSubstreamIsInvalid
+		}
+
+		stats, err := s.service.Term(stream, substream)
+		if err != nil {
+			if errors.Is(err, models.ErrLessonsAreEmpty) {
+				return ctx.Reply("Расписание на семестр не найдено! Попробуйте ещё раз через пару минут, если считаете, что это ошибка.")
+			}
+			return err
+		}
+
+		// Stats of a group with many disciplines and teachers may exceed the message limit
+		for _, part := range splitMessage(s.service.StatsToString(stats), maxMessageLength) {
+			if err := ctx.Send(part); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"pgtk-schedule/internal/models"
+	"strings"
 
 	"gopkg.in/telebot.v4"
 )
@@ -14,6 +15,7 @@ const (
 	actionSetSubstream = "setSubstream"
 
 	KeyStudent = "student"
+	KeyTeacher = "teacher"
 )
 
 type studentService interface {
@@ -22,6 +24,17 @@ type studentService interface {
 	UpdateStream(ctx context.Context, id int64, stream string) error
 	UpdateSubstream(ctx context.Context, id int64, substream string) error
 	UpdateNickname(ctx context.Context, id int64, nickname string) error
+	UpdateOnboardingStep(ctx context.Context, id int64, step string) error
+	Deactivate(ctx context.Context, id int64) error
+	Activate(ctx context.Context, id int64) error
+}
+
+type studentSubscriptionService interface {
+	Replace(ctx context.Context, studentId int64, stream, substream string) error
+}
+
+type studentNotifySettingsService interface {
+	SetTime(ctx context.Context, studentId int64, kind models.NotifyKind, minute int) error
 }
 
 type portal interface {
@@ -29,16 +42,45 @@ type portal interface {
 }
 
 type student struct {
-	service studentService
-	portal  portal
-	bot     *telebot.Bot
+	service               studentService
+	subscriptionService   studentSubscriptionService
+	notifySettingsService studentNotifySettingsService
+	portal                portal
+	picker                *picker
+	bot                   *telebot.Bot
 }
 
-func NewStudent(bot *telebot.Bot, service studentService, portal portal) *student {
+func NewStudent(bot *telebot.Bot, service studentService, subscriptionService studentSubscriptionService, notifySettingsService studentNotifySettingsService, portal portal, picker *picker) *student {
 	return &student{
-		service: service,
-		portal:  portal,
-		bot:     bot,
+		service:               service,
+		subscriptionService:   subscriptionService,
+		notifySettingsService: notifySettingsService,
+		portal:                portal,
+		picker:                picker,
+		bot:                   bot,
+	}
+}
+
+// ChatMember tracks whether a user has blocked the bot in a private chat.
+func (s *student) ChatMember() telebot.HandlerFunc {
+	return func(ctx telebot.Context) error {
+		update := ctx.ChatMember()
+		if update == nil || update.Chat == nil || update.Chat.Type != telebot.ChatPrivate || update.NewChatMember == nil {
+			return nil
+		}
+
+		switch update.NewChatMember.Role {
+		case telebot.Kicked, telebot.Left:
+			return s.service.Deactivate(context.Background(), update.Chat.ID)
+		case telebot.Member:
+			err := s.service.Activate(context.Background(), update.Chat.ID)
+			if errors.Is(err, models.ErrStudentNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		return nil
 	}
 }
 
@@ -55,6 +97,7 @@ func (s *student) RegisteredStudent() telebot.MiddlewareFunc {
 					ctx.Set(KeyStudent, models.Student{
 						ID:       ctx.Sender().ID,
 						Nickname: &ctx.Sender().Username,
+						Active:   true,
 					})
 
 					return next(ctx)
@@ -80,6 +123,14 @@ func (s *student) RegisteredStudent() telebot.MiddlewareFunc {
 				student.Nickname = &ctx.Sender().Username
 			}
 
+			// Writing to the bot means it is unblocked again
+			if !student.Active {
+				if err := s.service.Activate(context.Background(), student.ID); err != nil {
+					return err
+				}
+				student.Active = true
+			}
+
 			ctx.Set(KeyStudent, student)
 
 			if student.Stream != nil {
@@ -90,6 +141,10 @@ func (s *student) RegisteredStudent() telebot.MiddlewareFunc {
 				ctx.Set(KeySubstream, *student.Substream)
 			}
 
+			if student.IsTeacher() {
+				ctx.Set(KeyTeacher, *student.Teacher)
+			}
+
 			return next(ctx)
 		}
 	}
@@ -100,6 +155,10 @@ func (s *student) validate(student models.Student) error {
 		return models.ErrStudentNotFound
 	}
 
+	if student.IsTeacher() {
+		return nil
+	}
+
 	if student.Stream == nil {
 		return models.ErrStudentStreamMissed
 	}
@@ -117,10 +176,14 @@ func (s *student) ValidateStudent() telebot.MiddlewareFunc {
 				return models.ErrStudentNotFound
 			}
 
+			if modelStudent.StreamMissing && !modelStudent.IsTeacher() {
+				return ctx.Reply(streamGoneMessage)
+			}
+
 			err := s.validate(modelStudent)
 			if err != nil {
 				if errors.Is(err, models.ErrStudentStreamMissed) {
-					return ctx.Reply("Укажите группу с помощью команды /setstream")
+					return ctx.Reply("Укажите группу с помощью команды /start или /setstream, или выберите себя в списке преподавателей командой /iamteacher")
 				}
 				return err
 			}
@@ -133,6 +196,7 @@ func (s *student) ValidateStudent() telebot.MiddlewareFunc {
 func (s *student) SetStream() telebot.HandlerFunc {
 	s.bot.Handle("\f"+actionSetStream, func(ctx telebot.Context) error {
 		stream := ctx.Callback().Data
+		s.picker.Forget(ctx.Chat().ID)
 
 		streams := s.portal.Streams()
 		var foundStream models.Stream
@@ -147,21 +211,22 @@ func (s *student) SetStream() telebot.HandlerFunc {
 			return models.ErrStreamIsUnknown
 		}
 
-		err := s.service.UpdateStream(context.Background(), ctx.Callback().Sender.ID, stream)
-		if err != nil {
-			return err
-		}
-
 		if len(foundStream.Substreams) == 0 {
-			_, err := s.bot.Edit(ctx.Callback().Message, fmt.Sprintf("Группа %s установлена!", foundStream.Name))
+			err := s.subscriptionService.Replace(context.Background(), ctx.Callback().Sender.ID, stream, "")
+			if err != nil {
+				return err
+			}
+
+			_, err = s.bot.Edit(ctx.Callback().Message, fmt.Sprintf("Группа %s установлена!", foundStream.Name))
 			return err
 		}
 
 		if len(foundStream.Substreams) == 1 {
-			err := s.service.UpdateSubstream(context.Background(), ctx.Callback().Sender.ID, foundStream.Substreams[0])
+			err := s.subscriptionService.Replace(context.Background(), ctx.Callback().Sender.ID, stream, foundStream.Substreams[0])
 			if err != nil {
 				return err
 			}
+
 			_, err = s.bot.Edit(ctx.Callback().Message, fmt.Sprintf("Подгруппа %s установлена!", foundStream.Substreams[0]))
 			return err
 		}
@@ -170,38 +235,83 @@ func (s *student) SetStream() telebot.HandlerFunc {
 
 		btns := make([]telebot.Row, 0, len(streams))
 		for _, substream := range foundStream.Substreams {
-			b := markup.Data(substream, actionSetSubstream, substream)
+			b := markup.Data(substream, actionSetSubstream, stream, substream)
 			btns = append(btns, markup.Row(b))
 		}
 		markup.Inline(btns...)
 
-		_, err = s.bot.Edit(ctx.Callback().Message, "Выберите подгруппу:", markup)
+		_, err := s.bot.Edit(ctx.Callback().Message, "Выберите подгруппу:", markup)
 		return err
 	})
 
 	s.bot.Handle("\f"+actionSetSubstream, func(ctx telebot.Context) error {
-		substream := ctx.Callback().Data
+		args := ctx.Args()
+		if len(args) != 2 {
+			return ErrSubstreamIsInvalid
+		}
 
-		err := s.service.UpdateSubstream(context.Background(), ctx.Callback().Sender.ID, substream)
+		// The group is replaced only now, so the previous group keeps notifying until the substream is chosen
+		err := s.subscriptionService.Replace(context.Background(), ctx.Callback().Sender.ID, args[0], args[1])
 		if err != nil {
 			return err
 		}
 
-		_, err = s.bot.Edit(ctx.Callback().Message, fmt.Sprintf("Подгруппа %s установлена!", substream))
+		_, err = s.bot.Edit(ctx.Callback().Message, fmt.Sprintf("Подгруппа %s установлена!", args[1]))
 		return err
 	})
 
 	return func(ctx telebot.Context) error {
-		streams := s.portal.Streams()
-		markup := s.bot.NewMarkup()
+		return s.picker.Show(ctx, actionSetStream, "Выберите группу:", ctx.Message().Payload)
+	}
+}
 
-		btns := make([]telebot.Row, 0, len(streams))
-		for _, stream := range streams {
-			b := markup.Data(stream.Name, actionSetStream, stream.ID)
-			btns = append(btns, markup.Row(b))
+// Start sets the stream of the student from a deep link like t.me/bot?start=g123_s1.
+// Without a payload greet is called.
+func (s *student) Start(greet telebot.HandlerFunc) telebot.HandlerFunc {
+	return func(ctx telebot.Context) error {
+		payload := strings.TrimSpace(ctx.Message().Payload)
+		if payload == "" {
+			return greet(ctx)
+		}
+
+		link, err := decodeStartLink(s.portal.Streams(), payload)
+		if err != nil {
+			return ctx.Reply(err.Error() + ". Укажите группу командой /setstream")
+		}
+
+		id := ctx.Sender().ID
+		if err := s.subscriptionService.Replace(context.Background(), id, link.Stream.ID, link.Substream); err != nil {
+			return err
+		}
+
+		times := [...]struct {
+			Kind   models.NotifyKind
+			Minute *int
+		}{
+			{Kind: models.NotifyMorning, Minute: link.MorningTime},
+			{Kind: models.NotifyEvening, Minute: link.EveningTime},
+		}
+
+		for _, t := range times {
+			if t.Minute == nil {
+				continue
+			}
+
+			if err := s.notifySettingsService.SetTime(context.Background(), id, t.Kind, *t.Minute); err != nil {
+				return err
+			}
+		}
+
+		// The link replaces the onboarding, notifications keep default or link settings
+		if err := s.service.UpdateOnboardingStep(context.Background(), id, ""); err != nil {
+			return err
+		}
+
+		text := fmt.Sprintf("Группа %s установлена!", link.Stream.Name)
+		if link.Substream != "" {
+			text = fmt.Sprintf("Группа %s, подгруппа %s установлена!", link.Stream.Name, link.Substream)
 		}
-		markup.Inline(btns...)
 
-		return ctx.Reply("Выберите группу:", markup)
+		return ctx.Reply(text + " Расписание покажут команды /today, /tomorrow и /week, а уведомления настраиваются командой /notifysettings")
 	}
 }
@@ -0,0 +1,272 @@
+package tg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"pgtk-schedule/internal/models"
+	"strconv"
+
+	"gopkg.in/telebot.v4"
+)
+
+const (
+	actionActivateSubscription     = "activateSubscription"
+	actionToggleSubscriptionNotify = "toggleSubscriptionNotify"
+	actionDeleteSubscription       = "deleteSubscription"
+	actionAddSubscription          = "addSubscription"
+	actionFollowStream             = "followStream"
+	actionFollowSubstream          = "followSubstream"
+	actionShareGroup               = "shareGroup"
+)
+
+type subscriptionService interface {
+	Follow(ctx context.Context, studentId int64, stream, substream string) error
+	FindByStudentID(ctx context.Context, studentId int64) ([]models.Subscription, error)
+	ToggleNotify(ctx context.Context, studentId, id int64) error
+	Delete(ctx context.Context, studentId, id int64) error
+	Activate(ctx context.Context, studentId, id int64) (models.Subscription, error)
+	Title(subscription models.Subscription) string
+}
+
+type subscriptionStudentService interface {
+	FindByID(ctx context.Context, id int64) (models.Student, error)
+}
+
+type subscription struct {
+	bot            *telebot.Bot
+	service        subscriptionService
+	studentService subscriptionStudentService
+	portal         portal
+	picker         *picker
+}
+
+func NewSubscription(bot *telebot.Bot, service subscriptionService, studentService subscriptionStudentService, portal portal, picker *picker) *subscription {
+	return &subscription{
+		bot:            bot,
+		service:        service,
+		studentService: studentService,
+		portal:         portal,
+		picker:         picker,
+	}
+}
+
+func (s *subscription) List() telebot.HandlerFunc {
+	s.bot.Handle("\f"+actionActivateSubscription, func(ctx telebot.Context) error {
+		id, err := strconv.ParseInt(ctx.Callback().Data, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		subscription, err := s.service.Activate(context.Background(), ctx.Callback().Sender.ID, id)
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Respond(&telebot.CallbackResponse{Text: fmt.Sprintf("Активная группа: %s", s.service.Title(subscription))}); err != nil {
+			return err
+		}
+
+		return s.edit(ctx)
+	})
+
+	s.bot.Handle("\f"+actionToggleSubscriptionNotify, func(ctx telebot.Context) error {
+		id, err := strconv.ParseInt(ctx.Callback().Data, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		if err := s.service.ToggleNotify(context.Background(), ctx.Callback().Sender.ID, id); err != nil {
+			return err
+		}
+
+		return s.edit(ctx)
+	})
+
+	s.bot.Handle("\f"+actionDeleteSubscription, func(ctx telebot.Context) error {
+		id, err := strconv.ParseInt(ctx.Callback().Data, 10, 64)
+		if err != nil {
+			return err
+		}
+
+		if err := s.service.Delete(context.Background(), ctx.Callback().Sender.ID, id); err != nil {
+			return err
+		}
+
+		return s.edit(ctx)
+	})
+
+	s.bot.Handle("\f"+actionAddSubscription, func(ctx telebot.Context) error {
+		return s.picker.Edit(ctx, actionFollowStream, "Выберите группу, которую хотите добавить:")
+	})
+
+	s.bot.Handle("\f"+actionFollowStream, func(ctx telebot.Context) error {
+		stream := ctx.Callback().Data
+		s.picker.Forget(ctx.Chat().ID)
+
+		var foundStream models.Stream
+		for _, st := range s.portal.Streams() {
+			if st.ID == stream {
+				foundStream = st
+				break
+			}
+		}
+
+		if foundStream.ID == "" {
+			return models.ErrStreamIsUnknown
+		}
+
+		if len(foundStream.Substreams) <= 1 {
+			substream := ""
+			if len(foundStream.Substreams) == 1 {
+				substream = foundStream.Substreams[0]
+			}
+
+			if err := s.service.Follow(context.Background(), ctx.Callback().Sender.ID, stream, substream); err != nil {
+				return err
+			}
+
+			return s.edit(ctx)
+		}
+
+		markup := s.bot.NewMarkup()
+
+		btns := make([]telebot.Row, 0, len(foundStream.Substreams))
+		for _, substream := range foundStream.Substreams {
+			b := markup.Data(substream, actionFollowSubstream, stream, substream)
+			btns = append(btns, markup.Row(b))
+		}
+		markup.Inline(btns...)
+
+		_, err := s.bot.Edit(ctx.Callback().Message, "Выберите подгруппу:", markup)
+		return err
+	})
+
+	s.bot.Handle("\f"+actionFollowSubstream, func(ctx telebot.Context) error {
+		args := ctx.Args()
+		if len(args) != 2 {
+			return ErrSubstreamIsInvalid
+		}
+
+		if err := s.service.Follow(context.Background(), ctx.Callback().Sender.ID, args[0], args[1]); err != nil {
+			return err
+		}
+
+		return s.edit(ctx)
+	})
+
+	s.bot.Handle("\f"+actionShareGroup, func(ctx telebot.Context) error {
+		student, err := s.studentService.FindByID(context.Background(), ctx.Callback().Sender.ID)
+		if err != nil {
+			return err
+		}
+
+		if student.Stream == nil {
+			return ctx.Respond(&telebot.CallbackResponse{Text: "Сначала выберите группу"})
+		}
+
+		link := startLink{}
+		for _, stream := range s.portal.Streams() {
+			if stream.ID == *student.Stream {
+				link.Stream = stream
+				break
+			}
+		}
+		if student.Substream != nil {
+			link.Substream = *student.Substream
+		}
+
+		payload, err := encodeStartLink(link)
+		if err != nil {
+			if errors.Is(err, ErrStartLinkInvalid) || errors.Is(err, ErrSubstreamIsInvalid) {
+				return ctx.Respond(&telebot.CallbackResponse{Text: "Этой группой пока нельзя поделиться"})
+			}
+			return err
+		}
+
+		if err := ctx.Respond(); err != nil {
+			return err
+		}
+
+		return ctx.Send(fmt.Sprintf("Перешлите эту ссылку одногруппникам, бот сразу покажет расписание вашей группы:\n%s", startLinkURL(s.bot.Me.Username, payload)))
+	})
+
+	return func(ctx telebot.Context) error {
+		text, markup, err := s.render(ctx.Sender().ID)
+		if err != nil {
+			return err
+		}
+
+		return ctx.Reply(text, markup)
+	}
+}
+
+func (s *subscription) edit(ctx telebot.Context) error {
+	text, markup, err := s.render(ctx.Callback().Sender.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.bot.Edit(ctx.Callback().Message, text, markup)
+	return err
+}
+
+func (s *subscription) render(studentId int64) (string, *telebot.ReplyMarkup, error) {
+	student, err := s.studentService.FindByID(context.Background(), studentId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	subscriptions, err := s.service.FindByStudentID(context.Background(), studentId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	markup := s.bot.NewMarkup()
+
+	btns := make([]telebot.Row, 0, len(subscriptions)+1)
+	for _, subscription := range subscriptions {
+		id := strconv.FormatInt(subscription.ID, 10)
+
+		title := s.service.Title(subscription)
+		if isActiveSubscription(student, subscription) {
+			title = "✅ " + title
+		}
+
+		notifyText := "🔕"
+		if subscription.Notify {
+			notifyText = "🔔"
+		}
+
+		btns = append(btns, markup.Row(
+			markup.Data(title, actionActivateSubscription, id),
+			markup.Data(notifyText, actionToggleSubscriptionNotify, id),
+			markup.Data("✖", actionDeleteSubscription, id),
+		))
+	}
+	btns = append(btns, markup.Row(markup.Data("➕ Добавить группу", actionAddSubscription)))
+	if student.Stream != nil {
+		btns = append(btns, markup.Row(markup.Data("🔗 Поделиться моей группой", actionShareGroup)))
+	}
+	markup.Inline(btns...)
+
+	text := "Ваши группы. Нажмите на группу, чтобы сделать её активной, 🔔 — чтобы включить или выключить уведомления по ней:"
+	if len(subscriptions) == 0 {
+		text = "Вы ещё не добавили ни одной группы."
+	}
+
+	return text, markup, nil
+}
+
+func isActiveSubscription(student models.Student, subscription models.Subscription) bool {
+	if student.Stream == nil || *student.Stream != subscription.Stream {
+		return false
+	}
+
+	substream := ""
+	if student.Substream != nil {
+		substream = *student.Substream
+	}
+
+	return substream == subscription.Substream
+}
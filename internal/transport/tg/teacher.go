@@ -1,10 +1,12 @@
 package tg
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"pgtk-schedule/internal/models"
 	"slices"
+	"strconv"
 	"strings"
 
 	"gopkg.in/telebot.v4"
@@ -12,30 +14,49 @@ import (
 
 const (
 	actionFindTeacher = "findTeacher"
+	actionSetTeacher  = "setTeacher"
+	actionTeacherPage = "teacherPage"
+
+	teachersPerPage = 20
 )
 
 type teacherService interface {
-	TodayList() ([]string, error)
+	TodayList() ([]models.Teacher, error)
+	List() ([]models.Teacher, error)
+	Resolve(id int64) (models.Teacher, error)
 	Find(teacher string) (models.Lesson, error)
 }
 
+type teacherStudentService interface {
+	UpdateTeacher(ctx context.Context, id int64, teacher string) error
+}
+
 type teacher struct {
 	bot            *telebot.Bot
 	teacherService teacherService
+	studentService teacherStudentService
 }
 
-func NewTeacher(bot *telebot.Bot, teacherService teacherService) *teacher {
+func NewTeacher(bot *telebot.Bot, teacherService teacherService, studentService teacherStudentService) *teacher {
 	return &teacher{
 		bot:            bot,
 		teacherService: teacherService,
+		studentService: studentService,
 	}
 }
 
 func (t *teacher) Find() telebot.HandlerFunc {
 	t.bot.Handle("\f"+actionFindTeacher, func(ctx telebot.Context) error {
-		teacher := ctx.Callback().Data
+		teacher, err := t.resolve(ctx.Callback().Data)
+		if err != nil {
+			if errors.Is(err, models.ErrTeacherNotFound) {
+				_, err = t.bot.Edit(ctx.Callback().Message, "Преподаватель не найден!")
+				return err
+			}
+			return err
+		}
 
-		lesson, err := t.teacherService.Find(teacher)
+		lesson, err := t.teacherService.Find(teacher.Name)
 		if err != nil {
 			if errors.Is(err, models.ErrLessonNotFound) {
 				_, err = t.bot.Edit(ctx.Callback().Message, "Пара не найдена!")
@@ -61,24 +82,15 @@ func (t *teacher) Find() telebot.HandlerFunc {
 			return ctx.Reply("Преподаватели не найдены!")
 		}
 
-		slices.Sort(teachers)
+		slices.SortFunc(teachers, func(a, b models.Teacher) int {
+			return strings.Compare(a.Name, b.Name)
+		})
 
 		markup := t.bot.NewMarkup()
 
 		btns := make([]telebot.Row, 0, len(teachers))
 		for _, teacher := range teachers {
-			if teacher == "" {
-				continue
-			}
-
-			splitted := strings.Split(teacher, " ")
-			data := splitted[0]
-			if len(splitted) > 1 {
-				data += " "
-				data += splitted[1]
-			}
-
-			b := markup.Data(teacher, actionFindTeacher, data)
+			b := markup.Data(teacher.Name, actionFindTeacher, strconv.FormatInt(teacher.ID, 10))
 			btns = append(btns, markup.Row(b))
 		}
 		markup.Inline(btns...)
@@ -86,3 +98,94 @@ func (t *teacher) Find() telebot.HandlerFunc {
 		return ctx.Reply("Список преподавателей, у которых сегодня есть пары:", markup)
 	}
 }
+
+// Register lets the user pick themselves from the list of teachers.
+func (t *teacher) Register() telebot.HandlerFunc {
+	t.bot.Handle("\f"+actionTeacherPage, func(ctx telebot.Context) error {
+		page, err := strconv.Atoi(ctx.Callback().Data)
+		if err != nil {
+			return err
+		}
+
+		markup, err := t.registerMarkup(page)
+		if err != nil {
+			return err
+		}
+
+		_, err = t.bot.Edit(ctx.Callback().Message, "Выберите себя в списке преподавателей:", markup)
+		return err
+	})
+
+	t.bot.Handle("\f"+actionSetTeacher, func(ctx telebot.Context) error {
+		teacher, err := t.resolve(ctx.Callback().Data)
+		if err != nil {
+			if errors.Is(err, models.ErrTeacherNotFound) {
+				_, err = t.bot.Edit(ctx.Callback().Message, "Преподаватель не найден!")
+				return err
+			}
+			return err
+		}
+
+		if err := t.studentService.UpdateTeacher(context.Background(), ctx.Callback().Sender.ID, teacher.Name); err != nil {
+			return err
+		}
+
+		_, err = t.bot.Edit(ctx.Callback().Message, fmt.Sprintf("Готово! Теперь вы получаете расписание преподавателя %s. Чтобы вернуться к расписанию группы, используйте /setstream", teacher.Name))
+		return err
+	})
+
+	return func(ctx telebot.Context) error {
+		markup, err := t.registerMarkup(0)
+		if err != nil {
+			return err
+		}
+
+		return ctx.Reply("Выберите себя в списке преподавателей:", markup)
+	}
+}
+
+func (t *teacher) registerMarkup(page int) (*telebot.ReplyMarkup, error) {
+	teachers, err := t.teacherService.List()
+	if err != nil {
+		return nil, err
+	}
+
+	pages := (len(teachers) + teachersPerPage - 1) / teachersPerPage
+	page = max(0, min(page, pages-1))
+
+	start := page * teachersPerPage
+	end := min(start+teachersPerPage, len(teachers))
+
+	markup := t.bot.NewMarkup()
+
+	btns := make([]telebot.Row, 0, end-start+1)
+	for _, teacher := range teachers[start:end] {
+		b := markup.Data(teacher.Name, actionSetTeacher, strconv.FormatInt(teacher.ID, 10))
+		btns = append(btns, markup.Row(b))
+	}
+
+	nav := make(telebot.Row, 0, 2)
+	if page > 0 {
+		nav = append(nav, markup.Data("⬅️", actionTeacherPage, strconv.Itoa(page-1)))
+	}
+	if page < pages-1 {
+		nav = append(nav, markup.Data("➡️", actionTeacherPage, strconv.Itoa(page+1)))
+	}
+	if len(nav) > 0 {
+		btns = append(btns, nav)
+	}
+
+	markup.Inline(btns...)
+
+	return markup, nil
+}
+
+// resolve finds the teacher by identifier from callback data.
+func (t *teacher) resolve(data string) (models.Teacher, error) {
+	id, err := strconv.ParseInt(data, 10, 64)
+	if err != nil {
+		return models.Teacher{}, models.ErrTeacherNotFound
+	}
+
+	return t.teacherService.Resolve(id)
+}
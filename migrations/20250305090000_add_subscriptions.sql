@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS subscriptions(
+  id bigserial PRIMARY KEY,
+  student_id bigint NOT NULL,
+  stream varchar(255) NOT NULL,
+  substream varchar(255) NOT NULL DEFAULT '',
+  label text NOT NULL DEFAULT '',
+  notify bool NOT NULL DEFAULT true,
+  UNIQUE(student_id, stream, substream),
+  FOREIGN KEY(student_id) REFERENCES students(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+-- Current group of existing students becomes their first subscription
+INSERT INTO subscriptions (student_id, stream, substream)
+SELECT id, stream, COALESCE(substream, '') FROM students
+WHERE stream IS NOT NULL
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS subscriptions;
+-- +goose StatementEnd
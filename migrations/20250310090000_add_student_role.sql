@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE students
+  ADD COLUMN IF NOT EXISTS role varchar(16) NOT NULL DEFAULT 'student',
+  ADD COLUMN IF NOT EXISTS teacher text;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE students
+  DROP COLUMN IF EXISTS role,
+  DROP COLUMN IF EXISTS teacher;
+-- +goose StatementEnd
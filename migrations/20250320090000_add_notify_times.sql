@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Times are minutes since midnight, days are bitmasks where bit 0 is sunday.
+ALTER TABLE notify_settings
+  ADD COLUMN IF NOT EXISTS morning_time smallint NOT NULL DEFAULT 300,
+  ADD COLUMN IF NOT EXISTS morning_days smallint NOT NULL DEFAULT 126,
+  ADD COLUMN IF NOT EXISTS evening_time smallint NOT NULL DEFAULT 1080,
+  ADD COLUMN IF NOT EXISTS evening_days smallint NOT NULL DEFAULT 62,
+  ADD COLUMN IF NOT EXISTS week_time smallint NOT NULL DEFAULT 720,
+  ADD COLUMN IF NOT EXISTS week_days smallint NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE notify_settings
+  DROP COLUMN IF EXISTS morning_time,
+  DROP COLUMN IF EXISTS morning_days,
+  DROP COLUMN IF EXISTS evening_time,
+  DROP COLUMN IF EXISTS evening_days,
+  DROP COLUMN IF EXISTS week_time,
+  DROP COLUMN IF EXISTS week_days;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE notify_settings
+  ADD COLUMN IF NOT EXISTS reminder bool NOT NULL DEFAULT false,
+  ADD COLUMN IF NOT EXISTS reminder_lead smallint NOT NULL DEFAULT 10;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE notify_settings
+  DROP COLUMN IF EXISTS reminder,
+  DROP COLUMN IF EXISTS reminder_lead;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE notify_settings ADD COLUMN IF NOT EXISTS paused_until date;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE notify_settings DROP COLUMN IF EXISTS paused_until;
+-- +goose StatementEnd
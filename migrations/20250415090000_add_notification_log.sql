@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS notification_log(
+  id bigserial PRIMARY KEY,
+  run_id varchar(64) NOT NULL,
+  kind varchar(32) NOT NULL,
+  student_id bigint NOT NULL,
+  status varchar(16) NOT NULL,
+  outbox_id bigint,
+  message_id bigint,
+  error text,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS notification_log_run_idx ON notification_log(run_id);
+CREATE INDEX IF NOT EXISTS notification_log_kind_idx ON notification_log(kind, created_at);
+CREATE INDEX IF NOT EXISTS notification_log_student_idx ON notification_log(student_id, created_at);
+CREATE INDEX IF NOT EXISTS notification_log_outbox_idx ON notification_log(outbox_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS notification_log;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS lesson_snapshots(
+  student_id bigint NOT NULL,
+  stream varchar(255) NOT NULL,
+  substream varchar(255) NOT NULL DEFAULT '',
+  date date NOT NULL,
+  lessons jsonb NOT NULL,
+  updated_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (student_id, stream, substream, date),
+  FOREIGN KEY(student_id) REFERENCES students(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS lesson_snapshots_date_idx ON lesson_snapshots(date);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS lesson_snapshots;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS edit_message_id bigint;
+
+CREATE TABLE IF NOT EXISTS schedule_messages(
+  id bigserial PRIMARY KEY,
+  chat_id bigint NOT NULL,
+  outbox_id bigint,
+  message_id bigint,
+  stream varchar(255) NOT NULL,
+  substream varchar(255) NOT NULL DEFAULT '',
+  header text NOT NULL DEFAULT '',
+  date_from date NOT NULL,
+  date_to date NOT NULL,
+  lessons jsonb NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  updated_at timestamptz NOT NULL DEFAULT now(),
+  FOREIGN KEY(outbox_id) REFERENCES outbox(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS schedule_messages_date_to_idx ON schedule_messages(date_to);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS schedule_messages;
+
+ALTER TABLE outbox DROP COLUMN IF EXISTS edit_message_id;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS first_lessons(
+  student_id bigint NOT NULL,
+  stream varchar(255) NOT NULL,
+  substream varchar(255) NOT NULL DEFAULT '',
+  date date NOT NULL,
+  lesson jsonb NOT NULL,
+  alerted_at timestamptz,
+  PRIMARY KEY (student_id, stream, substream, date),
+  FOREIGN KEY(student_id) REFERENCES students(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS first_lessons;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Group chats bound to a stream. Times are minutes since midnight, days are bitmasks where bit 0 is sunday.
+CREATE TABLE IF NOT EXISTS chats(
+  id bigint PRIMARY KEY,
+  title varchar(255) NOT NULL DEFAULT '',
+  stream varchar(255),
+  substream varchar(255),
+  active boolean NOT NULL DEFAULT true,
+  morning_time smallint NOT NULL DEFAULT 300,
+  morning_days smallint NOT NULL DEFAULT 126,
+  evening_time smallint NOT NULL DEFAULT 1080,
+  evening_days smallint NOT NULL DEFAULT 62,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS chats;
+-- +goose StatementEnd
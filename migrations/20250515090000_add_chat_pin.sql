@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS pin boolean NOT NULL DEFAULT false;
+
+ALTER TABLE chats
+  ADD COLUMN IF NOT EXISTS pin boolean NOT NULL DEFAULT true,
+  ADD COLUMN IF NOT EXISTS pinned_message_id bigint;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE chats
+  DROP COLUMN IF EXISTS pin,
+  DROP COLUMN IF EXISTS pinned_message_id;
+
+ALTER TABLE outbox DROP COLUMN IF EXISTS pin;
+-- +goose StatementEnd
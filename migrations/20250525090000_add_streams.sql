@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Streams seen on the portal, names of old streams are needed after their IDs change
+CREATE TABLE IF NOT EXISTS streams(
+  id varchar(255) PRIMARY KEY,
+  name text NOT NULL,
+  substreams text[] NOT NULL DEFAULT '{}',
+  seen_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS stream_rollovers(
+  id bigserial PRIMARY KEY,
+  old_stream varchar(255) NOT NULL,
+  old_name text NOT NULL,
+  new_stream varchar(255) NOT NULL,
+  new_name text NOT NULL,
+  students int NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS stream_rollovers;
+DROP TABLE IF EXISTS streams;
+-- +goose StatementEnd
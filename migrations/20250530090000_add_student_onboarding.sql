@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Step of the onboarding after /start, NULL when it is finished or was never started
+ALTER TABLE students ADD COLUMN IF NOT EXISTS onboarding_step varchar(32);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE students DROP COLUMN IF EXISTS onboarding_step;
+-- +goose StatementEnd
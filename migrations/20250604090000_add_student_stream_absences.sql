@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE students ADD COLUMN IF NOT EXISTS stream_absences smallint NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE students DROP COLUMN IF EXISTS stream_absences;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE notification_log ADD COLUMN IF NOT EXISTS key varchar(128);
+
+CREATE UNIQUE INDEX IF NOT EXISTS notification_log_key_idx ON notification_log(kind, student_id, key) WHERE key IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS notification_log_key_idx;
+
+ALTER TABLE notification_log DROP COLUMN IF EXISTS key;
+-- +goose StatementEnd
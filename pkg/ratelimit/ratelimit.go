@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// chatIdle is how long an unused chat bucket is kept.
+const chatIdle = time.Minute
+
+// Bucket is a token bucket refilled at rate tokens per second up to burst tokens.
+type Bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+func NewBucket(rate float64, burst int) *Bucket {
+	return &Bucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Reserve takes a token and returns how long to wait before using it.
+func (b *Bucket) Reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// Wait blocks until a token is available or ctx is done.
+func (b *Bucket) Wait(ctx context.Context) error {
+	return sleep(ctx, b.Reserve())
+}
+
+func (b *Bucket) idle(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.tokens >= 0 && now.Sub(b.last) > chatIdle
+}
+
+// Limiter combines a global bucket with a bucket per chat.
+type Limiter struct {
+	global  *Bucket
+	perChat float64
+
+	chats       map[int64]*Bucket
+	pausedUntil time.Time
+	mu          sync.Mutex
+}
+
+// New returns a limiter allowing rate messages per second with burst overall and perChat messages per second to a chat.
+func New(rate float64, burst int, perChat float64) *Limiter {
+	return &Limiter{
+		global:  NewBucket(rate, burst),
+		perChat: perChat,
+		chats:   make(map[int64]*Bucket),
+	}
+}
+
+// Wait blocks until a message may be sent to the chat or ctx is done.
+func (l *Limiter) Wait(ctx context.Context, chatId int64) error {
+	if err := sleep(ctx, l.pause()); err != nil {
+		return err
+	}
+
+	if err := l.chat(chatId).Wait(ctx); err != nil {
+		return err
+	}
+
+	if err := l.global.Wait(ctx); err != nil {
+		return err
+	}
+
+	// A pause may have been requested while waiting
+	return sleep(ctx, l.pause())
+}
+
+// Pause stops all sending for d, e.g. after Telegram answered with retry_after.
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := time.Now().Add(d)
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+func (l *Limiter) pause() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return time.Until(l.pausedUntil)
+}
+
+func (l *Limiter) chat(chatId int64) *Bucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.chats[chatId]
+	if ok {
+		return bucket
+	}
+
+	if len(l.chats) >= 1024 {
+		now := time.Now()
+		for id, b := range l.chats {
+			if b.idle(now) {
+				delete(l.chats, id)
+			}
+		}
+	}
+
+	bucket = NewBucket(l.perChat, 1)
+	l.chats[chatId] = bucket
+
+	return bucket
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketReserve(t *testing.T) {
+	b := NewBucket(10, 2)
+
+	assert.Zero(t, b.Reserve())
+	assert.Zero(t, b.Reserve())
+	assert.InDelta(t, 100*time.Millisecond, b.Reserve(), float64(10*time.Millisecond))
+	assert.InDelta(t, 200*time.Millisecond, b.Reserve(), float64(10*time.Millisecond))
+}
+
+func TestLimiter(t *testing.T) {
+	testCases := []struct {
+		name     string
+		chats    []int64
+		minTotal time.Duration
+		maxTotal time.Duration
+	}{
+		{
+			name:     "different chats share global rate",
+			chats:    []int64{1, 2, 3, 4, 5, 6},
+			minTotal: 40 * time.Millisecond,
+			maxTotal: 150 * time.Millisecond,
+		},
+		{
+			name:     "same chat is limited by chat rate",
+			chats:    []int64{1, 1, 1},
+			minTotal: 180 * time.Millisecond,
+			maxTotal: 400 * time.Millisecond,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := New(100, 2, 10)
+
+			start := time.Now()
+			for _, chat := range tc.chats {
+				require.NoError(t, l.Wait(t.Context(), chat))
+			}
+			elapsed := time.Since(start)
+
+			assert.GreaterOrEqual(t, elapsed, tc.minTotal)
+			assert.Less(t, elapsed, tc.maxTotal)
+		})
+	}
+}
+
+func TestLimiterPause(t *testing.T) {
+	l := New(100, 10, 100)
+	l.Pause(50 * time.Millisecond)
+
+	start := time.Now()
+	require.NoError(t, l.Wait(t.Context(), 1))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	assert.ErrorIs(t, l.Wait(ctx, 2), context.Canceled)
+}